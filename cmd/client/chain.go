@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/urfave/cli"
+
+	drynx_services "github.com/ldsec/drynx/services"
+	"go.dedis.ch/cothority/v3/skipchain"
+	onet "go.dedis.ch/onet/v3"
+)
+
+// getChainClient reads the network config and gives a client with the roster of the verifying nodes
+func getChainClient() (*drynx_services.API, onet.Roster, error) {
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return nil, onet.Roster{}, err
+	}
+
+	if conf.Network == nil {
+		return nil, onet.Roster{}, errors.New("need some network config")
+	}
+	if len(conf.Network.VerifyingNodes) == 0 {
+		return nil, onet.Roster{}, errors.New("no verifying nodes defined")
+	}
+	roster, err := getRoster(configNetwork{Nodes: conf.Network.VerifyingNodes})
+	if err != nil {
+		return nil, onet.Roster{}, err
+	}
+
+	if conf.Network.Client == nil {
+		return nil, onet.Roster{}, errors.New("no client defined")
+	}
+	client := drynx_services.NewDrynxClient(conf.Network.Client, os.Args[0])
+
+	return client, roster, nil
+}
+
+func printBlock(w io.Writer, sb *skipchain.SkipBlock, asJSON bool) error {
+	audit := drynx_services.AuditChain([]*skipchain.SkipBlock{sb})[0]
+	if asJSON {
+		return printJSON(w, audit)
+	}
+	printAudit(w, audit)
+	return nil
+}
+
+func printJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(v)
+}
+
+func printAudit(w io.Writer, audit drynx_services.BlockAudit) {
+	fmt.Fprintf(w, "block %d %s\n", audit.Index, audit.Hash)
+	fmt.Fprintf(w, "\tsurvey: %s\n", audit.SurveyID)
+	fmt.Fprintf(w, "\ttime: %s\n", audit.Time)
+	fmt.Fprintf(w, "\tverifying nodes: %v\n", audit.Roster)
+	fmt.Fprintf(w, "\tproofs root: %s\n", audit.ProofsRoot)
+
+	types := make([]string, 0, len(audit.Verdicts))
+	for t := range audit.Verdicts {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		fmt.Fprintf(w, "\tproofs %s: %v\n", t, audit.Verdicts[t])
+	}
+
+	for _, d := range audit.Disagreements {
+		fmt.Fprintf(w, "\tdisagreement on %s: valid for %v, rejected by %v\n", d.ProofID, d.Valid, d.Rejected)
+	}
+
+	if len(audit.Errors) == 0 {
+		fmt.Fprintln(w, "\tstatus: ok")
+	}
+	for _, e := range audit.Errors {
+		fmt.Fprintf(w, "\terror: %s\n", e)
+	}
+}
+
+func chainGenesis(c *cli.Context) error {
+	if args := c.Args(); len(args) != 0 {
+		return errors.New("no args expected")
+	}
+
+	client, roster, err := getChainClient()
+	if err != nil {
+		return err
+	}
+
+	genesis, err := client.SendGetGenesis(roster.List[0])
+	if err != nil {
+		return err
+	}
+	return printBlock(os.Stdout, genesis, c.Bool("json"))
+}
+
+func chainLatest(c *cli.Context) error {
+	if args := c.Args(); len(args) != 0 {
+		return errors.New("no args expected")
+	}
+
+	client, roster, err := getChainClient()
+	if err != nil {
+		return err
+	}
+
+	genesis, err := client.SendGetGenesis(roster.List[0])
+	if err != nil {
+		return err
+	}
+	latest, err := client.SendGetLatestBlock(&roster, genesis)
+	if err != nil {
+		return err
+	}
+	return printBlock(os.Stdout, latest, c.Bool("json"))
+}
+
+func chainAudit(c *cli.Context) error {
+	args := c.Args()
+	if len(args) > 1 {
+		return errors.New("need at most a survey name")
+	}
+
+	client, roster, err := getChainClient()
+	if err != nil {
+		return err
+	}
+
+	blocks, err := client.SendGetChain(&roster)
+	if err != nil {
+		return err
+	}
+
+	audits := make([]drynx_services.BlockAudit, 0)
+	for _, audit := range drynx_services.AuditChain(blocks) {
+		if args.Present() && audit.SurveyID != args.First() {
+			continue
+		}
+		audits = append(audits, audit)
+	}
+
+	if len(audits) == 0 {
+		return errors.New("no block found for survey " + args.First())
+	}
+	if c.Bool("json") {
+		return printJSON(os.Stdout, audits)
+	}
+
+	failed := 0
+	for _, audit := range audits {
+		printAudit(os.Stdout, audit)
+		if len(audit.Errors) > 0 {
+			failed++
+		}
+	}
+	for _, vn := range drynx_services.FlagDeviatingVNs(audits) {
+		fmt.Printf("verifying node %s deviated from the agreed verdicts in most of its blocks\n", vn)
+	}
+	fmt.Printf("%d block(s) audited from genesis %s, %d with errors\n", len(audits), hex.EncodeToString(blocks[0].Hash), failed)
+
+	return nil
+}
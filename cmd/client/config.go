@@ -3,7 +3,9 @@ package main
 import (
 	"io"
 
+	"go.dedis.ch/cothority/v3/skipchain"
 	kyber_encoding "go.dedis.ch/kyber/v3/util/encoding"
+	onet "go.dedis.ch/onet/v3"
 	onet_network "go.dedis.ch/onet/v3/network"
 
 	drynx_lib "github.com/ldsec/drynx/lib"
@@ -12,8 +14,9 @@ import (
 )
 
 type configNetwork struct {
-	Client *onet_network.ServerIdentity
-	Nodes  []onet_network.ServerIdentity
+	Client         *onet_network.ServerIdentity
+	Nodes          []onet_network.ServerIdentity
+	VerifyingNodes []onet_network.ServerIdentity
 }
 type configSurvey struct {
 	Name      *string
@@ -27,13 +30,16 @@ type config struct {
 type serverIdentityStr struct {
 	Address   onet_network.Address
 	PublicKey string
+	// key of the node's skipchain service, with which verifying nodes sign the blocks
+	SkipchainKey string `toml:",omitempty"`
 }
 type clientIdentityStr struct {
 	URL string
 }
 type configNetworkStr struct {
-	Client *clientIdentityStr
-	Nodes  []serverIdentityStr
+	Client         *clientIdentityStr
+	Nodes          []serverIdentityStr
+	VerifyingNodes []serverIdentityStr
 }
 type configStr struct {
 	Network *configNetworkStr
@@ -45,7 +51,16 @@ func serverIdentityToUnsafe(id onet_network.ServerIdentity) (serverIdentityStr,
 	if err != nil {
 		return serverIdentityStr{}, err
 	}
-	return serverIdentityStr{id.Address, point}, nil
+	skipchainKey := ""
+	for _, sid := range id.ServiceIdentities {
+		if sid.Name != skipchain.ServiceName {
+			continue
+		}
+		if skipchainKey, err = kyber_encoding.PointToStringHex(onet.ServiceFactory.Suite(skipchain.ServiceName), sid.Public); err != nil {
+			return serverIdentityStr{}, err
+		}
+	}
+	return serverIdentityStr{id.Address, point, skipchainKey}, nil
 }
 
 func clientIdentityToUnsafe(id onet_network.ServerIdentity) (clientIdentityStr, error) {
@@ -62,15 +77,27 @@ func (conf configNetwork) toUnsafe() (configNetworkStr, error) {
 		client = &clientStruct
 	}
 
-	nodes := make([]serverIdentityStr, len(conf.Nodes))
-	for i, n := range conf.Nodes {
+	nodes, err := serverIdentitiesToUnsafe(conf.Nodes)
+	if err != nil {
+		return configNetworkStr{}, err
+	}
+	verifyingNodes, err := serverIdentitiesToUnsafe(conf.VerifyingNodes)
+	if err != nil {
+		return configNetworkStr{}, err
+	}
+
+	return configNetworkStr{client, nodes, verifyingNodes}, nil
+}
+
+func serverIdentitiesToUnsafe(ids []onet_network.ServerIdentity) ([]serverIdentityStr, error) {
+	strs := make([]serverIdentityStr, len(ids))
+	for i, id := range ids {
 		var err error
-		if nodes[i], err = serverIdentityToUnsafe(n); err != nil {
-			return configNetworkStr{}, err
+		if strs[i], err = serverIdentityToUnsafe(id); err != nil {
+			return nil, err
 		}
 	}
-
-	return configNetworkStr{client, nodes}, nil
+	return strs, nil
 }
 
 func (conf configNetworkStr) toSafe() (configNetwork, error) {
@@ -79,16 +106,44 @@ func (conf configNetworkStr) toSafe() (configNetwork, error) {
 		client = &onet_network.ServerIdentity{URL: conf.Client.URL}
 	}
 
-	nodes := make([]onet_network.ServerIdentity, len(conf.Nodes))
-	for i, n := range conf.Nodes {
-		point, err := kyber_encoding.StringHexToPoint(drynx_lib.Suite, n.PublicKey)
+	nodes, err := serverIdentitiesToSafe(conf.Nodes)
+	if err != nil {
+		return configNetwork{}, err
+	}
+	verifyingNodes, err := serverIdentitiesToSafe(conf.VerifyingNodes)
+	if err != nil {
+		return configNetwork{}, err
+	}
+
+	return configNetwork{client, nodes, verifyingNodes}, nil
+}
+
+func serverIdentitiesToSafe(strs []serverIdentityStr) ([]onet_network.ServerIdentity, error) {
+	ids := make([]onet_network.ServerIdentity, len(strs))
+	for i, str := range strs {
+		point, err := kyber_encoding.StringHexToPoint(drynx_lib.Suite, str.PublicKey)
 		if err != nil {
-			return configNetwork{}, err
+			return nil, err
+		}
+		ids[i] = *onet_network.NewServerIdentity(point, str.Address)
+		if str.SkipchainKey != "" {
+			if ids[i], err = withSkipchainKey(ids[i], str.SkipchainKey); err != nil {
+				return nil, err
+			}
 		}
-		nodes[i] = *onet_network.NewServerIdentity(point, n.Address)
 	}
+	return ids, nil
+}
 
-	return configNetwork{client, nodes}, nil
+// withSkipchainKey adds the public key (as hex) of its skipchain service to a node
+func withSkipchainKey(id onet_network.ServerIdentity, publicHex string) (onet_network.ServerIdentity, error) {
+	suite := onet.ServiceFactory.Suite(skipchain.ServiceName)
+	public, err := kyber_encoding.StringHexToPoint(suite, publicHex)
+	if err != nil {
+		return onet_network.ServerIdentity{}, err
+	}
+	id.ServiceIdentities = []onet_network.ServiceIdentity{onet_network.NewServiceIdentity(skipchain.ServiceName, suite, public, nil)}
+	return id, nil
 }
 
 func readConfigFrom(r io.Reader) (config, error) {
@@ -10,6 +10,9 @@ import (
 	"github.com/urfave/cli"
 )
 
+var jsonFlag = cli.BoolFlag{Name: "json", Usage: "output a JSON report"}
+var genesisFlag = cli.StringFlag{Name: "genesis", Usage: "hash (as hex) of the trusted genesis block of the verifying nodes"}
+
 func main() {
 	app := cli.NewApp()
 	app.Usage = "communicate with a Drynx network"
@@ -19,6 +22,7 @@ func main() {
 	if you want to generate a network config, use something like
 		%[1]s network new |
 			%[1]s network add-node 1.drynx.c4dt.org 1234abc |
+			%[1]s network add-verifying-node 3.drynx.c4dt.org 9abc012 3def456 |
 			%[1]s network set-client 2.drynx.c4dt.org 5678def >
 			$my_network_config
 	if you want to generate a survey config, use something like
@@ -28,6 +32,13 @@ func main() {
 	then, you can launch a given survey on a given network
 		cat $my_network_config $my_survey_config |
 			%[1]s survey new run
+	and audit the proofs' skipchain of the verifying nodes
+		cat $my_network_config | %[1]s chain audit
+	or export the proofs of a survey to re-verify them offline
+		cat $my_network_config | %[1]s proofs export my-survey > $my_bundle
+		%[1]s proofs verify --genesis $trusted_genesis_hash < $my_bundle
+	and score a local CSV with a trained logistic regression model
+		%[1]s model predict $my_model < $my_csv
 	`, "\t", "   ", -1)), os.Args[0])
 
 	app.Commands = []cli.Command{{
@@ -42,6 +53,11 @@ func main() {
 			ArgsUsage: "host:node-port node-public-key-as-hex",
 			Usage:     "on a network config stream, add a node with a public key",
 			Action:    networkAddNode,
+		}, {
+			Name:      "add-verifying-node",
+			ArgsUsage: "host:node-port node-public-key-as-hex [skipchain-public-key-as-hex]",
+			Usage:     "on a network config stream, add a verifying node with a public key, whose skipchain the chain and proofs commands read; its skipchain key is needed to run verified surveys",
+			Action:    networkAddVerifyingNode,
 		}, {
 			Name:      "set-client",
 			ArgsUsage: "host:client-port",
@@ -64,11 +80,52 @@ func main() {
 		}, {
 			Name:      "run",
 			ArgsUsage: "client-to-connect public-of-client",
-			Usage:     "sink of a survey and network stream, run the survey on the network",
+			Usage:     "sink of a survey and network stream, run the survey on the network, with proofs verified by the verifying nodes if there are some",
 			Action:    surveyRun,
+		}}}, {
+		Name:  "chain",
+		Usage: "skipchain of the verifying nodes",
+		Subcommands: []cli.Command{{
+			Name:   "genesis",
+			Usage:  "sink of a network stream, show the genesis block",
+			Flags:  []cli.Flag{jsonFlag},
+			Action: chainGenesis,
+		}, {
+			Name:   "latest",
+			Usage:  "sink of a network stream, show the latest block",
+			Flags:  []cli.Flag{jsonFlag},
+			Action: chainLatest,
+		}, {
+			Name:      "audit",
+			ArgsUsage: "[survey-name]",
+			Usage:     "sink of a network stream, walk the chain and verify every block (or only the survey's)",
+			Flags:     []cli.Flag{jsonFlag},
+			Action:    chainAudit,
+		}}}, {
+		Name:  "proofs",
+		Usage: "proofs stored by the verifying nodes",
+		Subcommands: []cli.Command{{
+			Name:      "export",
+			ArgsUsage: "survey-name",
+			Usage:     "sink of a network stream, export the survey's proofs, query and block as a bundle",
+			Action:    proofsExport,
+		}, {
+			Name:   "verify",
+			Usage:  "sink of a proofs bundle, re-verify every proof offline against a trusted genesis block",
+			Flags:  []cli.Flag{jsonFlag, genesisFlag},
+			Action: proofsVerify,
+		}}}, {
+		Name:  "model",
+		Usage: "trained models",
+		Subcommands: []cli.Command{{
+			Name:      "predict",
+			ArgsUsage: "model-file",
+			Usage:     "sink of a CSV stream, score each record with the model",
+			Action:    modelPredict,
 		}}}}
 
 	if err := app.Run(os.Args); err != nil {
 		onet_log.Error(err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/urfave/cli"
+
+	libdrynxencoding "github.com/ldsec/drynx/lib/encoding"
+)
+
+// featureColumns gives the column of each feature of the model: by name in the header if the model has feature names,
+// in order otherwise
+func featureColumns(model *libdrynxencoding.LogisticRegressionModel, header []string) ([]int, error) {
+	columns := make([]int, len(model.Means))
+	if len(model.Features) == 0 {
+		for i := range columns {
+			columns[i] = i
+		}
+		return columns, nil
+	}
+
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+	for i, name := range model.Features {
+		column, ok := index[name]
+		if !ok {
+			return nil, fmt.Errorf("no column for feature %q", name)
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+func modelPredict(c *cli.Context) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return errors.New("need a model file")
+	}
+
+	model, err := libdrynxencoding.LoadModel(args.First())
+	if err != nil {
+		return err
+	}
+
+	reader := csv.NewReader(os.Stdin)
+	reader.TrimLeadingSpace = true
+	writer := csv.NewWriter(os.Stdout)
+
+	var columns []int
+	if len(model.Features) > 0 {
+		header, err := reader.Read()
+		if err != nil {
+			return fmt.Errorf("reading header: %w", err)
+		}
+		if columns, err = featureColumns(model, header); err != nil {
+			return err
+		}
+	} else if columns, err = featureColumns(model, nil); err != nil {
+		return err
+	}
+
+	// a binary regression has a single row of weights for two classes
+	classes := len(model.Weights)
+	if classes == 1 {
+		classes = 2
+	}
+	header := []string{"class"}
+	for class := 0; class < classes; class++ {
+		header = append(header, "p"+strconv.Itoa(class))
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading record: %w", err)
+		}
+
+		record := make([]float64, len(columns))
+		for i, column := range columns {
+			if column >= len(row) {
+				return fmt.Errorf("missing column %d at record %d", column, line)
+			}
+			if record[i], err = strconv.ParseFloat(row[column], 64); err != nil {
+				return fmt.Errorf("parsing record %d: %w", line, err)
+			}
+		}
+
+		probabilities, err := model.Predict(record)
+		if err != nil {
+			return fmt.Errorf("scoring record %d: %w", line, err)
+		}
+		scored := []string{strconv.FormatInt(libdrynxencoding.PredictClass(probabilities), 10)}
+		for _, p := range probabilities {
+			scored = append(scored, strconv.FormatFloat(p, 'g', -1, 64))
+		}
+		if err := writer.Write(scored); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
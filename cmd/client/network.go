@@ -22,19 +22,25 @@ func networkNew(c *cli.Context) error {
 	return conf.writeTo(os.Stdout)
 }
 
-func networkAddNode(c *cli.Context) error {
-	args := c.Args()
+func parseNode(args cli.Args) (onet_network.ServerIdentity, error) {
 	if len(args) != 2 {
-		return errors.New("need a host and its public key")
+		return onet_network.ServerIdentity{}, errors.New("need a host and its public key")
 	}
 	host, publicHex := args.Get(0), args.Get(1)
 
 	public, err := kyber_util_encoding.StringHexToPoint(drynx_lib.Suite, publicHex)
 	if err != nil {
-		return err
+		return onet_network.ServerIdentity{}, err
 	}
 	addr := onet_network.NewTCPAddress(host)
-	id := *onet_network.NewServerIdentity(public, addr)
+	return *onet_network.NewServerIdentity(public, addr), nil
+}
+
+func networkAddNode(c *cli.Context) error {
+	id, err := parseNode(c.Args())
+	if err != nil {
+		return err
+	}
 
 	conf, err := readConfigFrom(os.Stdin)
 	if err != nil {
@@ -46,6 +52,31 @@ func networkAddNode(c *cli.Context) error {
 	return conf.writeTo(os.Stdout)
 }
 
+func networkAddVerifyingNode(c *cli.Context) error {
+	args := c.Args()
+	if len(args) == 3 {
+		args = args[:2]
+	}
+	id, err := parseNode(args)
+	if err != nil {
+		return err
+	}
+	if len(c.Args()) == 3 {
+		if id, err = withSkipchainKey(id, c.Args().Get(2)); err != nil {
+			return err
+		}
+	}
+
+	conf, err := readConfigFrom(os.Stdin)
+	if err != nil {
+		return err
+	}
+
+	conf.Network.VerifyingNodes = append(conf.Network.VerifyingNodes, id)
+
+	return conf.writeTo(os.Stdout)
+}
+
 func networkSetClient(c *cli.Context) error {
 	args := c.Args()
 	if len(args) != 1 {
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/urfave/cli"
+
+	drynx_services "github.com/ldsec/drynx/services"
+	"go.dedis.ch/cothority/v3/skipchain"
+)
+
+func proofsExport(c *cli.Context) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return errors.New("need a survey name")
+	}
+
+	client, roster, err := getChainClient()
+	if err != nil {
+		return err
+	}
+
+	export, err := client.ExportProofs(roster.List[0], args.First())
+	if err != nil {
+		return err
+	}
+	bundle, err := drynx_services.EncodeProofsExport(export)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(bundle)
+	return err
+}
+
+func proofsVerify(c *cli.Context) error {
+	if args := c.Args(); len(args) != 0 {
+		return errors.New("no args expected")
+	}
+
+	if !c.IsSet("genesis") {
+		return errors.New("need the hash of the trusted genesis block")
+	}
+	genesisID, err := hex.DecodeString(c.String("genesis"))
+	if err != nil {
+		return err
+	}
+
+	bundle, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	export, err := drynx_services.DecodeProofsExport(bundle)
+	if err != nil {
+		return err
+	}
+
+	report := drynx_services.VerifyProofsExport(export, skipchain.SkipBlockID(genesisID))
+	if c.Bool("json") {
+		return printJSON(os.Stdout, report)
+	}
+
+	fmt.Printf("survey %s in block %s\n", report.SurveyID, report.Block)
+	for _, e := range report.Errors {
+		fmt.Printf("\tblock error: %s\n", e)
+	}
+	for _, p := range report.Proofs {
+		fmt.Printf("\t%s: recorded %s, verified %s\n", p.Key, p.Recorded, p.Verified)
+		for _, e := range p.Errors {
+			fmt.Printf("\t\terror: %s\n", e)
+		}
+	}
+	fmt.Printf("%d proof(s) verified, %d with errors\n", len(report.Proofs), report.Failed())
+
+	if len(report.Errors) > 0 || report.Failed() > 0 {
+		return errors.New("verification failed")
+	}
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/urfave/cli"
 
 	drynx_lib "github.com/ldsec/drynx/lib"
+	drynx_range "github.com/ldsec/drynx/lib/range"
 	drynx_services "github.com/ldsec/drynx/services"
 	kyber "go.dedis.ch/kyber/v3"
 	onet "go.dedis.ch/onet/v3"
@@ -90,32 +91,61 @@ func surveyRun(c *cli.Context) error {
 	if conf.Survey.Operation == nil {
 		return errors.New("need a survey operation")
 	}
+	operation := drynx_lib.ChooseOperation(
+		*conf.Survey.Operation, // operation
+		len(roster.List),       // min num of DP to query
+		len(roster.List),       // max num of DP to query
+		5,                      // dimension for linear regression
+		0)                      // "cutting factor", how much to remove of gen data[0:#/n]
+
+	// with verifying nodes, the outputs of the DPs are proven to be in [0, 2^32) and the result is only given once the
+	// verifying nodes accepted the proofs. A node then cannot be both a CN and a DP, as each keeps its own proof
+	// collection of the survey.
+	cnRoster := &roster
+	vnRoster := &roster
+	ranges := []*[]int64{}
+	var signatures []*[]drynx_lib.PublishSignatureBytes
+	proofs := 0
+	if len(conf.Network.VerifyingNodes) > 0 {
+		vns, err := getRoster(configNetwork{Nodes: conf.Network.VerifyingNodes})
+		if err != nil {
+			return err
+		}
+		vnRoster = &vns
+		cnRoster = onet.NewRoster(append([]*onet_network.ServerIdentity{roster.List[0]}, roster.List[3:]...))
+		ranges = make([]*[]int64, operation.NbrOutput)
+		for i := range ranges {
+			ranges[i] = &[]int64{2, 32}
+		}
+		signatures = drynx_range.InitRangeProofSignatures(len(roster.List), ranges)
+		proofs = 1
+	}
+
+	idToPublic := make(map[string]kyber.Point)
+	for _, r := range []*onet.Roster{&roster, vnRoster} {
+		for _, id := range r.List {
+			idToPublic[id.String()] = id.Public
+		}
+	}
+
 	sq := client.GenerateSurveyQuery(
 
 		/// network
 
-		&roster, // CN roster
-		&roster, // VN roster
+		cnRoster, // CN roster
+		vnRoster, // VN roster
 		map[string]*[]onet_network.ServerIdentity{ // map CN to DPs
 			roster.List[0].String(): {*roster.List[1], *roster.List[2]}},
-		map[string]kyber.Point{ // map CN|DP|VN to pub key
-			roster.List[0].String(): roster.List[0].Public,
-			roster.List[1].String(): roster.List[1].Public,
-			roster.List[2].String(): roster.List[2].Public},
+		idToPublic, // map CN|DP|VN to pub key
 
 		/// gen
 
 		*conf.Survey.Name, // survey id
-		drynx_lib.ChooseOperation(
-			*conf.Survey.Operation, // operation
-			len(roster.List),       // min num of DP to query
-			len(roster.List),       // max num of DP to query
-			5,                      // dimension for linear regression
-			0),                     // "cutting factor", how much to remove of gen data[0:#/n]
+		operation,
 
-		[]*[]int64{}, // range for each output of operation
-		nil,          // signature of range validity
-		int(0),       // 0 == no proof, 1 == proof, 2 == optimized proof
+		ranges,     // range for each output of operation
+		signatures, // signature of range validity
+		proofs,     // 0 == no proof, 1 == proof, 2 == optimized proof
 
 		false, // obfuscation
 		[]float64{
@@ -131,7 +161,14 @@ func surveyRun(c *cli.Context) error {
 		0, // cutting factor
 	)
 
-	_, aggregations, err := client.SendSurveyQuery(sq)
+	send := client.SendSurveyQuery
+	if proofs != 0 {
+		if err := client.SendSurveyQueryToVNs(vnRoster, &sq); err != nil {
+			return err
+		}
+		send = client.SendSurveyQueryVerified
+	}
+	_, aggregations, err := send(sq)
 	if err != nil {
 		return err
 	}
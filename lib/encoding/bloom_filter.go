@@ -0,0 +1,148 @@
+package libdrynxencoding
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"strconv"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+//Note: a Bloom filter of size m with k hash functions sets, for each element, the k bits given by BloomIndexes. The
+//union (intersection) of the sets of the DPs is encoded bit by bit under OR (AND), so that only the bits of the global
+//filter leak, not how many DPs set them.
+
+//BloomIndexes gives the k bits of a Bloom filter of size m set by an element (double hashing of its SHA-256)
+func BloomIndexes(element string, m int, k int) []int {
+	hash := sha256.Sum256([]byte(element))
+	h1 := binary.BigEndian.Uint64(hash[:8])
+	h2 := binary.BigEndian.Uint64(hash[8:16]) | 1
+
+	indexes := make([]int, k)
+	for i := range indexes {
+		indexes[i] = int((h1 + uint64(i)*h2) % uint64(m))
+	}
+	return indexes
+}
+
+//Elements gives the elements of integer values, as hashed in the Bloom filters and HyperLogLog registers
+func Elements(input []int64) []string {
+	elements := make([]string, len(input))
+	for i, v := range input {
+		elements[i] = strconv.FormatInt(v, 10)
+	}
+	return elements
+}
+
+//BloomFilter builds the Bloom filter of size m with k hash functions of a set of elements
+func BloomFilter(elements []string, m int, k int) []bool {
+	filter := make([]bool, m)
+	for _, e := range elements {
+		for _, i := range BloomIndexes(e, m, k) {
+			filter[i] = true
+		}
+	}
+	return filter
+}
+
+//EncodeBloomUnion encodes the local Bloom filter for the union
+func EncodeBloomUnion(input []string, m int, k int, pubKey kyber.Point) ([]libunlynx.CipherText, []int64) {
+	ciphers, clears, _ := EncodeBloomUnionWithProofs(input, m, k, pubKey, nil, nil)
+	return ciphers, clears
+}
+
+//EncodeBloomUnionWithProofs encodes the local Bloom filter for the union with range proofs
+func EncodeBloomUnionWithProofs(input []string, m int, k int, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	return encodeBloomFilter(BloomFilter(input, m, k), pubKey, sigs, lu, EncodeBitOr, EncodeBitOrWithProof)
+}
+
+//EncodeBloomInter encodes the local Bloom filter for the intersection
+func EncodeBloomInter(input []string, m int, k int, pubKey kyber.Point) ([]libunlynx.CipherText, []int64) {
+	ciphers, clears, _ := EncodeBloomInterWithProofs(input, m, k, pubKey, nil, nil)
+	return ciphers, clears
+}
+
+//EncodeBloomInterWithProofs encodes the local Bloom filter for the intersection with range proofs
+func EncodeBloomInterWithProofs(input []string, m int, k int, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	return encodeBloomFilter(BloomFilter(input, m, k), pubKey, sigs, lu, EncodeBitAND, EncodeBitANDWithProof)
+}
+
+func encodeBloomFilter(filter []bool, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64,
+	encode func(bool, kyber.Point) (*libunlynx.CipherText, int64),
+	encodeWithProof func(bool, kyber.Point, []libdrynx.PublishSignature, int64, int64) (*libunlynx.CipherText, int64, libdrynxrange.CreateProof)) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	ciphertextTuples := make([]libunlynx.CipherText, len(filter))
+	cleartextTuples := make([]int64, len(filter))
+	proofsTuples := make([]libdrynxrange.CreateProof, len(filter))
+	wg := libunlynx.StartParallelize(len(filter))
+	for i := range filter {
+		go func(i int) {
+			defer wg.Done()
+			tmp := &libunlynx.CipherText{}
+			if sigs != nil {
+				tmp, cleartextTuples[i], proofsTuples[i] = encodeWithProof(filter[i], pubKey, libdrynxrange.ReadColumn(sigs, i), (*lu[i])[1], (*lu[i])[0])
+			} else {
+				tmp, cleartextTuples[i] = encode(filter[i], pubKey)
+			}
+			ciphertextTuples[i] = *tmp
+		}(i)
+	}
+	libunlynx.EndParallelize(wg)
+
+	return ciphertextTuples, cleartextTuples, proofsTuples
+}
+
+//DecodeBloomUnion decodes the global Bloom filter of the union, estimates its cardinality and tests the membership of
+//the candidates of the querier
+func DecodeBloomUnion(result []libunlynx.CipherText, secKey kyber.Scalar, k int, candidates []string) (float64, []bool) {
+	filter := bloomFilterResult(DecodeUnion(result, secKey))
+	return BloomCardinality(filter, k), BloomMembers(filter, k, candidates)
+}
+
+//DecodeBloomInter decodes the global Bloom filter of the intersection, estimates its cardinality and tests the
+//membership of the candidates of the querier. As the filter is the AND of the filters of the DPs, it can have more bits
+//than the filter of the intersection: the cardinality is overestimated and the false positive rate is higher.
+func DecodeBloomInter(result []libunlynx.CipherText, secKey kyber.Scalar, k int, candidates []string) (float64, []bool) {
+	filter := bloomFilterResult(DecodeInter(result, secKey))
+	return BloomCardinality(filter, k), BloomMembers(filter, k, candidates)
+}
+
+func bloomFilterResult(filter []int64) []float64 {
+	result := make([]float64, len(filter))
+	for i, v := range filter {
+		result[i] = float64(v)
+	}
+	return result
+}
+
+//BloomCardinality estimates the number of elements of a decoded Bloom filter with k hash functions from its number of
+//bits set: -(m/k) ln(1 - X/m). It is infinite if the filter is full.
+func BloomCardinality(filter []float64, k int) float64 {
+	set := 0
+	for _, v := range filter {
+		if v != 0 {
+			set++
+		}
+	}
+	m := float64(len(filter))
+	return -m / float64(k) * math.Log(1-float64(set)/m)
+}
+
+//BloomMembers tests the membership of the candidates in a decoded Bloom filter with k hash functions. There can be false
+//positives but no false negatives.
+func BloomMembers(filter []float64, k int, candidates []string) []bool {
+	members := make([]bool, len(candidates))
+	for i, c := range candidates {
+		members[i] = true
+		for _, j := range BloomIndexes(c, len(filter), k) {
+			if filter[j] == 0 {
+				members[i] = false
+				break
+			}
+		}
+	}
+	return members
+}
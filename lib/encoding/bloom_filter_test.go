@@ -0,0 +1,52 @@
+package libdrynxencoding_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+//TestEncodeDecodeBloom tests the union and intersection of sets of strings with Bloom filters
+func TestEncodeDecodeBloom(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	m, k := 512, 4
+
+	// every set has the common elements and 20 elements of its own
+	common := []string{"alice", "bob", "carol"}
+	sets := make([][]string, 3)
+	for i := range sets {
+		sets[i] = append([]string{}, common...)
+		for j := 0; j < 20; j++ {
+			sets[i] = append(sets[i], fmt.Sprintf("dp%d-%d", i, j))
+		}
+	}
+	candidates := []string{"alice", "dp0-3", "dp2-19", "mallory"}
+
+	union := make([]libunlynx.CipherText, m)
+	inter := make([]libunlynx.CipherText, m)
+	for i := range union {
+		union[i] = *libunlynx.NewCipherText()
+		inter[i] = *libunlynx.NewCipherText()
+	}
+	for _, set := range sets {
+		unionDP, _ := libdrynxencoding.EncodeBloomUnion(set, m, k, pubKey)
+		interDP, _ := libdrynxencoding.EncodeBloomInter(set, m, k, pubKey)
+		for i := range union {
+			union[i].Add(union[i], unionDP[i])
+			inter[i].Add(inter[i], interDP[i])
+		}
+	}
+
+	cardinality, members := libdrynxencoding.DecodeBloomUnion(union, secKey, k, candidates)
+	assert.InDelta(t, 63, cardinality, 6)
+	assert.Equal(t, []bool{true, true, true, false}, members)
+
+	cardinality, members = libdrynxencoding.DecodeBloomInter(inter, secKey, k, candidates)
+	assert.InDelta(t, 3, cardinality, 3)
+	assert.Equal(t, []bool{true, false, false, false}, members)
+}
@@ -0,0 +1,151 @@
+package libdrynxencoding
+
+import (
+	"math"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+//Note: in classifierEval, a DP scores its records with the model and sends the counts of its confusion matrix, row
+//after row (actual class i, predicted class j at i*classes + j), followed by, for each class c, the histogram of the
+//scores of class c of its records of class c and then of its other records. The counts of the DPs are summed.
+
+// ClassifierScores gives the probability of each class of each record for the model of lrParameters: of 0 and 1 if it is
+// binary. The records are standardised with the means and standard deviations of the model, if given.
+func ClassifierScores(xData [][]float64, lrParameters libdrynx.LogisticRegressionParameters) [][]float64 {
+	if len(xData) == 0 {
+		return nil
+	}
+	X := xData
+	if len(lrParameters.Means) > 0 && len(lrParameters.StandardDeviations) > 0 {
+		X = StandardiseWithConstantFeatures(xData, lrParameters.Means, lrParameters.StandardDeviations)
+	}
+
+	weights := libdrynx.LogisticRegressionWeightMatrix(lrParameters.Weights, lrParameters)
+	scores := make([][]float64, len(X))
+	for i, x := range X {
+		if len(weights) == 1 {
+			p := PredictInClear(x, weights[0])
+			scores[i] = []float64{1 - p, p}
+		} else {
+			scores[i] = PredictClassesInClear(x, weights, lrParameters.Multinomial)
+		}
+	}
+	return scores
+}
+
+// ClassifierEvalCounts computes the confusion matrix and the histograms of the scores of the records. The records with
+// a label out of the classes are ignored.
+func ClassifierEvalCounts(scores [][]float64, yData []int64, classes int, bins int) []int64 {
+	counts := make([]int64, classes*classes+2*classes*bins)
+	histograms := counts[classes*classes:]
+	for i, s := range scores {
+		actual := int(yData[i])
+		if actual < 0 || actual >= classes {
+			continue
+		}
+		counts[actual*classes+int(PredictClass(s))]++
+
+		for c, p := range s {
+			bin := int(math.Min(math.Max(p*float64(bins), 0), float64(bins-1)))
+			if actual == c {
+				histograms[2*c*bins+bin]++
+			} else {
+				histograms[(2*c+1)*bins+bin]++
+			}
+		}
+	}
+	return counts
+}
+
+// EncodeClassifierEval encodes the confusion matrix and the histograms of the scores of the local records
+func EncodeClassifierEval(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, bins int, pubKey kyber.Point) ([]libunlynx.CipherText, []int64) {
+	ciphers, clears, _ := EncodeClassifierEvalWithProofs(xData, yData, lrParameters, bins, pubKey, nil, nil)
+	return ciphers, clears
+}
+
+// EncodeClassifierEvalWithProofs encodes the confusion matrix and the histograms of the scores of the local records
+// with range proofs, e.g. for each count to be at most the number of records of the DP (see libdrynx.CountRanges)
+func EncodeClassifierEvalWithProofs(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, bins int, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	counts := ClassifierEvalCounts(ClassifierScores(xData, lrParameters), yData, libdrynx.ClassifierClasses(lrParameters), bins)
+	ciphertextTuples, createRangeProof := encryptWithRangeProofs(counts, pubKey, sigs, lu)
+	return ciphertextTuples, counts, createRangeProof
+}
+
+// DecodeClassifierEval decodes the global confusion matrix and histograms and evaluates the classifier
+func DecodeClassifierEval(result []libunlynx.CipherText, secKey kyber.Scalar, classes int, bins int) libdrynx.ClassifierEvaluation {
+	return ClassifierEvaluationFromCounts(DecodeFreqCount(result, secKey), classes, bins)
+}
+
+// ratio gives a / b, or 0 if b is 0
+func ratio(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return a / b
+}
+
+// ClassifierEvaluationFromCounts computes the metrics and the ROC curves from a confusion matrix and the histograms of
+// the scores
+func ClassifierEvaluationFromCounts(counts []int64, classes int, bins int) libdrynx.ClassifierEvaluation {
+	evaluation := libdrynx.ClassifierEvaluation{
+		Confusion:          counts[:classes*classes],
+		Precision:          make([]float64, classes),
+		Recall:             make([]float64, classes),
+		F1:                 make([]float64, classes),
+		FalsePositiveRates: make([][]float64, classes),
+		TruePositiveRates:  make([][]float64, classes),
+		AUC:                make([]float64, classes),
+	}
+
+	total, correct := 0.0, 0.0
+	for c := 0; c < classes; c++ {
+		actual, predicted := 0.0, 0.0
+		for k := 0; k < classes; k++ {
+			actual += float64(evaluation.Confusion[c*classes+k])
+			predicted += float64(evaluation.Confusion[k*classes+c])
+		}
+		truePositives := float64(evaluation.Confusion[c*classes+c])
+		total += actual
+		correct += truePositives
+
+		evaluation.Precision[c] = ratio(truePositives, predicted)
+		evaluation.Recall[c] = ratio(truePositives, actual)
+		evaluation.F1[c] = ratio(2*evaluation.Precision[c]*evaluation.Recall[c], evaluation.Precision[c]+evaluation.Recall[c])
+
+		histograms := counts[classes*classes+2*c*bins:]
+		evaluation.FalsePositiveRates[c], evaluation.TruePositiveRates[c], evaluation.AUC[c] = ROCCurve(histograms[:bins], histograms[bins:2*bins])
+	}
+	evaluation.Accuracy = ratio(correct, total)
+	return evaluation
+}
+
+// ROCCurve computes the ROC curve and its area from the histograms of the scores of the positive and negative records:
+// the point t is for the records of bin t or above predicted as positives
+func ROCCurve(positives []int64, negatives []int64) ([]float64, []float64, float64) {
+	bins := len(positives)
+	p, n := 0.0, 0.0
+	for b := 0; b < bins; b++ {
+		p += float64(positives[b])
+		n += float64(negatives[b])
+	}
+
+	fpr := make([]float64, bins+1)
+	tpr := make([]float64, bins+1)
+	truePositives, falsePositives := 0.0, 0.0
+	for t := bins - 1; t >= 0; t-- {
+		truePositives += float64(positives[t])
+		falsePositives += float64(negatives[t])
+		tpr[t] = ratio(truePositives, p)
+		fpr[t] = ratio(falsePositives, n)
+	}
+
+	auc := 0.0
+	for t := 0; t < bins; t++ {
+		auc += (fpr[t] - fpr[t+1]) * (tpr[t] + tpr[t+1]) / 2
+	}
+	return fpr, tpr, auc
+}
@@ -0,0 +1,155 @@
+package libdrynxencoding_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+// TestEncodeDecodeClassifierEval compares the evaluation of a binary model over the encrypted counts of 3 DPs with the
+// metrics of the pooled records
+func TestEncodeDecodeClassifierEval(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	rng := rand.New(rand.NewSource(11))
+	bins := 100
+
+	// y = 1 if x0 plus noise is positive, scored by the model sigmoid(2 x0)
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 1, Weights: []float64{0, 2}}
+	xs := make([][][]float64, 3)
+	ys := make([][]int64, 3)
+	for dp := range xs {
+		for i := 0; i < 30; i++ {
+			x := rng.NormFloat64()
+			y := int64(0)
+			if x+rng.NormFloat64() > 0 {
+				y = 1
+			}
+			xs[dp] = append(xs[dp], []float64{x})
+			ys[dp] = append(ys[dp], y)
+		}
+	}
+
+	var result []libunlynx.CipherText
+	var scores []float64
+	var predicted, actual []int64
+	for dp := range xs {
+		ciphers, _ := libdrynxencoding.EncodeClassifierEval(xs[dp], ys[dp], lrParameters, bins, pubKey)
+		if result == nil {
+			result = ciphers
+		} else {
+			for i := range result {
+				result[i].Add(result[i], ciphers[i])
+			}
+		}
+
+		for i, s := range libdrynxencoding.ClassifierScores(xs[dp], lrParameters) {
+			scores = append(scores, s[1])
+			predicted = append(predicted, libdrynxencoding.PredictClass(s))
+			actual = append(actual, ys[dp][i])
+		}
+	}
+
+	operation := libdrynx.ClassifierEvalOperation(lrParameters, bins)
+	assert.Len(t, result, operation.NbrOutput)
+	var evaluation libdrynx.ClassifierEvaluation
+	evaluation.FromFloats(libdrynxencoding.Decode(result, secKey, operation), 2, bins)
+
+	assert.Equal(t, int64(90), evaluation.Confusion[0]+evaluation.Confusion[1]+evaluation.Confusion[2]+evaluation.Confusion[3])
+	assert.InDelta(t, libdrynxencoding.Accuracy(predicted, actual), evaluation.Accuracy, 1e-12)
+	assert.InDelta(t, libdrynxencoding.Precision(predicted, actual), evaluation.Precision[1], 1e-12)
+	assert.InDelta(t, libdrynxencoding.Recall(predicted, actual), evaluation.Recall[1], 1e-12)
+	assert.InDelta(t, libdrynxencoding.Fscore(predicted, actual), evaluation.F1[1], 1e-12)
+
+	// the AUC is the probability for a positive record to score above a negative one, ties counting half
+	pairs, above := 0.0, 0.0
+	for i := range scores {
+		for j := range scores {
+			if actual[i] == 1 && actual[j] == 0 {
+				pairs++
+				if scores[i] > scores[j] {
+					above++
+				} else if scores[i] == scores[j] {
+					above += 0.5
+				}
+			}
+		}
+	}
+	// up to the records in the same bin
+	assert.InDelta(t, above/pairs, evaluation.AUC[1], 2e-2)
+	assert.Len(t, evaluation.TruePositiveRates[1], bins+1)
+	assert.Equal(t, []float64{1, 0}, []float64{evaluation.TruePositiveRates[1][0], evaluation.TruePositiveRates[1][bins]})
+}
+
+// TestClassifierEvaluationFromCounts tests the metrics of a 3 classes confusion matrix
+func TestClassifierEvaluationFromCounts(t *testing.T) {
+	// actual class in rows, predicted in columns, then the histograms of 2 bins of the scores of each class
+	counts := []int64{
+		5, 1, 0,
+		2, 3, 1,
+		0, 0, 4,
+		0, 5, 10, 0,
+		1, 5, 10, 0,
+		0, 4, 12, 0}
+
+	evaluation := libdrynxencoding.ClassifierEvaluationFromCounts(counts, 3, 2)
+	assert.InDelta(t, 12.0/16, evaluation.Accuracy, 1e-12)
+	assert.InDeltaSlice(t, []float64{5.0 / 7, 3.0 / 4, 4.0 / 5}, evaluation.Precision, 1e-12)
+	assert.InDeltaSlice(t, []float64{5.0 / 6, 3.0 / 6, 4.0 / 4}, evaluation.Recall, 1e-12)
+	assert.InDelta(t, 2*(5.0/7)*(5.0/6)/(5.0/7+5.0/6), evaluation.F1[0], 1e-12)
+	// the positive of class 1 in the first bin ties with the negatives
+	assert.InDeltaSlice(t, []float64{1, 11.0 / 12, 1}, evaluation.AUC, 1e-12)
+	assert.Equal(t, []float64{1, 0, 0}, evaluation.FalsePositiveRates[0])
+
+	var back libdrynx.ClassifierEvaluation
+	back.FromFloats(evaluation.ToFloats(), 3, 2)
+	assert.Equal(t, evaluation, back)
+}
+
+// TestEncodeClassifierEvalWithProofs tests that the counts are proven to be at most the number of records
+func TestEncodeClassifierEvalWithProofs(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	pubKey := keys.Public
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 1, Weights: []float64{0, 1}}
+	xData := [][]float64{{-1}, {0.5}, {2}}
+	yData := []int64{0, 1, 1}
+
+	operation := libdrynx.ClassifierEvalOperation(lrParameters, 2)
+	ranges := libdrynx.CountRanges(int64(len(xData)), operation.NbrOutput)
+	ps := make([][]libdrynx.PublishSignature, 2)
+	for j := range ps {
+		ps[j] = make([]libdrynx.PublishSignature, operation.NbrOutput)
+		for i := range ps[j] {
+			ps[j][i] = libdrynxrange.PublishSignatureBytesToPublishSignatures(libdrynxrange.InitRangeProofSignature((*ranges[i])[0]))
+		}
+	}
+
+	_, clear, prf := libdrynxencoding.EncodeClassifierEvalWithProofs(xData, yData, lrParameters, operation.ScoreBins, pubKey, ps, ranges)
+	assert.Equal(t, []int64{1, 0, 0, 2}, clear[:4])
+	for i := range prf {
+		ys := []kyber.Point{ps[0][i].Public, ps[1][i].Public}
+		assert.True(t, libdrynxrange.RangeProofVerification(libdrynxrange.CreatePredicateRangeProofForAllServ(prf[i]), (*ranges[i])[0], (*ranges[i])[1], ys, pubKey))
+	}
+}
+
+// TestCrossValidationStatistics tests the mean and variance of the metrics of the folds of a cross-validation
+func TestCrossValidationStatistics(t *testing.T) {
+	folds := []libdrynx.ClassifierEvaluation{
+		libdrynxencoding.ClassifierEvaluationFromCounts([]int64{3, 1, 0, 4, 0, 1, 3, 0, 1, 0, 0, 3}, 2, 2),
+		libdrynxencoding.ClassifierEvaluationFromCounts([]int64{2, 2, 1, 3, 1, 1, 2, 1, 1, 1, 1, 2}, 2, 2),
+	}
+	mean, variance := libdrynx.CrossValidationStatistics(folds)
+
+	assert.InDelta(t, (7.0/8+5.0/8)/2, mean.Accuracy, 1e-12)
+	assert.InDelta(t, (7.0/8-5.0/8)*(7.0/8-5.0/8)/2, variance.Accuracy, 1e-12)
+	assert.InDeltaSlice(t, []float64{(folds[0].AUC[0] + folds[1].AUC[0]) / 2, (folds[0].AUC[1] + folds[1].AUC[1]) / 2}, mean.AUC, 1e-12)
+	assert.Nil(t, mean.Confusion)
+	assert.Len(t, variance.F1, 2)
+}
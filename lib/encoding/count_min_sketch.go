@@ -0,0 +1,70 @@
+package libdrynxencoding
+
+import (
+	"sort"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+//Note: a count-min sketch of depth d and width w has d rows of w counters, stored row after row. Each element increments
+//one counter per row, given by BloomIndexes, and its count is estimated by the smallest of its counters: it can be
+//overestimated but never underestimated. The sketches of the DPs are summed.
+
+//CountMinSketch computes the count-min sketch of a list of elements
+func CountMinSketch(elements []string, depth int, width int) []int64 {
+	sketch := make([]int64, depth*width)
+	for _, e := range elements {
+		for row, i := range BloomIndexes(e, width, depth) {
+			sketch[row*width+i]++
+		}
+	}
+	return sketch
+}
+
+//EncodeTopK encodes the local count-min sketch
+func EncodeTopK(input []string, depth int, width int, pubKey kyber.Point) ([]libunlynx.CipherText, []int64) {
+	ciphers, clears, _ := EncodeTopKWithProofs(input, depth, width, pubKey, nil, nil)
+	return ciphers, clears
+}
+
+//EncodeTopKWithProofs encodes the local count-min sketch with range proofs, e.g. for each counter to be at most the
+//number of records of the DP (see libdrynx.CountRanges)
+func EncodeTopKWithProofs(input []string, depth int, width int, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	sketch := CountMinSketch(input, depth, width)
+	ciphertextTuples, createRangeProof := encryptWithRangeProofs(sketch, pubKey, sigs, lu)
+	return ciphertextTuples, sketch, createRangeProof
+}
+
+//DecodeTopK decodes the global count-min sketch and estimates the counts of the candidates
+func DecodeTopK(result []libunlynx.CipherText, secKey kyber.Scalar, depth int, candidates []string) []int64 {
+	sketch := DecodeFreqCount(result, secKey)
+	width := len(sketch) / depth
+
+	counts := make([]int64, len(candidates))
+	for c, e := range candidates {
+		for row, i := range BloomIndexes(e, width, depth) {
+			if row == 0 || sketch[row*width+i] < counts[c] {
+				counts[c] = sketch[row*width+i]
+			}
+		}
+	}
+	return counts
+}
+
+//TopK ranks estimated counts in decreasing order and gives the indexes of the first k
+func TopK(counts []int64, k int) []int {
+	order := make([]int, len(counts))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return counts[order[i]] > counts[order[j]]
+	})
+	if k > len(order) {
+		k = len(order)
+	}
+	return order[:k]
+}
@@ -0,0 +1,79 @@
+package libdrynxencoding_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+//TestEncodeDecodeTopK tests the estimated counts and the ranking of the candidates from the count-min sketches of DPs
+func TestEncodeDecodeTopK(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	depth, width := 4, 64
+
+	// "flu" appears 3 times per DP, "cold" twice and the other diagnoses once
+	dps := make([][]string, 3)
+	for i := range dps {
+		dps[i] = []string{"flu", "flu", "flu", "cold", "cold"}
+		for j := 0; j < 10; j++ {
+			dps[i] = append(dps[i], fmt.Sprintf("diagnosis-%d-%d", i, j))
+		}
+	}
+
+	var result []libunlynx.CipherText
+	for _, dp := range dps {
+		ciphers, _ := libdrynxencoding.EncodeTopK(dp, depth, width, pubKey)
+		if result == nil {
+			result = ciphers
+			continue
+		}
+		for i := range result {
+			result[i].Add(result[i], ciphers[i])
+		}
+	}
+
+	candidates := []string{"asthma", "cold", "flu", "diagnosis-0-0"}
+	counts := libdrynxencoding.DecodeTopK(result, secKey, depth, candidates)
+	assert.Equal(t, int64(9), counts[2])
+	assert.Equal(t, int64(6), counts[1])
+	assert.True(t, counts[3] >= 1)
+	assert.Equal(t, []int{2, 1}, libdrynxencoding.TopK(counts, 2))
+
+	// the same through Decode: the index of each of the top-k candidates followed by its count
+	operation := libdrynx.ChooseOperation("topK", 0, 0, width, 0)
+	operation.TopK = 2
+	operation.Candidates = candidates
+	assert.Equal(t, []float64{2, 9, 1, 6}, libdrynxencoding.Decode(result, secKey, operation))
+}
+
+//TestEncodeTopKWithProofs tests that the counters of a count-min sketch are proven to be at most the number of records
+func TestEncodeTopKWithProofs(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	pubKey := keys.Public
+	depth, width := 2, 4
+	input := []string{"a", "b", "a", "c", "a"}
+
+	ranges := libdrynx.CountRanges(int64(len(input)), depth*width)
+	ps := make([][]libdrynx.PublishSignature, 2)
+	for j := range ps {
+		ps[j] = make([]libdrynx.PublishSignature, depth*width)
+		for i := range ps[j] {
+			ps[j][i] = libdrynxrange.PublishSignatureBytesToPublishSignatures(libdrynxrange.InitRangeProofSignature((*ranges[i])[0]))
+		}
+	}
+
+	_, clear, prf := libdrynxencoding.EncodeTopKWithProofs(input, depth, width, pubKey, ps, ranges)
+	for i := range prf {
+		assert.True(t, clear[i] <= int64(len(input)))
+		ys := []kyber.Point{ps[0][i].Public, ps[1][i].Public}
+		assert.True(t, libdrynxrange.RangeProofVerification(libdrynxrange.CreatePredicateRangeProofForAllServ(prf[i]), (*ranges[i])[0], (*ranges[i])[1], ys, pubKey))
+	}
+}
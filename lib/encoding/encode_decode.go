@@ -51,6 +51,21 @@ func Encode(datas [][]int64, pubKey kyber.Point, signatures [][]libdrynx.Publish
 			encryptedResponse, clearResponse = EncodeVariance(datas[0], pubKey)
 		}
 		break
+	case "pca":
+		// the records of the dimensions
+		records := make([][]int64, len(datas[0]))
+		for i := range records {
+			records[i] = make([]int64, len(datas))
+			for j := range datas {
+				records[i][j] = datas[j][i]
+			}
+		}
+		if withProofs {
+			encryptedResponse, clearResponse, createPrf = EncodePCAWithProofs(records, pubKey, signatures, ranges)
+		} else {
+			encryptedResponse, clearResponse = EncodePCA(records, pubKey)
+		}
+		break
 	case "lin_reg":
 		d := len(datas)
 		numbValues := len(datas[0])
@@ -65,12 +80,26 @@ func Encode(datas [][]int64, pubKey kyber.Point, signatures [][]libdrynx.Publish
 			dataYS[j] = datas[d-1][j]
 		}
 
-		if withProofs {
-			encryptedResponse, clearResponse, createPrf = EncodeLinearRegressionDimsWithProofs(dataDimensions, dataYS, pubKey, signatures, ranges)
+		// the sum of the squares of y is the last value
+		statistics := operation.LinRegParameters.Statistics
+		if withProofs && statistics {
+			last := len(ranges) - 1
+			sigsDims := make([][]libdrynx.PublishSignature, len(signatures))
+			for i := range signatures {
+				sigsDims[i] = signatures[i][:last]
+			}
+			encryptedResponse, clearResponse, createPrf = EncodeLinearRegressionDimsWithProofs(dataDimensions, dataYS, pubKey, sigsDims, ranges[:last])
+			cipher, _, prf := EncodeSumSquaresWithProofs(dataYS, pubKey, libdrynxrange.ReadColumn(signatures, last), (*ranges[last])[1], (*ranges[last])[0])
+			encryptedResponse = append(encryptedResponse, *cipher)
+			createPrf = append(createPrf, prf...)
+		} else if withProofs {
 			encryptedResponse, clearResponse, createPrf = EncodeLinearRegressionDimsWithProofs(dataDimensions, dataYS, pubKey, signatures, ranges)
-
 		} else {
 			encryptedResponse, clearResponse = EncodeLinearRegressionDims(dataDimensions, dataYS, pubKey)
+			if statistics {
+				cipher, _, _ := EncodeSumSquaresWithProofs(dataYS, pubKey, nil, 0, 0)
+				encryptedResponse = append(encryptedResponse, *cipher)
+			}
 		}
 		break
 	case "frequencyCount":
@@ -120,7 +149,9 @@ func Encode(datas [][]int64, pubKey kyber.Point, signatures [][]libdrynx.Publish
 		break
 
 	case "min":
-		if withProofs {
+		if operation.MinMaxRound {
+			encryptedResponse, clearResponse, createPrf = encodeMinMaxRound(datas[0], pubKey, signatures, ranges, operation)
+		} else if withProofs {
 			encryptedResponse, clearResponse, createPrf = EncodeMinWithProofs(datas[0], operation.QueryMax, operation.QueryMin, pubKey, signatures, ranges)
 		} else {
 			encryptedResponse, clearResponse = EncodeMin(datas[0], operation.QueryMax, operation.QueryMin, pubKey)
@@ -129,7 +160,9 @@ func Encode(datas [][]int64, pubKey kyber.Point, signatures [][]libdrynx.Publish
 		break
 
 	case "max":
-		if withProofs {
+		if operation.MinMaxRound {
+			encryptedResponse, clearResponse, createPrf = encodeMinMaxRound(datas[0], pubKey, signatures, ranges, operation)
+		} else if withProofs {
 			encryptedResponse, clearResponse, createPrf = EncodeMaxWithProofs(datas[0], operation.QueryMax, operation.QueryMin, pubKey, signatures, ranges)
 		} else {
 			encryptedResponse, clearResponse = EncodeMax(datas[0], operation.QueryMax, operation.QueryMin, pubKey)
@@ -149,6 +182,31 @@ func Encode(datas [][]int64, pubKey kyber.Point, signatures [][]libdrynx.Publish
 		} else {
 			encryptedResponse, clearResponse = EncodeInter(datas[0], operation.QueryMin, operation.QueryMax, pubKey)
 		}
+	case "bloom_union":
+		if withProofs {
+			encryptedResponse, clearResponse, createPrf = EncodeBloomUnionWithProofs(Elements(datas[0]), operation.NbrOutput, operation.BloomHashes, pubKey, signatures, ranges)
+		} else {
+			encryptedResponse, clearResponse = EncodeBloomUnion(Elements(datas[0]), operation.NbrOutput, operation.BloomHashes, pubKey)
+		}
+	case "bloom_inter":
+		if withProofs {
+			encryptedResponse, clearResponse, createPrf = EncodeBloomInterWithProofs(Elements(datas[0]), operation.NbrOutput, operation.BloomHashes, pubKey, signatures, ranges)
+		} else {
+			encryptedResponse, clearResponse = EncodeBloomInter(Elements(datas[0]), operation.NbrOutput, operation.BloomHashes, pubKey)
+		}
+	case "distinctCount":
+		if withProofs {
+			encryptedResponse, clearResponse, createPrf = EncodeDistinctCountWithProofs(Elements(datas[0]), operation.HLLPrecision, pubKey, signatures, ranges)
+		} else {
+			encryptedResponse, clearResponse = EncodeDistinctCount(Elements(datas[0]), operation.HLLPrecision, pubKey)
+		}
+	case "topK":
+		width := operation.NbrOutput / operation.SketchDepth
+		if withProofs {
+			encryptedResponse, clearResponse, createPrf = EncodeTopKWithProofs(Elements(datas[0]), operation.SketchDepth, width, pubKey, signatures, ranges)
+		} else {
+			encryptedResponse, clearResponse = EncodeTopK(Elements(datas[0]), operation.SketchDepth, width, pubKey)
+		}
 	case "MLeval":
 		if withProofs {
 			encryptedResponse, clearResponse, createPrf = EncodeModelEvaluationWithProofs(datas[0], datas[1], pubKey, signatures, ranges)
@@ -171,7 +229,23 @@ func Decode(ciphers []libunlynx.CipherText, secKey kyber.Scalar, operation libdr
 	case "variance":
 		return []float64{DecodeVariance(ciphers, secKey)}
 	case "lin_reg":
-		return DecodeLinearRegressionDims(ciphers, secKey)
+		parameters := operation.LinRegParameters
+		if parameters.Lambda == 0 && !parameters.Statistics {
+			return DecodeLinearRegressionDims(ciphers, secKey)
+		}
+		result, err := DecodeLinearRegression(ciphers, secKey, parameters)
+		if err != nil {
+			log.Error(err)
+			return nil
+		}
+		return result.ToFloats()
+	case "pca":
+		result, err := DecodePCA(ciphers, secKey, operation.PCAParameters)
+		if err != nil {
+			log.Error(err)
+			return nil
+		}
+		return result.ToFloats()
 	case "frequencyCount":
 		freqCount := DecodeFreqCount(ciphers, secKey)
 		result := make([]float64, len(freqCount))
@@ -179,9 +253,17 @@ func Decode(ciphers []libunlynx.CipherText, secKey kyber.Scalar, operation libdr
 			result[i] = float64(freqCount[i])
 		}
 		return result
-	case "min":
-		return []float64{float64(DecodeMin(ciphers, operation.QueryMin, secKey))}
-	case "max":
+	case "min", "max":
+		if operation.MinMaxRound {
+			result := float64(0)
+			if DecodeBitOR(ciphers[0], secKey) {
+				result = float64(1)
+			}
+			return []float64{result}
+		}
+		if operation.NameOp == "min" {
+			return []float64{float64(DecodeMin(ciphers, operation.QueryMin, secKey))}
+		}
 		return []float64{float64(DecodeMax(ciphers, operation.QueryMin, secKey))}
 	case "bool_AND":
 		boolResult := DecodeBitAND(ciphers[0], secKey)
@@ -211,12 +293,50 @@ func Decode(ciphers []libunlynx.CipherText, secKey kyber.Scalar, operation libdr
 			result[i] = float64(interSet[i])
 		}
 		return result
+	case "bloom_union":
+		// the global Bloom filter, see BloomCardinality and BloomMembers
+		return bloomFilterResult(DecodeUnion(ciphers, secKey))
+	case "bloom_inter":
+		return bloomFilterResult(DecodeInter(ciphers, secKey))
+	case "distinctCount":
+		estimate, stdErr := DecodeDistinctCount(ciphers, secKey)
+		return []float64{estimate, stdErr}
+	case "topK":
+		// the TopK candidates (all if it is 0) with the largest estimated counts, as their index in Candidates followed
+		// by their count
+		k := operation.TopK
+		if k == 0 {
+			k = len(operation.Candidates)
+		}
+		counts := DecodeTopK(ciphers, secKey, operation.SketchDepth, operation.Candidates)
+		result := make([]float64, 0, 2*k)
+		for _, i := range TopK(counts, k) {
+			result = append(result, float64(i), float64(counts[i]))
+		}
+		return result
 	case "logistic regression":
 		lrParameters := operation.LRParameters
+		if lrParameters.StandardisationRound {
+			return DecodeLogisticRegressionStandardisation(ciphers, secKey, lrParameters)
+		}
+		if lrParameters.GradientRound {
+			return DecodeLogisticRegressionGradient(ciphers, secKey, lrParameters)
+		}
+		if libdrynx.LogisticRegressionClasses(lrParameters) > 1 {
+			return DecodeOneVsRestLogisticRegression(ciphers, secKey, lrParameters)
+		}
 		return DecodeLogisticRegression(ciphers, secKey, lrParameters)
 
 	case "MLeval":
 		return []float64{DecodeModelEvaluation(ciphers, secKey)}
+	case "classifierEval":
+		evaluation := DecodeClassifierEval(ciphers, secKey, libdrynx.ClassifierClasses(operation.LRParameters), operation.ScoreBins)
+		return evaluation.ToFloats()
+	case "kmeans":
+		return DecodeKMeans(ciphers, secKey, operation.LRParameters, operation.KMParameters)
+	case "naiveBayes":
+		model := DecodeNaiveBayes(ciphers, secKey, operation.LRParameters, operation.NBParameters)
+		return model.ToFloats()
 
 	default:
 		log.Info("no such operation:", operation)
@@ -230,18 +350,56 @@ func Decode(ciphers []libunlynx.CipherText, secKey kyber.Scalar, operation libdr
 	}
 }
 
-// EncodeForFloat encodes floating points
+// EncodeForFloat encodes floating points, the records of an operation for which libdrynx.FeatureOperation holds
 func EncodeForFloat(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, pubKey kyber.Point,
-	signatures [][]libdrynx.PublishSignature, ranges []*[]int64, operation string) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof, error) {
+	signatures [][]libdrynx.PublishSignature, ranges []*[]int64, operation libdrynx.Operation) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof, error) {
 
 	clearResponse := make([]int64, 0)
 	encryptedResponse := make([]libunlynx.CipherText, 0)
 	prf := make([]libdrynxrange.CreateProof, 0)
 	withProofs := len(ranges) > 0
-	switch operation {
-	case "logistic regression":
+	switch operation.NameOp {
+	case "classifierEval":
+		if withProofs {
+			encryptedResponse, clearResponse, prf = EncodeClassifierEvalWithProofs(xData, yData, lrParameters, operation.ScoreBins, pubKey, signatures, ranges)
+		} else {
+			encryptedResponse, clearResponse = EncodeClassifierEval(xData, yData, lrParameters, operation.ScoreBins, pubKey)
+		}
+	case "kmeans":
 		var err error
 		if withProofs {
+			encryptedResponse, clearResponse, prf, err = EncodeKMeansWithProofs(xData, lrParameters, operation.KMParameters, pubKey, signatures, ranges)
+		} else {
+			encryptedResponse, clearResponse, err = EncodeKMeans(xData, lrParameters, operation.KMParameters, pubKey)
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("when encoding response: %w", err)
+		}
+	case "naiveBayes":
+		var err error
+		if withProofs {
+			encryptedResponse, clearResponse, prf, err = EncodeNaiveBayesWithProofs(xData, yData, lrParameters, operation.NBParameters, pubKey, signatures, ranges)
+		} else {
+			encryptedResponse, clearResponse, err = EncodeNaiveBayes(xData, yData, lrParameters, operation.NBParameters, pubKey)
+		}
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("when encoding response: %w", err)
+		}
+	case "logistic regression":
+		var err error
+		if lrParameters.StandardisationRound && withProofs {
+			encryptedResponse, clearResponse, prf = EncodeLogisticRegressionStandardisationWithProofs(xData, lrParameters, pubKey, signatures, ranges)
+		} else if lrParameters.StandardisationRound {
+			encryptedResponse, clearResponse = EncodeLogisticRegressionStandardisation(xData, lrParameters, pubKey)
+		} else if lrParameters.GradientRound && withProofs {
+			encryptedResponse, clearResponse, prf, err = EncodeLogisticRegressionGradientWithProofs(xData, yData, lrParameters, pubKey, signatures, ranges)
+		} else if lrParameters.GradientRound {
+			encryptedResponse, clearResponse, err = EncodeLogisticRegressionGradient(xData, yData, lrParameters, pubKey)
+		} else if libdrynx.LogisticRegressionClasses(lrParameters) > 1 && withProofs {
+			encryptedResponse, clearResponse, prf, err = EncodeOneVsRestLogisticRegressionWithProofs(xData, yData, lrParameters, pubKey, signatures, ranges)
+		} else if libdrynx.LogisticRegressionClasses(lrParameters) > 1 {
+			encryptedResponse, clearResponse, err = EncodeOneVsRestLogisticRegression(xData, yData, lrParameters, pubKey)
+		} else if withProofs {
 			encryptedResponse, clearResponse, prf, err = EncodeLogisticRegressionWithProofs(xData, yData, lrParameters, pubKey, signatures, ranges)
 		} else {
 			encryptedResponse, clearResponse, err = EncodeLogisticRegression(xData, yData, lrParameters, pubKey)
@@ -252,3 +410,30 @@ func EncodeForFloat(xData [][]float64, yData []int64, lrParameters libdrynx.Logi
 	}
 	return encryptedResponse, clearResponse, prf, nil
 }
+
+// encryptWithRangeProofs encrypts values in parallel and, if there are signatures, creates the range proof of each
+// value i in [0, u^l) with lu[i] = [u, l]
+func encryptWithRangeProofs(values []int64, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []libdrynxrange.CreateProof) {
+	r := make([]kyber.Scalar, len(values))
+	ciphertextTuples := make([]libunlynx.CipherText, len(values))
+	wg := libunlynx.StartParallelize(len(values))
+	for i := range values {
+		go func(i int) {
+			defer wg.Done()
+			encrypted, ri := libunlynx.EncryptIntGetR(pubKey, values[i])
+			r[i] = ri
+			ciphertextTuples[i] = *encrypted
+		}(i)
+	}
+	libunlynx.EndParallelize(wg)
+
+	if sigs == nil {
+		return ciphertextTuples, nil
+	}
+
+	createRangeProof := make([]libdrynxrange.CreateProof, len(values))
+	for i, v := range values {
+		createRangeProof[i] = libdrynxrange.CreateProof{Sigs: libdrynxrange.ReadColumn(sigs, i), U: (*lu[i])[0], L: (*lu[i])[1], Secret: v, R: r[i], CaPub: pubKey, Cipher: ciphertextTuples[i]}
+	}
+	return ciphertextTuples, createRangeProof
+}
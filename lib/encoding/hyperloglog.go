@@ -0,0 +1,117 @@
+package libdrynxencoding
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+//Note: with a precision p, the first p bits of the hash of an element select one of the 2^p HyperLogLog registers and
+//the rank of the first 1-bit of the remaining bits is kept in the register if it is larger. Each register is encoded as
+//a max over [0, HLLMaxRank] so that the aggregation of the DPs gives the registers of the union of their sets.
+
+//HLLRegisters computes the HyperLogLog registers of a set of elements
+func HLLRegisters(elements []string, p int) []int64 {
+	registers := make([]int64, 1<<uint(p))
+	for _, e := range elements {
+		hash := sha256.Sum256([]byte(e))
+		h := binary.BigEndian.Uint64(hash[:8])
+
+		rank := int64(bits.LeadingZeros64(h<<uint(p)) + 1)
+		if rank > 64-int64(p)+1 {
+			rank = 64 - int64(p) + 1
+		}
+		if rank > libdrynx.HLLMaxRank {
+			rank = libdrynx.HLLMaxRank
+		}
+
+		index := h >> uint(64-p)
+		if rank > registers[index] {
+			registers[index] = rank
+		}
+	}
+	return registers
+}
+
+//EncodeDistinctCount encodes the local HyperLogLog registers
+func EncodeDistinctCount(input []string, p int, pubKey kyber.Point) ([]libunlynx.CipherText, []int64) {
+	ciphers, clears, _ := EncodeDistinctCountWithProofs(input, p, pubKey, nil, nil)
+	return ciphers, clears
+}
+
+//EncodeDistinctCountWithProofs encodes the local HyperLogLog registers with range proofs
+func EncodeDistinctCountWithProofs(input []string, p int, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	size := libdrynx.HLLMaxRank + 1
+	registers := HLLRegisters(input, p)
+
+	ciphertextTuples := make([]libunlynx.CipherText, 0, len(registers)*size)
+	cleartextTuples := make([]int64, 0, len(registers)*size)
+	proofsTuples := make([]libdrynxrange.CreateProof, 0, len(registers)*size)
+	for i, r := range registers {
+		if sigs != nil {
+			registerSigs := make([][]libdrynx.PublishSignature, len(sigs))
+			for j := range sigs {
+				registerSigs[j] = sigs[j][i*size : (i+1)*size]
+			}
+			ciphers, clears, prfs := EncodeMaxWithProofs([]int64{r}, libdrynx.HLLMaxRank, 0, pubKey, registerSigs, lu[i*size:(i+1)*size])
+			ciphertextTuples = append(ciphertextTuples, ciphers...)
+			cleartextTuples = append(cleartextTuples, clears...)
+			proofsTuples = append(proofsTuples, prfs...)
+		} else {
+			ciphers, clears := EncodeMax([]int64{r}, libdrynx.HLLMaxRank, 0, pubKey)
+			ciphertextTuples = append(ciphertextTuples, ciphers...)
+			cleartextTuples = append(cleartextTuples, clears...)
+		}
+	}
+	return ciphertextTuples, cleartextTuples, proofsTuples
+}
+
+//DecodeDistinctCount decodes the global HyperLogLog registers and gives the estimate of the number of distinct elements
+//with its standard error
+func DecodeDistinctCount(result []libunlynx.CipherText, secKey kyber.Scalar) (float64, float64) {
+	size := libdrynx.HLLMaxRank + 1
+	registers := make([]int64, len(result)/size)
+	for i := range registers {
+		registers[i] = DecodeMax(result[i*size:(i+1)*size], 0, secKey)
+	}
+	return HLLEstimate(registers)
+}
+
+//HLLEstimate gives the estimate of the number of distinct elements from HyperLogLog registers, with linear counting for
+//small cardinalities, and its standard error 1.04/sqrt(m)
+func HLLEstimate(registers []int64) (float64, float64) {
+	m := float64(len(registers))
+
+	var alpha float64
+	switch len(registers) {
+	case 16:
+		alpha = 0.673
+	case 32:
+		alpha = 0.697
+	case 64:
+		alpha = 0.709
+	default:
+		alpha = 0.7213 / (1 + 1.079/m)
+	}
+
+	sum := 0.0
+	zeros := 0
+	for _, r := range registers {
+		sum += math.Pow(2, -float64(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return estimate, estimate * 1.04 / math.Sqrt(m)
+}
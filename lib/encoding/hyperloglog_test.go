@@ -0,0 +1,46 @@
+package libdrynxencoding_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+//TestEncodeDecodeDistinctCount tests the estimate of the number of distinct elements of overlapping sets
+func TestEncodeDecodeDistinctCount(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	p := 6
+
+	// the sets of the DPs overlap by half: 2000 distinct elements
+	sets := make([][]string, 3)
+	for i := range sets {
+		for j := 0; j < 1000; j++ {
+			sets[i] = append(sets[i], fmt.Sprintf("patient-%d", i*500+j))
+		}
+	}
+
+	var result []libunlynx.CipherText
+	for _, set := range sets {
+		ciphers, _ := libdrynxencoding.EncodeDistinctCount(set, p, pubKey)
+		if result == nil {
+			result = ciphers
+			continue
+		}
+		for i := range result {
+			result[i].Add(result[i], ciphers[i])
+		}
+	}
+
+	estimate, stdErr := libdrynxencoding.DecodeDistinctCount(result, secKey)
+	assert.InDelta(t, 2000, estimate, 3*stdErr)
+	assert.InDelta(t, 2000*1.04/8, stdErr, 100)
+
+	// small cardinalities are estimated by linear counting
+	estimate, _ = libdrynxencoding.HLLEstimate(libdrynxencoding.HLLRegisters(sets[0][:5], p))
+	assert.InDelta(t, 5, estimate, 1)
+}
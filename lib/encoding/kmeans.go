@@ -0,0 +1,127 @@
+package libdrynxencoding
+
+import (
+	"errors"
+	"math"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+//Note: in a round of kmeans, a DP assigns each of its records to the nearest of the current centroids and sends, for
+//each cluster c, at c*(2*features+1), the number of its records in the cluster followed by the sums of their positive
+//coordinates and then of the absolute values of their negative ones (so that both can be range proven), scaled to
+//integers by the precision. The counts and sums of the DPs are summed.
+
+// kmeansPrecision gives the scale of the coordinates to integers
+func kmeansPrecision(kmParameters libdrynx.KMeansParameters) float64 {
+	if kmParameters.Precision == 0 {
+		return libdrynx.KMeansDefaultPrecision
+	}
+	return kmParameters.Precision
+}
+
+// KMeansCentroidMatrix gives the rows of the flattened centroids, of the given number of features each
+func KMeansCentroidMatrix(centroids []float64, features int) [][]float64 {
+	matrix := make([][]float64, len(centroids)/features)
+	for c := range matrix {
+		matrix[c] = centroids[c*features : (c+1)*features]
+	}
+	return matrix
+}
+
+// AssignCluster gives the index of the centroid nearest to a record, by Euclidean distance
+func AssignCluster(record []float64, centroids [][]float64) int {
+	nearest, best := 0, math.Inf(1)
+	for c, centroid := range centroids {
+		distance := 0.0
+		for j, x := range record {
+			distance += (x - centroid[j]) * (x - centroid[j])
+		}
+		if distance < best {
+			nearest, best = c, distance
+		}
+	}
+	return nearest
+}
+
+// EncodeKMeans encodes the number of local records and the sums of their coordinates in each cluster
+func EncodeKMeans(xData [][]float64, lrParameters libdrynx.LogisticRegressionParameters, kmParameters libdrynx.KMeansParameters, pubKey kyber.Point) ([]libunlynx.CipherText, []int64, error) {
+	ciphers, clears, _, err := EncodeKMeansWithProofs(xData, lrParameters, kmParameters, pubKey, nil, nil)
+	return ciphers, clears, err
+}
+
+// EncodeKMeansWithProofs encodes the number of local records and the sums of their coordinates in each cluster with
+// range proofs
+func EncodeKMeansWithProofs(xData [][]float64, lrParameters libdrynx.LogisticRegressionParameters, kmParameters libdrynx.KMeansParameters, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof, error) {
+	d := int(lrParameters.NbrFeatures)
+	if d == 0 || len(kmParameters.Centroids) != kmParameters.K*d {
+		return nil, nil, nil, errors.New("the centroids do not match the number of clusters and features")
+	}
+	precision := kmeansPrecision(kmParameters)
+	centroids := KMeansCentroidMatrix(kmParameters.Centroids, d)
+
+	clears := make([]int64, kmParameters.K*(2*d+1))
+	for _, record := range xData {
+		c := AssignCluster(record[:d], centroids)
+		cluster := clears[c*(2*d+1) : (c+1)*(2*d+1)]
+		cluster[0]++
+		for j := 0; j < d; j++ {
+			x := int64(math.Round(record[j] * precision))
+			if x >= 0 {
+				cluster[1+j] += x
+			} else {
+				cluster[1+d+j] -= x
+			}
+		}
+	}
+
+	ciphers, createRangeProof := encryptWithRangeProofs(clears, pubKey, sigs, lu)
+	return ciphers, clears, createRangeProof, nil
+}
+
+// DecodeKMeans decodes the global number of records and sums of their coordinates in each cluster, rescaled to the
+// coordinates: for each cluster c, at c*(features+1), the number of records followed by the sums
+func DecodeKMeans(result []libunlynx.CipherText, secKey kyber.Scalar, lrParameters libdrynx.LogisticRegressionParameters, kmParameters libdrynx.KMeansParameters) []float64 {
+	precision := kmeansPrecision(kmParameters)
+	d := int(lrParameters.NbrFeatures)
+	values := DecodeFreqCount(result, secKey)
+
+	k := len(values) / (2*d + 1)
+	decoded := make([]float64, k*(d+1))
+	for c := 0; c < k; c++ {
+		cluster := values[c*(2*d+1) : (c+1)*(2*d+1)]
+		decoded[c*(d+1)] = float64(cluster[0])
+		for j := 0; j < d; j++ {
+			decoded[c*(d+1)+1+j] = float64(cluster[1+j]-cluster[1+d+j]) / precision
+		}
+	}
+	return decoded
+}
+
+// KMeansStep moves each centroid to the mean of the records of its cluster, given by the decoded counts and sums of a
+// round; the centroid of an empty cluster does not move. It gives the new centroids, the number of records of each
+// cluster and the largest move of a centroid.
+func KMeansStep(centroids []float64, sums []float64, features int) ([]float64, []float64, float64) {
+	k := len(centroids) / features
+	updated := append([]float64(nil), centroids...)
+	sizes := make([]float64, k)
+	shift := 0.0
+	for c := 0; c < k; c++ {
+		n := sums[c*(features+1)]
+		sizes[c] = n
+		// noise can make a small cluster look empty
+		if n <= 0 {
+			continue
+		}
+		move := 0.0
+		for j := 0; j < features; j++ {
+			updated[c*features+j] = sums[c*(features+1)+1+j] / n
+			move += (updated[c*features+j] - centroids[c*features+j]) * (updated[c*features+j] - centroids[c*features+j])
+		}
+		shift = math.Max(shift, math.Sqrt(move))
+	}
+	return updated, sizes, shift
+}
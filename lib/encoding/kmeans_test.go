@@ -0,0 +1,112 @@
+package libdrynxencoding_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+// TestEncodeDecodeKMeans runs rounds of k-means over the encrypted counts and sums of 3 DPs, whose records are around
+// (0, 0) and (5, 5)
+func TestEncodeDecodeKMeans(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	rng := rand.New(rand.NewSource(3))
+
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 2}
+	kmParameters := libdrynx.KMeansParameters{K: 2, Centroids: []float64{1, 0, 2, 3}}
+	xs := make([][][]float64, 3)
+	for dp := range xs {
+		for i := 0; i < 10; i++ {
+			center := float64(5 * (i % 2))
+			xs[dp] = append(xs[dp], []float64{center + rng.Float64() - 0.5, center + rng.Float64() - 0.5})
+		}
+	}
+
+	operation := libdrynx.KMeansOperation(lrParameters, kmParameters)
+	shift := 0.0
+	var sizes []float64
+	for round := 0; round < 3; round++ {
+		var result []libunlynx.CipherText
+		for dp := range xs {
+			ciphers, _, err := libdrynxencoding.EncodeKMeans(xs[dp], lrParameters, operation.KMParameters, pubKey)
+			assert.NoError(t, err)
+			if result == nil {
+				result = ciphers
+			} else {
+				for i := range result {
+					result[i].Add(result[i], ciphers[i])
+				}
+			}
+		}
+		assert.Len(t, result, operation.NbrOutput)
+		operation.KMParameters.Centroids, sizes, shift = libdrynxencoding.KMeansStep(operation.KMParameters.Centroids, libdrynxencoding.Decode(result, secKey, operation), 2)
+	}
+
+	// the clusters are found in the first round and do not move after
+	assert.Equal(t, []float64{15, 15}, sizes)
+	assert.Equal(t, 0.0, shift)
+	centroids := libdrynxencoding.KMeansCentroidMatrix(operation.KMParameters.Centroids, 2)
+	assert.InDeltaSlice(t, []float64{0, 0}, centroids[0], 0.3)
+	assert.InDeltaSlice(t, []float64{5, 5}, centroids[1], 0.3)
+	assert.Equal(t, 1, libdrynxencoding.AssignCluster([]float64{4, 6}, centroids))
+
+	var back libdrynx.KMeansResult
+	kmr := libdrynx.KMeansResult{Centroids: centroids, Sizes: sizes, Iterations: 3}
+	back.FromFloats(kmr.ToFloats(), 2, 2)
+	assert.Equal(t, kmr, back)
+}
+
+// TestKMeansStep tests that the centroid of an empty cluster does not move
+func TestKMeansStep(t *testing.T) {
+	centroids := []float64{0, 0, 1, 1}
+	sums := []float64{2, 6, 8, 0, 0, 0}
+
+	updated, sizes, shift := libdrynxencoding.KMeansStep(centroids, sums, 2)
+	assert.Equal(t, []float64{3, 4, 1, 1}, updated)
+	assert.Equal(t, []float64{2, 0}, sizes)
+	assert.Equal(t, 5.0, shift)
+	assert.Equal(t, []float64{0, 0, 1, 1}, centroids)
+}
+
+// TestEncodeKMeansWithProofs tests the range proofs of the counts and sums of the clusters, whose negative coordinates
+// are summed apart
+func TestEncodeKMeansWithProofs(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	pubKey := keys.Public
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 1}
+	kmParameters := libdrynx.KMeansParameters{K: 2, Centroids: []float64{0, 2}}
+	xData := [][]float64{{0.2}, {1.5}, {2.5}, {-0.3}}
+
+	operation := libdrynx.KMeansOperation(lrParameters, kmParameters)
+	ranges := make([]*[]int64, operation.NbrOutput)
+	ps := make([][]libdrynx.PublishSignature, 2)
+	for i := range ranges {
+		ranges[i] = &[]int64{16, 2}
+	}
+	for j := range ps {
+		ps[j] = make([]libdrynx.PublishSignature, operation.NbrOutput)
+		for i := range ps[j] {
+			ps[j][i] = libdrynxrange.PublishSignatureBytesToPublishSignatures(libdrynxrange.InitRangeProofSignature((*ranges[i])[0]))
+		}
+	}
+
+	_, clear, prf, err := libdrynxencoding.EncodeKMeansWithProofs(xData, lrParameters, kmParameters, pubKey, ps, ranges)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 2, 3, 2, 40, 0}, clear)
+	assert.Len(t, prf, operation.NbrOutput)
+	for i := range prf {
+		ys := []kyber.Point{ps[0][i].Public, ps[1][i].Public}
+		assert.True(t, libdrynxrange.RangeProofVerification(libdrynxrange.CreatePredicateRangeProofForAllServ(prf[i]), (*ranges[i])[0], (*ranges[i])[1], ys, pubKey))
+	}
+
+	_, _, err = libdrynxencoding.EncodeKMeans(xData, lrParameters, libdrynx.KMeansParameters{K: 3, Centroids: []float64{0, 2}}, pubKey)
+	assert.Error(t, err)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/ldsec/unlynx/lib"
 	"github.com/tonestuff/quadratic"
 	"go.dedis.ch/kyber/v3"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
 	"math"
 	"time"
 )
@@ -161,6 +163,93 @@ func DecodeLinearRegressionDims(result []libunlynx.CipherText, secKey kyber.Scal
 	return coeffs
 }
 
+//DecodeLinearRegression computes the least-squares (ridge if lambda is set) coefficients from the sums sent by the DPs
+//and, if the sum of the squares of y is the last of them, the standard errors, t-statistics and p-values of the
+//coefficients as well as the R² and adjusted R². The intercept is not penalized. With a ridge penalty, the statistics
+//use the effective degrees of freedom tr(H) of the fit.
+func DecodeLinearRegression(result []libunlynx.CipherText, secKey kyber.Scalar, parameters libdrynx.LinearRegressionParameters) (libdrynx.LinearRegressionResult, error) {
+	values := make([]float64, len(result))
+	for i, v := range result {
+		values[i] = float64(libunlynx.DecryptIntWithNeg(secKey, v))
+	}
+	sumY2 := float64(0)
+	if parameters.Statistics {
+		sumY2 = values[len(values)-1]
+		values = values[:len(values)-1]
+	}
+
+	//get the number of dimensions from d^2 + 5d + 4 = 2*len(values)
+	d := int(math.Round((math.Sqrt(float64(9+8*len(values))) - 5) / 2))
+	if (d*d+5*d+4)/2 != len(values) {
+		return libdrynx.LinearRegressionResult{}, fmt.Errorf("%d values are not the sums of a linear regression", len(values))
+	}
+
+	//X^T X and X^T y, with X having a first column of ones
+	xx := mat.NewSymDense(d+1, nil)
+	xy := mat.NewVecDense(d+1, nil)
+	index := 0
+	for j := 0; j <= d; j++ {
+		xx.SetSym(0, j, values[index])
+		index++
+	}
+	for j := 1; j <= d; j++ {
+		for k := j; k <= d; k++ {
+			xx.SetSym(j, k, values[index])
+			index++
+		}
+	}
+	for j := 0; j <= d; j++ {
+		xy.SetVec(j, values[index])
+		index++
+	}
+
+	penalized := mat.NewDense(d+1, d+1, nil)
+	penalized.Copy(xx)
+	for j := 1; j <= d; j++ {
+		penalized.Set(j, j, penalized.At(j, j)+parameters.Lambda)
+	}
+	var inverse mat.Dense
+	if err := inverse.Inverse(penalized); err != nil {
+		return libdrynx.LinearRegressionResult{}, fmt.Errorf("the linear regression has no unique solution: %w", err)
+	}
+	var coefficients mat.VecDense
+	coefficients.MulVec(&inverse, xy)
+
+	lrr := libdrynx.LinearRegressionResult{Coefficients: coefficients.RawVector().Data}
+	if !parameters.Statistics {
+		return lrr, nil
+	}
+
+	//residual sum of squares: y^T y - 2 b^T X^T y + b^T X^T X b
+	n := xx.At(0, 0)
+	rss := sumY2 - 2*mat.Dot(&coefficients, xy) + mat.Inner(&coefficients, xx, &coefficients)
+	tss := sumY2 - xy.AtVec(0)*xy.AtVec(0)/n
+	//the residual degrees of freedom are n - tr(H), with the hat matrix H = X (X^T X + L)^-1 X^T, that is n - d - 1
+	//without penalty
+	var hat mat.Dense
+	hat.Mul(&inverse, xx)
+	dof := n - mat.Trace(&hat)
+	sigma2 := rss / dof
+
+	//covariance of the coefficients: sigma^2 (X^T X + L)^-1 X^T X (X^T X + L)^-1
+	var covariance mat.Dense
+	covariance.Product(&inverse, xx, &inverse)
+	covariance.Scale(sigma2, &covariance)
+
+	studentT := distuv.StudentsT{Mu: 0, Sigma: 1, Nu: dof}
+	lrr.StandardErrors = make([]float64, d+1)
+	lrr.TStatistics = make([]float64, d+1)
+	lrr.PValues = make([]float64, d+1)
+	for j := range lrr.Coefficients {
+		lrr.StandardErrors[j] = math.Sqrt(covariance.At(j, j))
+		lrr.TStatistics[j] = lrr.Coefficients[j] / lrr.StandardErrors[j]
+		lrr.PValues[j] = 2 * (1 - studentT.CDF(math.Abs(lrr.TStatistics[j])))
+	}
+	lrr.R2 = 1 - rss/tss
+	lrr.AdjustedR2 = 1 - (1-lrr.R2)*(n-1)/dof
+	return lrr, nil
+}
+
 func h(weights []float64, x []float64) float64 {
 	h := weights[0]
 	for i := 0; i < len(x); i++ {
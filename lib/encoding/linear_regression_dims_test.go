@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"go.dedis.ch/kyber/v3"
 	"go.dedis.ch/kyber/v3/util/key"
+	"math"
 	"testing"
 )
 
@@ -277,3 +278,57 @@ func TestEncodeDecodeLinearRegressionDimsWithProofs(t *testing.T) {
 		assert.True(t, libdrynxrange.RangeProofVerification(libdrynxrange.CreatePredicateRangeProofForAllServ(prf[i]), u[i], l2[i], yss[i], pubKey))
 	}
 }
+
+//TestDecodeLinearRegressionStatistics tests the standard errors and R² of a simple linear regression against their
+//closed form, and the shrinkage and standard errors of the ridge coefficients
+func TestDecodeLinearRegressionStatistics(t *testing.T) {
+	x := []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	y := []int64{3, 7, 6, 10, 11, 14, 13, 18, 19, 20}
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+
+	//closed form: b1 = Sxy/Sxx, se(b1) = sqrt(s^2/Sxx), se(b0) = sqrt(s^2 (1/n + mean(x)^2/Sxx))
+	n := float64(len(x))
+	meanX, meanY := 0.0, 0.0
+	for i := range x {
+		meanX += float64(x[i]) / n
+		meanY += float64(y[i]) / n
+	}
+	sxx, sxy, syy := 0.0, 0.0, 0.0
+	for i := range x {
+		sxx += (float64(x[i]) - meanX) * (float64(x[i]) - meanX)
+		sxy += (float64(x[i]) - meanX) * (float64(y[i]) - meanY)
+		syy += (float64(y[i]) - meanY) * (float64(y[i]) - meanY)
+	}
+	b1 := sxy / sxx
+	b0 := meanY - b1*meanX
+	rss := syy - b1*sxy
+	s2 := rss / (n - 2)
+
+	operation := libdrynx.LinearRegressionOperation(1, libdrynx.LinearRegressionParameters{Statistics: true})
+	ciphers, _, _ := libdrynxencoding.Encode([][]int64{x, y}, pubKey, nil, nil, operation)
+	assert.Equal(t, operation.NbrOutput, len(ciphers))
+
+	lrr := libdrynx.LinearRegressionResult{}
+	lrr.FromFloats(libdrynxencoding.Decode(ciphers, secKey, operation), true)
+	assert.InDeltaSlice(t, []float64{b0, b1}, lrr.Coefficients, 1e-9)
+	assert.InDeltaSlice(t, []float64{math.Sqrt(s2 * (1/n + meanX*meanX/sxx)), math.Sqrt(s2 / sxx)}, lrr.StandardErrors, 1e-9)
+	assert.InDelta(t, b1/math.Sqrt(s2/sxx), lrr.TStatistics[1], 1e-9)
+	assert.True(t, lrr.PValues[1] < 1e-6)
+	assert.InDelta(t, 1-rss/syy, lrr.R2, 1e-9)
+	assert.InDelta(t, 1-(rss/syy)*(n-1)/(n-2), lrr.AdjustedR2, 1e-9)
+
+	//the ridge penalty shrinks the slope but not the intercept
+	operation.LinRegParameters.Lambda = 50
+	ridge := libdrynx.LinearRegressionResult{}
+	ridge.FromFloats(libdrynxencoding.Decode(ciphers, secKey, operation), true)
+	assert.True(t, ridge.Coefficients[1] < b1 && ridge.Coefficients[1] > 0)
+	assert.True(t, ridge.R2 < lrr.R2)
+
+	//and its standard errors use the effective degrees of freedom 1 + Sxx/(Sxx+lambda) of the fit
+	lambda := operation.LinRegParameters.Lambda
+	ridgeB1 := sxy / (sxx + lambda)
+	ridgeS2 := (syy - 2*ridgeB1*sxy + ridgeB1*ridgeB1*sxx) / (n - 1 - sxx/(sxx+lambda))
+	assert.InDelta(t, ridgeB1, ridge.Coefficients[1], 1e-9)
+	assert.InDelta(t, math.Sqrt(ridgeS2*sxx)/(sxx+lambda), ridge.StandardErrors[1], 1e-9)
+}
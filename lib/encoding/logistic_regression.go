@@ -304,12 +304,7 @@ func CombinationsWithRepetition(n int64, k int64) int64 {
 
 // getTotalNumberApproxCoefficients returns the total number of approximation coefficients to compute for <d> features and approximation degree <k>
 func getTotalNumberApproxCoefficients(d int64, k int) int {
-	count := 0
-	for j := 0; j < k; j++ {
-		count += int(math.Pow(float64(d+1), float64(j+1)))
-	}
-
-	return count
+	return libdrynx.LogisticRegressionApproxCoefficients(d, k)
 }
 
 // getNumberApproxCoefficients returns the number of approximation coefficients to compute for <d> features at approximation degree <level>
@@ -977,6 +972,20 @@ func StandardiseWith(data [][]float64, means []float64, standardDeviations []flo
 	return standardisedData
 }
 
+// StandardiseWithConstantFeatures standardises a dataset as StandardiseWith, with the features of no standard deviation
+// set to 0: a constant feature carries no information
+func StandardiseWithConstantFeatures(data [][]float64, means []float64, standardDeviations []float64) [][]float64 {
+	standardisedData := StandardiseWith(data, means, standardDeviations)
+	for _, record := range standardisedData {
+		for j, v := range record {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				record[j] = 0
+			}
+		}
+	}
+	return standardisedData
+}
+
 // Normalize normalises a matrix column-wise
 func Normalize(matrix [][]float64) ([][]float64, error) {
 	return NormalizeWith(matrix, matrix)
@@ -1423,6 +1432,28 @@ func PartitionDataset(X [][]float64, y []int64, ratio float64, shuffle bool, see
 	return XTrain, yTrain, XTest, yTest
 }
 
+// PartitionFold splits a dataset, shuffled with the seed, in the given number of folds and returns the records of all
+// the folds but the given one, for training, and those of the fold, for testing. The shuffle has its own source, the
+// DPs split their records at the same time.
+func PartitionFold(X [][]float64, y []int64, folds int, fold int, seed int64) ([][]float64, []int64, [][]float64, []int64) {
+	indices := Range(0, int64(len(X)))
+	rand.New(rand.NewSource(seed)).Shuffle(len(indices), func(i, j int) { indices[i], indices[j] = indices[j], indices[i] })
+
+	XShuffled := make([][]float64, len(indices))
+	yShuffled := make([]int64, len(indices))
+	for i, index := range indices {
+		XShuffled[i] = X[index]
+		yShuffled[i] = y[index]
+	}
+
+	start := fold * len(XShuffled) / folds
+	end := (fold + 1) * len(XShuffled) / folds
+
+	XTrain := append(append([][]float64{}, XShuffled[:start]...), XShuffled[end:]...)
+	yTrain := append(append([]int64{}, yShuffled[:start]...), yShuffled[end:]...)
+	return XTrain, yTrain, XShuffled[start:end], yShuffled[start:end]
+}
+
 // GetDataForDataProvider returns data records from a file for a given data provider based on its id
 func GetDataForDataProvider(datasetName, filename string, dataProviderIdentity network.ServerIdentity) ([][]float64, []int64, error) {
 	var xForDP [][]float64
@@ -0,0 +1,153 @@
+package libdrynxencoding
+
+import (
+	"math"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+//Note: in the iterative logistic regression, a DP sends, for the current weights, its number of records followed by the
+//positive and then the negative parts of its gradient sum_i (sigmoid(w.x_i) - y_i) x_i, scaled by PrecisionGradients,
+//so that all the values are non-negative integers and can be range-proven. With more than two classes, the weights and
+//the gradient are flattened row after row, one row per class.
+
+// EncodeLogisticRegressionGradient computes and encrypts the data provider's gradient for the current weights
+func EncodeLogisticRegressionGradient(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, pubKey kyber.Point) ([]libunlynx.CipherText, []int64, error) {
+	ciphers, clears, _, err := EncodeLogisticRegressionGradientWithProofs(xData, yData, lrParameters, pubKey, nil, nil)
+	return ciphers, clears, err
+}
+
+// EncodeLogisticRegressionGradientWithProofs computes and encrypts the data provider's gradient for the current weights
+// with range proofs
+func EncodeLogisticRegressionGradientWithProofs(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof, error) {
+	d := libdrynx.LogisticRegressionClasses(lrParameters) * (int(lrParameters.NbrFeatures) + 1)
+	values := make([]int64, libdrynx.LogisticRegressionGradientOutputs(lrParameters))
+
+	if len(xData) > 0 {
+		means, standardDeviations := lrParameters.Means, lrParameters.StandardDeviations
+		if len(means) == 0 || len(standardDeviations) == 0 {
+			var err error
+			if means, err = ComputeMeans(xData); err != nil {
+				return nil, nil, nil, err
+			}
+			if standardDeviations, err = ComputeStandardDeviations(xData); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+		XStandardised := Augment(StandardiseWithConstantFeatures(xData, means, standardDeviations))
+
+		weights := lrParameters.Weights
+		if len(weights) != d {
+			weights = make([]float64, d)
+		}
+		gradient := MulticlassLogisticRegressionGradient(weights, XStandardised, yData, lrParameters)
+
+		values[0] = int64(len(XStandardised))
+		for j, g := range gradient {
+			scaled := int64(math.Round(math.Abs(g) * lrParameters.PrecisionGradients))
+			if g >= 0 {
+				values[1+j] = scaled
+			} else {
+				values[1+d+j] = scaled
+			}
+		}
+	}
+
+	ciphertextTuples, createRangeProof := encryptWithRangeProofs(values, pubKey, sigs, lu)
+	return ciphertextTuples, values, createRangeProof, nil
+}
+
+// MulticlassLogisticRegressionGradient computes the gradient sum_i (p_c(x_i) - [y_i = c]) x_i of the cost of the
+// records for the flattened weights of each class c: p_c is the softmax of the scores of the classes if Multinomial, the
+// sigmoid of the score of c otherwise (one-vs-rest, or binary with a single class)
+func MulticlassLogisticRegressionGradient(weights []float64, X [][]float64, y []int64, lrParameters libdrynx.LogisticRegressionParameters) []float64 {
+	classes := libdrynx.LogisticRegressionClasses(lrParameters)
+	gradient := make([]float64, len(weights))
+	if len(X) == 0 {
+		return gradient
+	}
+	d := len(X[0])
+
+	scores := make([]float64, classes)
+	for i, x := range X {
+		for c := range scores {
+			scores[c] = 0
+			for j, v := range x {
+				scores[c] += weights[c*d+j] * v
+			}
+		}
+		if classes > 1 && lrParameters.Multinomial {
+			softmax(scores)
+		} else {
+			for c := range scores {
+				scores[c] = sigmoid(scores[c])
+			}
+		}
+
+		for c, p := range scores {
+			label := 0.0
+			if (classes == 1 && y[i] == 1) || (classes > 1 && y[i] == int64(c)) {
+				label = 1
+			}
+			for j, v := range x {
+				gradient[c*d+j] += (p - label) * v
+			}
+		}
+	}
+	return gradient
+}
+
+// softmax replaces scores by their softmax, shifted by their maximum to avoid overflows
+func softmax(scores []float64) {
+	max := math.Inf(-1)
+	for _, s := range scores {
+		max = math.Max(max, s)
+	}
+	sum := 0.0
+	for c, s := range scores {
+		scores[c] = math.Exp(s - max)
+		sum += scores[c]
+	}
+	for c := range scores {
+		scores[c] /= sum
+	}
+}
+
+// DecodeLogisticRegressionGradient decodes the global gradient (querier side): the number of records followed by the
+// gradient
+func DecodeLogisticRegressionGradient(result []libunlynx.CipherText, privKey kyber.Scalar, lrParameters libdrynx.LogisticRegressionParameters) []float64 {
+	d := (len(result) - 1) / 2
+	values := DecodeFreqCount(result, privKey)
+
+	decoded := make([]float64, d+1)
+	decoded[0] = float64(values[0])
+	for j := 0; j < d; j++ {
+		decoded[1+j] = float64(values[1+j]-values[1+d+j]) / lrParameters.PrecisionGradients
+	}
+	return decoded
+}
+
+// LogisticRegressionStep does a step of gradient descent from the decoded global gradient and gives the new weights
+// with the size of the step (its largest component). The cost is the mean of the cost of the records with an
+// l2-regularization of lambda/(2N) |w|^2, where w are the weights of each class except its intercept.
+func LogisticRegressionStep(weights []float64, decoded []float64, lrParameters libdrynx.LogisticRegressionParameters) ([]float64, float64) {
+	n := decoded[0]
+	next := make([]float64, len(weights))
+	size := 0.0
+	for j := range weights {
+		penalty := lrParameters.Lambda * weights[j]
+		if j%int(lrParameters.NbrFeatures+1) == 0 {
+			penalty = 0
+		}
+		step := 0.0
+		if n > 0 {
+			step = lrParameters.Step * (decoded[1+j] + penalty) / n
+		}
+		next[j] = weights[j] - step
+		size = math.Max(size, math.Abs(step))
+	}
+	return next, size
+}
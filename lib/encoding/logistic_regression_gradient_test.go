@@ -0,0 +1,81 @@
+package libdrynxencoding_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+// TestIterativeLogisticRegression trains a logistic regression with encrypted gradients of 3 DPs and compares it with
+// the gradient descent on their pooled data
+func TestIterativeLogisticRegression(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	rng := rand.New(rand.NewSource(42))
+
+	// y = 1 if x0 + 2 x1 plus noise is positive
+	xs := make([][][]float64, 3)
+	ys := make([][]int64, 3)
+	pooledX := make([][]float64, 0)
+	for dp := range xs {
+		for i := 0; i < 30; i++ {
+			x := []float64{rng.NormFloat64(), rng.NormFloat64()}
+			y := int64(0)
+			if x[0]+2*x[1]+rng.NormFloat64()/2 > 0 {
+				y = 1
+			}
+			xs[dp] = append(xs[dp], x)
+			ys[dp] = append(ys[dp], y)
+			pooledX = append(pooledX, x)
+		}
+	}
+
+	// the DPs standardise with the global means and standard deviations
+	means, _ := libdrynxencoding.ComputeMeans(pooledX)
+	sds, _ := libdrynxencoding.ComputeStandardDeviations(pooledX)
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 2, Means: means, StandardDeviations: sds,
+		Lambda: 1, Step: 1, PrecisionGradients: 1e3, GradientRound: true}
+
+	encrypted := []float64{0, 0, 0}
+	clear := []float64{0, 0, 0}
+	for round := 0; round < 20; round++ {
+		lrParameters.Weights = encrypted
+		var result []libunlynx.CipherText
+		for dp := range xs {
+			ciphers, _, err := libdrynxencoding.EncodeLogisticRegressionGradient(xs[dp], ys[dp], lrParameters, pubKey)
+			assert.NoError(t, err)
+			assert.Len(t, ciphers, libdrynx.LogisticRegressionGradientOutputs(lrParameters))
+			if result == nil {
+				result = ciphers
+				continue
+			}
+			for i := range result {
+				result[i].Add(result[i], ciphers[i])
+			}
+		}
+		gradient := libdrynxencoding.DecodeLogisticRegressionGradient(result, secKey, lrParameters)
+		assert.Equal(t, float64(90), gradient[0])
+		encrypted, _ = libdrynxencoding.LogisticRegressionStep(encrypted, gradient, lrParameters)
+
+		// same step on the pooled data, in clear
+		pooledGradient := []float64{0, 0, 0, 0}
+		for dp := range xs {
+			x := libdrynxencoding.Augment(libdrynxencoding.StandardiseWith(xs[dp], means, sds))
+			g := libdrynxencoding.LogisticRegressionGradient(clear, x, libdrynxencoding.Int64ToFloat641DArray(ys[dp]), len(x), 0)
+			pooledGradient[0] += float64(len(x))
+			for j := range g {
+				pooledGradient[1+j] += g[j]
+			}
+		}
+		clear, _ = libdrynxencoding.LogisticRegressionStep(clear, pooledGradient, lrParameters)
+	}
+
+	assert.InDeltaSlice(t, clear, encrypted, 1e-2)
+	// both features increase the probability of y = 1, the second one more
+	assert.True(t, encrypted[2] > encrypted[1] && encrypted[1] > 0)
+}
@@ -0,0 +1,143 @@
+package libdrynxencoding
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+// ModelVersion is the version of the model artifacts written by SaveModel
+const ModelVersion = 1
+
+// LogisticRegressionModel is a trained logistic regression with everything needed to score new records: the records
+// are standardised with the means and standard deviations of the training and their features are in the order of
+// Features
+type LogisticRegressionModel struct {
+	Version            int       `json:"version"`
+	Features           []string  `json:"features,omitempty"`
+	Means              []float64 `json:"means"`
+	StandardDeviations []float64 `json:"standard_deviations"`
+	// a row per class (a single one if binary), the offset first
+	Weights     [][]float64 `json:"weights"`
+	Multinomial bool        `json:"multinomial,omitempty"`
+	K           int         `json:"k,omitempty"`
+	Lambda      float64     `json:"lambda"`
+}
+
+// NewLogisticRegressionModel bundles the weights given by a logistic regression survey with its parameters. The names of
+// the features are optional.
+func NewLogisticRegressionModel(weights []float64, lrParameters libdrynx.LogisticRegressionParameters, features []string) (*LogisticRegressionModel, error) {
+	d := int(lrParameters.NbrFeatures)
+	if len(lrParameters.Means) != d || len(lrParameters.StandardDeviations) != d {
+		return nil, errors.New("the model needs the global means and standard deviations of the training")
+	}
+	if len(weights) != libdrynx.LogisticRegressionClasses(lrParameters)*(d+1) {
+		return nil, fmt.Errorf("%d weights for %d features", len(weights), d)
+	}
+	if len(features) != 0 && len(features) != d {
+		return nil, fmt.Errorf("%d feature names for %d features", len(features), d)
+	}
+
+	model := &LogisticRegressionModel{
+		Version:            ModelVersion,
+		Features:           features,
+		Means:              lrParameters.Means,
+		StandardDeviations: lrParameters.StandardDeviations,
+		Weights:            libdrynx.LogisticRegressionWeightMatrix(weights, lrParameters),
+		Multinomial:        lrParameters.Multinomial,
+		Lambda:             lrParameters.Lambda,
+	}
+	// the approximation is not used by the iterative training
+	if !lrParameters.Iterative {
+		model.K = lrParameters.K
+	}
+	return model, nil
+}
+
+// check verifies that the model is consistent
+func (m *LogisticRegressionModel) check() error {
+	if m.Version != ModelVersion {
+		return fmt.Errorf("unsupported model version %d", m.Version)
+	}
+	d := len(m.Means)
+	if len(m.StandardDeviations) != d || (len(m.Features) != 0 && len(m.Features) != d) {
+		return errors.New("inconsistent number of features")
+	}
+	if len(m.Weights) == 0 {
+		return errors.New("no weights")
+	}
+	for _, row := range m.Weights {
+		if len(row) != d+1 {
+			return errors.New("inconsistent number of weights")
+		}
+	}
+	return nil
+}
+
+// Predict gives the probability of each class of a record: of 0 and 1 if the regression is binary
+func (m *LogisticRegressionModel) Predict(record []float64) ([]float64, error) {
+	if len(record) != len(m.Means) {
+		return nil, fmt.Errorf("%d values for %d features", len(record), len(m.Means))
+	}
+	standardised := StandardiseWith([][]float64{record}, m.Means, m.StandardDeviations)[0]
+
+	if len(m.Weights) == 1 {
+		p := PredictInClear(standardised, m.Weights[0])
+		return []float64{1 - p, p}, nil
+	}
+	return PredictClassesInClear(standardised, m.Weights, m.Multinomial), nil
+}
+
+// Parameters gives the logistic regression parameters of the model, e.g. to evaluate it with
+// libdrynx.ClassifierEvalOperation
+func (m *LogisticRegressionModel) Parameters() libdrynx.LogisticRegressionParameters {
+	lrParameters := libdrynx.LogisticRegressionParameters{
+		NbrFeatures:        int64(len(m.Means)),
+		Means:              m.Means,
+		StandardDeviations: m.StandardDeviations,
+		Multinomial:        m.Multinomial,
+		K:                  m.K,
+		Lambda:             m.Lambda,
+	}
+	if len(m.Weights) > 1 {
+		lrParameters.NbrClasses = int64(len(m.Weights))
+	}
+	for _, row := range m.Weights {
+		lrParameters.Weights = append(lrParameters.Weights, row...)
+	}
+	return lrParameters
+}
+
+// SaveModel writes a model artifact as JSON
+func SaveModel(model *LogisticRegressionModel, filename string) error {
+	if err := model.check(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(model, "", "\t")
+	if err != nil {
+		return fmt.Errorf("encoding model: %w", err)
+	}
+	if err := ioutil.WriteFile(filename, data, 0644); err != nil {
+		return fmt.Errorf("writing model: %w", err)
+	}
+	return nil
+}
+
+// LoadModel reads a model artifact written by SaveModel
+func LoadModel(filename string) (*LogisticRegressionModel, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading model: %w", err)
+	}
+	var model LogisticRegressionModel
+	if err := json.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("decoding model: %w", err)
+	}
+	if err := model.check(); err != nil {
+		return nil, err
+	}
+	return &model, nil
+}
@@ -0,0 +1,60 @@
+package libdrynxencoding_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSaveLoadModel tests that a saved model artifact scores records as the weights it was built from
+func TestSaveLoadModel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "model")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "model.json")
+
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 2, Means: []float64{50, 70},
+		StandardDeviations: []float64{10, 5}, K: 2, Lambda: 1}
+	weights := []float64{0.5, 2, -1}
+
+	_, err = libdrynxencoding.NewLogisticRegressionModel(weights, lrParameters, []string{"age"})
+	assert.Error(t, err)
+	model, err := libdrynxencoding.NewLogisticRegressionModel(weights, lrParameters, []string{"age", "weight"})
+	require.NoError(t, err)
+	require.NoError(t, libdrynxencoding.SaveModel(model, filename))
+
+	loaded, err := libdrynxencoding.LoadModel(filename)
+	require.NoError(t, err)
+	assert.Equal(t, model, loaded)
+
+	record := []float64{60, 75}
+	probabilities, err := loaded.Predict(record)
+	require.NoError(t, err)
+	expected := libdrynxencoding.PredictInClear(libdrynxencoding.StandardiseWith([][]float64{record}, lrParameters.Means, lrParameters.StandardDeviations)[0], weights)
+	assert.InDeltaSlice(t, []float64{1 - expected, expected}, probabilities, 1e-12)
+
+	// a row of weights per class
+	lrParameters.NbrClasses = 3
+	lrParameters.Multinomial = true
+	lrParameters.Iterative = true
+	model, err = libdrynxencoding.NewLogisticRegressionModel([]float64{0, 1, 0, 0, 0, 1, 0, -1, -1}, lrParameters, nil)
+	require.NoError(t, err)
+	assert.Len(t, model.Weights, 3)
+	assert.Equal(t, 0, model.K)
+	probabilities, err = model.Predict([]float64{70, 70})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), libdrynxencoding.PredictClass(probabilities))
+
+	// another version of the artifact is rejected
+	model.Version = libdrynxencoding.ModelVersion + 1
+	assert.Error(t, libdrynxencoding.SaveModel(model, filename))
+	require.NoError(t, ioutil.WriteFile(filename, []byte(`{"version": 2, "means": [], "standard_deviations": [], "weights": [[0]]}`), 0644))
+	_, err = libdrynxencoding.LoadModel(filename)
+	assert.Error(t, err)
+}
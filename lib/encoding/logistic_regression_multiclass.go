@@ -0,0 +1,129 @@
+package libdrynxencoding
+
+import (
+	"errors"
+	"math"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+//Note: with more than two classes, the approximated logistic regression is trained one-vs-rest: a DP sends the
+//approximation coefficients of a binary regression per class c, with the label [y = c], one block after the other. The
+//querier finds the weights of each block and gets a row of weights per class.
+
+// EncodeOneVsRestLogisticRegression computes and encrypts the data provider's approximation coefficients for each class
+func EncodeOneVsRestLogisticRegression(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, pubKey kyber.Point) ([]libunlynx.CipherText, []int64, error) {
+	ciphers, clears, _, err := EncodeOneVsRestLogisticRegressionWithProofs(xData, yData, lrParameters, pubKey, nil, nil)
+	return ciphers, clears, err
+}
+
+// EncodeOneVsRestLogisticRegressionWithProofs computes and encrypts the data provider's approximation coefficients for
+// each class with range proofs
+func EncodeOneVsRestLogisticRegressionWithProofs(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof, error) {
+	if lrParameters.Multinomial {
+		return nil, nil, nil, errors.New("the multinomial logistic regression is only trained iteratively")
+	}
+
+	n := getTotalNumberApproxCoefficients(lrParameters.NbrFeatures, lrParameters.K)
+	ciphers := make([]libunlynx.CipherText, 0, n*int(lrParameters.NbrClasses))
+	clears := make([]int64, 0, n*int(lrParameters.NbrClasses))
+	prf := make([]libdrynxrange.CreateProof, 0)
+
+	binary := make([]int64, len(yData))
+	for c := int64(0); c < lrParameters.NbrClasses; c++ {
+		for i, y := range yData {
+			binary[i] = 0
+			if y == c {
+				binary[i] = 1
+			}
+		}
+
+		var blockCiphers []libunlynx.CipherText
+		var blockClears []int64
+		var blockProofs []libdrynxrange.CreateProof
+		var err error
+		if sigs == nil {
+			blockCiphers, blockClears, err = EncodeLogisticRegression(xData, binary, lrParameters, pubKey)
+		} else {
+			blockSigs := make([][]libdrynx.PublishSignature, len(sigs))
+			for j := range sigs {
+				blockSigs[j] = sigs[j][int(c)*n : int(c+1)*n]
+			}
+			blockCiphers, blockClears, blockProofs, err = EncodeLogisticRegressionWithProofs(xData, binary, lrParameters, pubKey, blockSigs, lu[int(c)*n:int(c+1)*n])
+		}
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		ciphers = append(ciphers, blockCiphers...)
+		clears = append(clears, blockClears...)
+		prf = append(prf, blockProofs...)
+	}
+
+	if sigs == nil {
+		return ciphers, clears, nil, nil
+	}
+	return ciphers, clears, prf, nil
+}
+
+// DecodeOneVsRestLogisticRegression decodes the approximation coefficients of each class (querier side) and gives the
+// flattened weights, a row per class. The initial weights are either a single row, used for all the classes, or a row
+// per class.
+func DecodeOneVsRestLogisticRegression(result []libunlynx.CipherText, privKey kyber.Scalar, lrParameters libdrynx.LogisticRegressionParameters) []float64 {
+	classes := int(lrParameters.NbrClasses)
+	n := len(result) / classes
+	d := int(lrParameters.NbrFeatures) + 1
+
+	weights := make([]float64, 0, classes*d)
+	for c := 0; c < classes; c++ {
+		// the gradient descent modifies the initial weights
+		classParameters := lrParameters
+		if len(lrParameters.InitialWeights) == classes*d {
+			classParameters.InitialWeights = append([]float64(nil), lrParameters.InitialWeights[c*d:(c+1)*d]...)
+		} else {
+			classParameters.InitialWeights = append([]float64(nil), lrParameters.InitialWeights...)
+		}
+		weights = append(weights, DecodeLogisticRegression(result[c*n:(c+1)*n], privKey, classParameters)...)
+	}
+	return weights
+}
+
+// PredictClassesInClear computes the probability of each class for data and a row of weights per class given in clear:
+// the softmax of the scores of the classes if multinomial, the normalised sigmoids of the one-vs-rest regressions
+// otherwise
+func PredictClassesInClear(data []float64, weights [][]float64, multinomial bool) []float64 {
+	scores := make([]float64, len(weights))
+	for c, w := range weights {
+		scores[c] = w[0]
+		for i, v := range data {
+			scores[c] += w[i+1] * v
+		}
+	}
+
+	if multinomial {
+		softmax(scores)
+		return scores
+	}
+	sum := 0.0
+	for c := range scores {
+		scores[c] = sigmoid(scores[c])
+		sum += scores[c]
+	}
+	for c := range scores {
+		scores[c] /= sum
+	}
+	return scores
+}
+
+// PredictClass gives the most probable class
+func PredictClass(probabilities []float64) int64 {
+	best, max := int64(0), math.Inf(-1)
+	for c, p := range probabilities {
+		if p > max {
+			best, max = int64(c), p
+		}
+	}
+	return best
+}
@@ -0,0 +1,135 @@
+package libdrynxencoding_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+// threeClasses generates records of 2 features for 3 DPs, of class 0, 1 or 2 around (2, 0), (-1, 2) and (-1, -2)
+func threeClasses(rng *rand.Rand, records int) ([][][]float64, [][]int64) {
+	centers := [][]float64{{2, 0}, {-1, 2}, {-1, -2}}
+	xs := make([][][]float64, 3)
+	ys := make([][]int64, 3)
+	for dp := range xs {
+		for i := 0; i < records; i++ {
+			c := rng.Intn(3)
+			xs[dp] = append(xs[dp], []float64{centers[c][0] + rng.NormFloat64(), centers[c][1] + rng.NormFloat64()})
+			ys[dp] = append(ys[dp], int64(c))
+		}
+	}
+	return xs, ys
+}
+
+// accuracy gives the fraction of the records of which the most probable class is the label
+func accuracy(xs [][][]float64, ys [][]int64, weights [][]float64, multinomial bool, means, sds []float64) float64 {
+	correct, total := 0, 0
+	for dp := range xs {
+		for i, x := range libdrynxencoding.StandardiseWith(xs[dp], means, sds) {
+			if libdrynxencoding.PredictClass(libdrynxencoding.PredictClassesInClear(x, weights, multinomial)) == ys[dp][i] {
+				correct++
+			}
+			total++
+		}
+	}
+	return float64(correct) / float64(total)
+}
+
+// TestMultinomialLogisticRegression trains a softmax regression with encrypted gradients of 3 DPs and compares it with
+// the gradient descent on their pooled data
+func TestMultinomialLogisticRegression(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	xs, ys := threeClasses(rand.New(rand.NewSource(7)), 20)
+
+	pooledX := append(append(append([][]float64{}, xs[0]...), xs[1]...), xs[2]...)
+	means, _ := libdrynxencoding.ComputeMeans(pooledX)
+	sds, _ := libdrynxencoding.ComputeStandardDeviations(pooledX)
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 2, NbrClasses: 3, Multinomial: true,
+		Means: means, StandardDeviations: sds, Lambda: 1, Step: 1, PrecisionGradients: 1e2, GradientRound: true}
+
+	encrypted := make([]float64, 9)
+	clear := make([]float64, 9)
+	for round := 0; round < 20; round++ {
+		lrParameters.Weights = encrypted
+		var result []libunlynx.CipherText
+		for dp := range xs {
+			ciphers, _, err := libdrynxencoding.EncodeLogisticRegressionGradient(xs[dp], ys[dp], lrParameters, pubKey)
+			require.NoError(t, err)
+			assert.Len(t, ciphers, libdrynx.LogisticRegressionGradientOutputs(lrParameters))
+			if result == nil {
+				result = ciphers
+				continue
+			}
+			for i := range result {
+				result[i].Add(result[i], ciphers[i])
+			}
+		}
+		gradient := libdrynxencoding.Decode(result, secKey, libdrynx.Operation{NameOp: "logistic regression", LRParameters: lrParameters})
+		encrypted, _ = libdrynxencoding.LogisticRegressionStep(encrypted, gradient, lrParameters)
+
+		// same step on the pooled data, in clear
+		pooledGradient := make([]float64, 10)
+		for dp := range xs {
+			x := libdrynxencoding.Augment(libdrynxencoding.StandardiseWith(xs[dp], means, sds))
+			g := libdrynxencoding.MulticlassLogisticRegressionGradient(clear, x, ys[dp], lrParameters)
+			pooledGradient[0] += float64(len(x))
+			for j := range g {
+				pooledGradient[1+j] += g[j]
+			}
+		}
+		clear, _ = libdrynxencoding.LogisticRegressionStep(clear, pooledGradient, lrParameters)
+	}
+
+	assert.InDeltaSlice(t, clear, encrypted, 5e-2)
+	weights := libdrynx.LogisticRegressionWeightMatrix(encrypted, lrParameters)
+	assert.Len(t, weights, 3)
+	assert.True(t, accuracy(xs, ys, weights, true, means, sds) > 0.8)
+}
+
+// TestEncodeDecodeOneVsRestLogisticRegression tests the approximated logistic regression of 3 classes, trained
+// one-vs-rest
+func TestEncodeDecodeOneVsRestLogisticRegression(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	xs, ys := threeClasses(rand.New(rand.NewSource(7)), 20)
+
+	pooledX := append(append(append([][]float64{}, xs[0]...), xs[1]...), xs[2]...)
+	means, _ := libdrynxencoding.ComputeMeans(pooledX)
+	sds, _ := libdrynxencoding.ComputeStandardDeviations(pooledX)
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 2, NbrClasses: 3, NbrRecords: 20,
+		Means: means, StandardDeviations: sds, Lambda: 1, Step: 0.1, MaxIterations: 200, InitialWeights: []float64{0, 0, 0},
+		K: 2, PrecisionApproxCoefficients: 1e2}
+
+	var result []libunlynx.CipherText
+	for dp := range xs {
+		ciphers, _, err := libdrynxencoding.EncodeOneVsRestLogisticRegression(xs[dp], ys[dp], lrParameters, pubKey)
+		require.NoError(t, err)
+		if result == nil {
+			result = ciphers
+			continue
+		}
+		for i := range result {
+			result[i].Add(result[i], ciphers[i])
+		}
+	}
+	lrParameters.NbrRecords = 60
+
+	flattened := libdrynxencoding.Decode(result, secKey, libdrynx.Operation{NameOp: "logistic regression", LRParameters: lrParameters})
+	assert.Len(t, flattened, 9)
+	// the initial weights are not modified by the gradient descent of each class
+	assert.Equal(t, []float64{0, 0, 0}, lrParameters.InitialWeights)
+	weights := libdrynx.LogisticRegressionWeightMatrix(flattened, lrParameters)
+	assert.True(t, accuracy(xs, ys, weights, false, means, sds) > 0.8)
+
+	// the multinomial logistic regression is only trained iteratively
+	lrParameters.Multinomial = true
+	_, _, err := libdrynxencoding.EncodeOneVsRestLogisticRegression(xs[0], ys[0], lrParameters, pubKey)
+	assert.Error(t, err)
+}
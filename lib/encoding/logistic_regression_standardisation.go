@@ -0,0 +1,98 @@
+package libdrynxencoding
+
+import (
+	"math"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+//Note: before a logistic regression without means and standard deviations, a DP sends the variance encoding (sum,
+//number of records and sum of squares) of each feature, scaled to integers by PrecisionStandardisation, one feature after
+//the other. The sums of the DPs give the global means and standard deviations.
+
+// EncodeLogisticRegressionStandardisation encodes the sum, number of records and sum of squares of each feature
+func EncodeLogisticRegressionStandardisation(xData [][]float64, lrParameters libdrynx.LogisticRegressionParameters, pubKey kyber.Point) ([]libunlynx.CipherText, []int64) {
+	ciphers, clears, _ := EncodeLogisticRegressionStandardisationWithProofs(xData, lrParameters, pubKey, nil, nil)
+	return ciphers, clears
+}
+
+// EncodeLogisticRegressionStandardisationWithProofs encodes the sum, number of records and sum of squares of each
+// feature with range proofs
+func EncodeLogisticRegressionStandardisationWithProofs(xData [][]float64, lrParameters libdrynx.LogisticRegressionParameters, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	precision := libdrynx.StandardisationPrecision(lrParameters)
+	d := int(lrParameters.NbrFeatures)
+
+	ciphers := make([]libunlynx.CipherText, 0, 3*d)
+	clears := make([]int64, 0, 3*d)
+	prf := make([]libdrynxrange.CreateProof, 0, 3*d)
+	feature := make([]int64, len(xData))
+	for j := 0; j < d; j++ {
+		for i, record := range xData {
+			feature[i] = int64(math.Round(record[j] * precision))
+		}
+
+		var featureCiphers []libunlynx.CipherText
+		var featureClears []int64
+		var featureProofs []libdrynxrange.CreateProof
+		if sigs == nil {
+			featureCiphers, featureClears = EncodeVariance(feature, pubKey)
+		} else {
+			featureSigs := make([][]libdrynx.PublishSignature, len(sigs))
+			for k := range sigs {
+				featureSigs[k] = sigs[k][3*j : 3*j+3]
+			}
+			featureCiphers, featureClears, featureProofs = EncodeVarianceWithProofs(feature, pubKey, featureSigs, lu[3*j:3*j+3])
+		}
+		ciphers = append(ciphers, featureCiphers...)
+		clears = append(clears, featureClears...)
+		prf = append(prf, featureProofs...)
+	}
+
+	if sigs == nil {
+		return ciphers, clears, nil
+	}
+	return ciphers, clears, prf
+}
+
+// DecodeLogisticRegressionStandardisation decodes the sum, number of records and sum of squares of each feature,
+// rescaled to the features
+func DecodeLogisticRegressionStandardisation(result []libunlynx.CipherText, secKey kyber.Scalar, lrParameters libdrynx.LogisticRegressionParameters) []float64 {
+	precision := libdrynx.StandardisationPrecision(lrParameters)
+	values := DecodeFreqCount(result, secKey)
+
+	decoded := make([]float64, len(values))
+	for j := 0; j+2 < len(values); j += 3 {
+		decoded[j] = float64(values[j]) / precision
+		decoded[j+1] = float64(values[j+1])
+		decoded[j+2] = float64(values[j+2]) / (precision * precision)
+	}
+	return decoded
+}
+
+// StandardisationFromSums gives the means and (population) standard deviations of the features from the decoded sums of
+// one or several groups
+func StandardisationFromSums(sums ...[]float64) ([]float64, []float64) {
+	if len(sums) == 0 {
+		return nil, nil
+	}
+	d := len(sums[0]) / 3
+	means := make([]float64, d)
+	standardDeviations := make([]float64, d)
+	for j := 0; j < d; j++ {
+		sum, n, sumSquares := 0.0, 0.0, 0.0
+		for _, s := range sums {
+			sum += s[3*j]
+			n += s[3*j+1]
+			sumSquares += s[3*j+2]
+		}
+		if n == 0 {
+			continue
+		}
+		means[j] = sum / n
+		standardDeviations[j] = math.Sqrt(math.Max(0, sumSquares/n-means[j]*means[j]))
+	}
+	return means, standardDeviations
+}
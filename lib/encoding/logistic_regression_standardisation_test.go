@@ -0,0 +1,62 @@
+package libdrynxencoding_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+// TestLogisticRegressionStandardisation compares the means and standard deviations from the encrypted sums of 3 DPs with
+// those of their pooled records
+func TestLogisticRegressionStandardisation(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	rng := rand.New(rand.NewSource(3))
+
+	// the DPs have different distributions, so that their local statistics differ from the global ones
+	xs := make([][][]float64, 3)
+	pooled := make([][]float64, 0)
+	for dp := range xs {
+		for i := 0; i < 20; i++ {
+			x := []float64{float64(dp) + rng.NormFloat64(), -2 + rng.Float64()}
+			xs[dp] = append(xs[dp], x)
+			pooled = append(pooled, x)
+		}
+	}
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 2, StandardisationRound: true}
+
+	var result []libunlynx.CipherText
+	for dp := range xs {
+		ciphers, _ := libdrynxencoding.EncodeLogisticRegressionStandardisation(xs[dp], lrParameters, pubKey)
+		assert.Len(t, ciphers, 6)
+		if result == nil {
+			result = ciphers
+			continue
+		}
+		for i := range result {
+			result[i].Add(result[i], ciphers[i])
+		}
+	}
+	sums := libdrynxencoding.Decode(result, secKey, libdrynx.Operation{NameOp: "logistic regression", LRParameters: lrParameters})
+	means, standardDeviations := libdrynxencoding.StandardisationFromSums(sums)
+
+	expectedMeans, _ := libdrynxencoding.ComputeMeans(pooled)
+	expectedStandardDeviations, _ := libdrynxencoding.ComputeStandardDeviations(pooled)
+	// the features are rounded to 1e-1
+	assert.InDeltaSlice(t, expectedMeans, means, 5e-2)
+	assert.InDeltaSlice(t, expectedStandardDeviations, standardDeviations, 5e-2)
+
+	// the sums of several groups are pooled
+	halves := make([]float64, len(sums))
+	for i := range sums {
+		halves[i] = sums[i] / 2
+	}
+	pooledMeans, pooledStandardDeviations := libdrynxencoding.StandardisationFromSums(halves, halves)
+	assert.InDeltaSlice(t, means, pooledMeans, 1e-12)
+	assert.InDeltaSlice(t, standardDeviations, pooledStandardDeviations, 1e-12)
+}
@@ -14,6 +14,7 @@ import (
 	"go.dedis.ch/kyber/v3/util/key"
 	"gonum.org/v1/gonum/stat/combin"
 	"math"
+	"sync"
 	"testing"
 )
 
@@ -771,3 +772,63 @@ func TestStandardise(t *testing.T) {
 
 	//assert.Equal(t, XStandardised, XScaledStandardised)
 }
+
+// TestStandardiseWithConstantFeatures tests that the constant features are set to 0 instead of NaN or Inf
+func TestStandardiseWithConstantFeatures(t *testing.T) {
+	X := [][]float64{{1, 2}, {3, 2}}
+	means := []float64{2, 2}
+	standardDeviations := []float64{1, 0}
+
+	assert.Equal(t, [][]float64{{-1, 0}, {1, 0}}, libdrynxencoding.StandardiseWithConstantFeatures(X, means, standardDeviations))
+	// a constant feature that is not at its mean
+	assert.Equal(t, [][]float64{{0, 0}}, libdrynxencoding.StandardiseWithConstantFeatures([][]float64{{2, 3}}, means, standardDeviations))
+}
+
+// TestPartitionFold tests that the folds partition the records, and that they are the same for the same seed
+func TestPartitionFold(t *testing.T) {
+	X := make([][]float64, 10)
+	y := make([]int64, 10)
+	for i := range X {
+		X[i] = []float64{float64(i)}
+		y[i] = int64(i)
+	}
+
+	// every record is tested in exactly one of the folds, and trains the model in the others
+	tested := make(map[int64]int)
+	for fold := 0; fold < 3; fold++ {
+		XTrain, yTrain, XTest, yTest := libdrynxencoding.PartitionFold(X, y, 3, fold, 42)
+		assert.Len(t, XTrain, len(yTrain))
+		assert.Len(t, XTest, len(yTest))
+		assert.Equal(t, 10, len(yTrain)+len(yTest))
+		for i, label := range yTest {
+			assert.Equal(t, float64(label), XTest[i][0])
+			tested[label]++
+		}
+	}
+	assert.Len(t, tested, 10)
+	for _, n := range tested {
+		assert.Equal(t, 1, n)
+	}
+
+	// the DPs split their records in the same way with the same seed
+	_, _, _, first := libdrynxencoding.PartitionFold(X, y, 3, 1, 42)
+	_, _, _, second := libdrynxencoding.PartitionFold(X, y, 3, 1, 42)
+	assert.Equal(t, first, second)
+
+	// even when they split them at the same time, with other seeds
+	var wg sync.WaitGroup
+	folds := make([][]int64, 8)
+	for i := range folds {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _, _, folds[i] = libdrynxencoding.PartitionFold(X, y, 3, 1, int64(42+i%2))
+		}(i)
+	}
+	wg.Wait()
+	for i := range folds {
+		if i%2 == 0 {
+			assert.Equal(t, first, folds[i])
+		}
+	}
+}
@@ -144,3 +144,50 @@ func DecodeMax(result []libunlynx.CipherText, globalMin int64, secKey kyber.Scal
 	}
 	return max
 }
+
+//EncodeMinMaxBitWithProofs encodes one round of the bit-by-bit search of the min (max): whether the local min (max),
+//minus the lower bound of the domain, has the prefix of the round followed by a 0 (1) at the bit of the round
+func EncodeMinMaxBitWithProofs(input []int64, operation libdrynx.Operation, pubKey kyber.Point, sigs []libdrynx.PublishSignature, l int64, u int64) (*libunlynx.CipherText, int64, libdrynxrange.CreateProof) {
+	local := input[0]
+	for _, v := range input {
+		if (operation.NameOp == "min" && v < local) || (operation.NameOp == "max" && v > local) {
+			local = v
+		}
+	}
+
+	searched := operation.MinMaxPrefix << 1
+	if operation.NameOp == "max" {
+		searched++
+	}
+	val := (local-operation.QueryMin)>>uint(operation.MinMaxBit) == searched
+
+	if sigs != nil {
+		return EncodeBitOrWithProof(val, pubKey, sigs, l, u)
+	}
+	cipher, clear := EncodeBitOr(val, pubKey)
+	return cipher, clear, libdrynxrange.CreateProof{}
+}
+
+//EncodeMinMaxBit encodes one round of the bit-by-bit search of the min (max)
+func EncodeMinMaxBit(input []int64, operation libdrynx.Operation, pubKey kyber.Point) (*libunlynx.CipherText, int64) {
+	cipher, clear, _ := EncodeMinMaxBitWithProofs(input, operation, pubKey, nil, 0, 0)
+	return cipher, clear
+}
+
+func encodeMinMaxRound(input []int64, pubKey kyber.Point, signatures [][]libdrynx.PublishSignature, ranges []*[]int64, operation libdrynx.Operation) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	if len(ranges) > 0 && len(signatures) > 0 {
+		cipher, clear, prf := EncodeMinMaxBitWithProofs(input, operation, pubKey, signatures[0], (*ranges[0])[1], (*ranges[0])[0])
+		return []libunlynx.CipherText{*cipher}, []int64{clear}, []libdrynxrange.CreateProof{prf}
+	}
+	cipher, clear := EncodeMinMaxBit(input, operation, pubKey)
+	return []libunlynx.CipherText{*cipher}, []int64{clear}, nil
+}
+
+//NextMinMaxPrefix gives the prefix of the global min (max) once the bit of the round is known: found tells whether
+//a DP has the prefix followed by a 0 (1)
+func NextMinMaxPrefix(operation libdrynx.Operation, prefix int64, found bool) int64 {
+	if found == (operation.NameOp == "max") {
+		return prefix<<1 + 1
+	}
+	return prefix << 1
+}
@@ -114,3 +114,33 @@ func TestEncodeDecodeMinMaxWithProofs(t *testing.T) {
 	}
 
 }
+
+//TestEncodeMinMaxBit tests the bit-by-bit search of the min and max over a large domain
+func TestEncodeMinMaxBit(t *testing.T) {
+	inputValues := [][]int64{{70000, 1254, 98765}, {4321, 99999, 65536}, {123456, 5000}}
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+
+	for _, tc := range []struct {
+		name     string
+		expected int64
+	}{{"min", 1254}, {"max", 123456}} {
+		operation := libdrynx.Operation{NameOp: tc.name, QueryMin: 1000, QueryMax: 200000}
+		assert.True(t, libdrynx.MinMaxBitwise(operation))
+
+		prefix := int64(0)
+		for bit := libdrynx.MinMaxRounds(operation) - 1; bit >= 0; bit-- {
+			operation.MinMaxRound = true
+			operation.MinMaxBit = bit
+			operation.MinMaxPrefix = prefix
+
+			sum := libunlynx.NewCipherText()
+			for _, v := range inputValues {
+				cipher, _ := libdrynxencoding.EncodeMinMaxBit(v, operation, pubKey)
+				sum.Add(*sum, *cipher)
+			}
+			prefix = libdrynxencoding.NextMinMaxPrefix(operation, prefix, libdrynxencoding.DecodeBitOR(*sum, secKey))
+		}
+		assert.Equal(t, tc.expected, operation.QueryMin+prefix)
+	}
+}
@@ -0,0 +1,206 @@
+package libdrynxencoding
+
+import (
+	"errors"
+	"math"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+//Note: in naiveBayes, a DP sends for each class c and each feature j, at (c*features + j)*perFeature, the statistics of
+//feature j over its records of class c: if the features are Gaussian, the sums of its positive values and of the
+//absolute values of its negative ones (so that both can be range proven), the number of records and the sum of squares,
+//scaled to integers by the precision; if they are categorical, the frequency count of the categories. The records with
+//a label out of the classes are ignored.
+
+// naiveBayesPrecision gives the scale of the Gaussian features to integers
+func naiveBayesPrecision(nbParameters libdrynx.NaiveBayesParameters) float64 {
+	if nbParameters.Precision == 0 {
+		return libdrynx.NaiveBayesDefaultPrecision
+	}
+	return nbParameters.Precision
+}
+
+// naiveBayesSmoothing gives the additive smoothing of the categorical counts
+func naiveBayesSmoothing(nbParameters libdrynx.NaiveBayesParameters) float64 {
+	if nbParameters.Smoothing == 0 {
+		return 1
+	}
+	return nbParameters.Smoothing
+}
+
+// EncodeNaiveBayes encodes the per-class sufficient statistics of the features of the local records
+func EncodeNaiveBayes(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, nbParameters libdrynx.NaiveBayesParameters, pubKey kyber.Point) ([]libunlynx.CipherText, []int64, error) {
+	ciphers, clears, _, err := EncodeNaiveBayesWithProofs(xData, yData, lrParameters, nbParameters, pubKey, nil, nil)
+	return ciphers, clears, err
+}
+
+// EncodeNaiveBayesWithProofs encodes the per-class sufficient statistics of the features of the local records with
+// range proofs
+func EncodeNaiveBayesWithProofs(xData [][]float64, yData []int64, lrParameters libdrynx.LogisticRegressionParameters, nbParameters libdrynx.NaiveBayesParameters, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof, error) {
+	classes := libdrynx.ClassifierClasses(lrParameters)
+	d := int(lrParameters.NbrFeatures)
+	categories := nbParameters.Categories
+	precision := naiveBayesPrecision(nbParameters)
+	perFeature := libdrynx.NaiveBayesGaussianStatistics
+	if categories > 0 {
+		perFeature = int(categories)
+	}
+
+	// the records of each class
+	byClass := make([][][]float64, classes)
+	for i, record := range xData {
+		if yData[i] < 0 || yData[i] >= int64(classes) {
+			continue
+		}
+		byClass[yData[i]] = append(byClass[yData[i]], record)
+	}
+
+	clears := make([]int64, classes*d*perFeature)
+	for c := 0; c < classes; c++ {
+		for j := 0; j < d; j++ {
+			statistics := clears[(c*d+j)*perFeature : (c*d+j+1)*perFeature]
+			for _, record := range byClass[c] {
+				if categories == 0 {
+					x := int64(math.Round(record[j] * precision))
+					if x >= 0 {
+						statistics[0] += x
+					} else {
+						statistics[1] -= x
+					}
+					statistics[2]++
+					statistics[3] += x * x
+					continue
+				}
+				category := int64(math.Round(record[j]))
+				if category < 0 || category >= categories {
+					return nil, nil, nil, errors.New("a categorical feature is out of the categories")
+				}
+				statistics[category]++
+			}
+		}
+	}
+
+	ciphers, prf := encryptWithRangeProofs(clears, pubKey, sigs, lu)
+	return ciphers, clears, prf, nil
+}
+
+// DecodeNaiveBayes decodes the global per-class sufficient statistics into a Naive Bayes model
+func DecodeNaiveBayes(result []libunlynx.CipherText, secKey kyber.Scalar, lrParameters libdrynx.LogisticRegressionParameters, nbParameters libdrynx.NaiveBayesParameters) libdrynx.NaiveBayesModel {
+	return NaiveBayesModelFromCounts(DecodeFreqCount(result, secKey), libdrynx.ClassifierClasses(lrParameters), int(lrParameters.NbrFeatures), nbParameters)
+}
+
+// NaiveBayesModelFromCounts computes a Naive Bayes model from the per-class sufficient statistics. The variances of the
+// Gaussian features are those of the population plus the variance of the rounding to the precision, which keeps them
+// positive for a constant feature.
+func NaiveBayesModelFromCounts(counts []int64, classes int, features int, nbParameters libdrynx.NaiveBayesParameters) libdrynx.NaiveBayesModel {
+	model := libdrynx.NaiveBayesModel{Priors: make([]float64, classes)}
+	categories := int(nbParameters.Categories)
+	perFeature := libdrynx.NaiveBayesGaussianStatistics
+	if categories > 0 {
+		perFeature = categories
+	}
+
+	// the number of records of each class, from the first feature
+	total := 0.0
+	for c := 0; c < classes && features > 0; c++ {
+		statistics := counts[c*features*perFeature : (c*features+1)*perFeature]
+		if categories == 0 {
+			model.Priors[c] = float64(statistics[2])
+		} else {
+			for _, count := range statistics {
+				model.Priors[c] += float64(count)
+			}
+		}
+		total += model.Priors[c]
+	}
+
+	if categories == 0 {
+		precision := naiveBayesPrecision(nbParameters)
+		rounding := 1 / (12 * precision * precision)
+		model.Means = make([][]float64, classes)
+		model.Variances = make([][]float64, classes)
+		for c := 0; c < classes; c++ {
+			model.Means[c] = make([]float64, features)
+			model.Variances[c] = make([]float64, features)
+			for j := 0; j < features; j++ {
+				statistics := counts[(c*features+j)*libdrynx.NaiveBayesGaussianStatistics:]
+				n := float64(statistics[2])
+				if n > 0 {
+					model.Means[c][j] = float64(statistics[0]-statistics[1]) / precision / n
+					model.Variances[c][j] = math.Max(0, float64(statistics[3])/(precision*precision)/n-model.Means[c][j]*model.Means[c][j])
+				}
+				model.Variances[c][j] += rounding
+			}
+		}
+	} else {
+		smoothing := naiveBayesSmoothing(nbParameters)
+		model.Probabilities = make([][][]float64, classes)
+		for c := 0; c < classes; c++ {
+			model.Probabilities[c] = make([][]float64, features)
+			for j := 0; j < features; j++ {
+				statistics := counts[(c*features+j)*categories:]
+				model.Probabilities[c][j] = make([]float64, categories)
+				for v := 0; v < categories; v++ {
+					model.Probabilities[c][j][v] = (float64(statistics[v]) + smoothing) / (model.Priors[c] + smoothing*float64(categories))
+				}
+			}
+		}
+	}
+
+	for c := range model.Priors {
+		model.Priors[c] = ratio(model.Priors[c], total)
+	}
+	return model
+}
+
+// NaiveBayesLogLikelihoods gives the log of the prior times the likelihood of a record for each class of the model. A
+// categorical feature out of the categories is ignored.
+func NaiveBayesLogLikelihoods(data []float64, model libdrynx.NaiveBayesModel) []float64 {
+	logLikelihoods := make([]float64, len(model.Priors))
+	for c, prior := range model.Priors {
+		logLikelihoods[c] = math.Log(prior)
+		for j, x := range data {
+			if model.Means != nil {
+				variance := model.Variances[c][j]
+				logLikelihoods[c] -= math.Log(2*math.Pi*variance)/2 + (x-model.Means[c][j])*(x-model.Means[c][j])/(2*variance)
+				continue
+			}
+			category := int(math.Round(x))
+			if category >= 0 && category < len(model.Probabilities[c][j]) {
+				logLikelihoods[c] += math.Log(model.Probabilities[c][j][category])
+			}
+		}
+	}
+	return logLikelihoods
+}
+
+// PredictNaiveBayesInClear gives the probability of each class of a record for a Naive Bayes model, see PredictClass
+func PredictNaiveBayesInClear(data []float64, model libdrynx.NaiveBayesModel) []float64 {
+	logLikelihoods := NaiveBayesLogLikelihoods(data, model)
+	max := math.Inf(-1)
+	for _, l := range logLikelihoods {
+		max = math.Max(max, l)
+	}
+
+	probabilities := make([]float64, len(logLikelihoods))
+	// a model without records
+	if math.IsInf(max, -1) {
+		for c := range probabilities {
+			probabilities[c] = 1 / float64(len(probabilities))
+		}
+		return probabilities
+	}
+	sum := 0.0
+	for c, l := range logLikelihoods {
+		probabilities[c] = math.Exp(l - max)
+		sum += probabilities[c]
+	}
+	for c := range probabilities {
+		probabilities[c] /= sum
+	}
+	return probabilities
+}
@@ -0,0 +1,141 @@
+package libdrynxencoding_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/key"
+)
+
+// TestEncodeDecodeNaiveBayes compares the Gaussian Naive Bayes of the encrypted statistics of 3 DPs with the means and
+// variances of the pooled records of each class
+func TestEncodeDecodeNaiveBayes(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	rng := rand.New(rand.NewSource(5))
+
+	// the features of class 1 are shifted by 2, with a tenth as precision
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 2}
+	nbParameters := libdrynx.NaiveBayesParameters{}
+	xs := make([][][]float64, 3)
+	ys := make([][]int64, 3)
+	pooled := make([][][]float64, 2)
+	for dp := range xs {
+		for i := 0; i < 20; i++ {
+			y := int64(rng.Intn(2))
+			x := []float64{math.Round(10*(rng.NormFloat64()+2*float64(y))) / 10, math.Round(10*rng.NormFloat64()) / 10}
+			xs[dp] = append(xs[dp], x)
+			ys[dp] = append(ys[dp], y)
+			pooled[y] = append(pooled[y], x)
+		}
+	}
+
+	var result []libunlynx.CipherText
+	for dp := range xs {
+		ciphers, _, err := libdrynxencoding.EncodeNaiveBayes(xs[dp], ys[dp], lrParameters, nbParameters, pubKey)
+		assert.NoError(t, err)
+		if result == nil {
+			result = ciphers
+		} else {
+			for i := range result {
+				result[i].Add(result[i], ciphers[i])
+			}
+		}
+	}
+
+	operation := libdrynx.NaiveBayesOperation(lrParameters, nbParameters)
+	assert.Len(t, result, operation.NbrOutput)
+	var model libdrynx.NaiveBayesModel
+	model.FromFloats(libdrynxencoding.Decode(result, secKey, operation), 2, 2, 0)
+
+	for c := range pooled {
+		assert.InDelta(t, float64(len(pooled[c]))/60, model.Priors[c], 1e-12)
+		means, _ := libdrynxencoding.ComputeMeans(pooled[c])
+		assert.InDeltaSlice(t, means, model.Means[c], 1e-9)
+		for j := range means {
+			variance := 0.0
+			for _, x := range pooled[c] {
+				variance += (x[j] - means[j]) * (x[j] - means[j]) / float64(len(pooled[c]))
+			}
+			assert.InDelta(t, variance+1.0/1200, model.Variances[c][j], 1e-9)
+		}
+	}
+
+	// far on either side of the classes
+	assert.Equal(t, int64(0), libdrynxencoding.PredictClass(libdrynxencoding.PredictNaiveBayesInClear([]float64{-2, 0}, model)))
+	assert.Equal(t, int64(1), libdrynxencoding.PredictClass(libdrynxencoding.PredictNaiveBayesInClear([]float64{4, 0}, model)))
+	probabilities := libdrynxencoding.PredictNaiveBayesInClear([]float64{1, 0}, model)
+	assert.InDelta(t, 1, probabilities[0]+probabilities[1], 1e-12)
+}
+
+// TestNaiveBayesModelFromCounts tests a categorical Naive Bayes of 2 classes, 2 features and 3 categories
+func TestNaiveBayesModelFromCounts(t *testing.T) {
+	// for each class, the counts of the categories of each feature
+	counts := []int64{
+		3, 1, 0, 2, 2, 0,
+		0, 1, 5, 0, 0, 6}
+	nbParameters := libdrynx.NaiveBayesParameters{Categories: 3}
+
+	model := libdrynxencoding.NaiveBayesModelFromCounts(counts, 2, 2, nbParameters)
+	assert.InDeltaSlice(t, []float64{0.4, 0.6}, model.Priors, 1e-12)
+	assert.InDeltaSlice(t, []float64{4.0 / 7, 2.0 / 7, 1.0 / 7}, model.Probabilities[0][0], 1e-12)
+	assert.InDeltaSlice(t, []float64{1.0 / 9, 1.0 / 9, 7.0 / 9}, model.Probabilities[1][1], 1e-12)
+	assert.Nil(t, model.Means)
+
+	assert.Equal(t, int64(0), libdrynxencoding.PredictClass(libdrynxencoding.PredictNaiveBayesInClear([]float64{0, 1}, model)))
+	assert.Equal(t, int64(1), libdrynxencoding.PredictClass(libdrynxencoding.PredictNaiveBayesInClear([]float64{2, 2}, model)))
+	// the second feature is out of the categories and ignored
+	probabilities := libdrynxencoding.PredictNaiveBayesInClear([]float64{0, 7}, model)
+	assert.InDelta(t, 0.4*4.0/7/(0.4*4.0/7+0.6*1.0/9), probabilities[0], 1e-12)
+
+	var back libdrynx.NaiveBayesModel
+	back.FromFloats(model.ToFloats(), 2, 2, 3)
+	assert.Equal(t, model, back)
+}
+
+// TestEncodeNaiveBayesWithProofs tests the range proofs of the frequency counts of a categorical Naive Bayes and of the
+// statistics of a Gaussian one, whose negative features are summed apart
+func TestEncodeNaiveBayesWithProofs(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	pubKey := keys.Public
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 1}
+	yData := []int64{0, 1, 1}
+
+	for _, test := range []struct {
+		nbParameters libdrynx.NaiveBayesParameters
+		xData        [][]float64
+		clear        []int64
+	}{
+		{libdrynx.NaiveBayesParameters{Categories: 2}, [][]float64{{0}, {1}, {1}}, []int64{1, 0, 0, 2}},
+		{libdrynx.NaiveBayesParameters{}, [][]float64{{-0.5}, {1}, {-2}}, []int64{0, 5, 1, 25, 10, 20, 2, 500}},
+	} {
+		operation := libdrynx.NaiveBayesOperation(lrParameters, test.nbParameters)
+		ranges := libdrynx.CountRanges(1000, operation.NbrOutput)
+		ps := make([][]libdrynx.PublishSignature, 2)
+		for j := range ps {
+			ps[j] = make([]libdrynx.PublishSignature, operation.NbrOutput)
+			for i := range ps[j] {
+				ps[j][i] = libdrynxrange.PublishSignatureBytesToPublishSignatures(libdrynxrange.InitRangeProofSignature((*ranges[i])[0]))
+			}
+		}
+
+		_, clear, prf, err := libdrynxencoding.EncodeNaiveBayesWithProofs(test.xData, yData, lrParameters, test.nbParameters, pubKey, ps, ranges)
+		assert.NoError(t, err)
+		assert.Equal(t, test.clear, clear)
+		assert.Len(t, prf, operation.NbrOutput)
+		for i := range prf {
+			ys := []kyber.Point{ps[0][i].Public, ps[1][i].Public}
+			assert.True(t, libdrynxrange.RangeProofVerification(libdrynxrange.CreatePredicateRangeProofForAllServ(prf[i]), (*ranges[i])[0], (*ranges[i])[1], ys, pubKey))
+		}
+	}
+
+	_, _, err := libdrynxencoding.EncodeNaiveBayes([][]float64{{2}}, []int64{0}, lrParameters, libdrynx.NaiveBayesParameters{Categories: 2}, pubKey)
+	assert.Error(t, err)
+}
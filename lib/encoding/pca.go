@@ -0,0 +1,142 @@
+package libdrynxencoding
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+	"gonum.org/v1/gonum/mat"
+)
+
+//Note: in pca, a DP sends the number of its records, the sum of each dimension and then the sums of the products of
+//every pair of dimensions j <= k, row after row of the upper triangle, as the first values of EncodeLinearRegressionDims.
+
+// EncodePCA encodes the sums and cross-products of the dimensions of the local records
+func EncodePCA(input [][]int64, pubKey kyber.Point) ([]libunlynx.CipherText, []int64) {
+	resultEnc, resultClear, _ := EncodePCAWithProofs(input, pubKey, nil, nil)
+	return resultEnc, resultClear
+}
+
+// EncodePCAWithProofs encodes the sums and cross-products of the dimensions of the local records with range proofs
+func EncodePCAWithProofs(input [][]int64, pubKey kyber.Point, sigs [][]libdrynx.PublishSignature, lu []*[]int64) ([]libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	d := 0
+	if len(input) > 0 {
+		d = len(input[0])
+	}
+
+	clears := make([]int64, 1+d+d*(d+1)/2)
+	clears[0] = int64(len(input))
+	for _, record := range input {
+		index := 1 + d
+		for j, x := range record {
+			clears[1+j] += x
+			for k := j; k < d; k++ {
+				clears[index] += x * record[k]
+				index++
+			}
+		}
+	}
+
+	ciphers, createRangeProof := encryptWithRangeProofs(clears, pubKey, sigs, lu)
+	return ciphers, clears, createRangeProof
+}
+
+// DecodePCA decodes the global sums and cross-products of the dimensions and analyses their covariance matrix
+func DecodePCA(result []libunlynx.CipherText, secKey kyber.Scalar, parameters libdrynx.PCAParameters) (libdrynx.PCAResult, error) {
+	values := make([]float64, len(result))
+	for i, v := range result {
+		values[i] = float64(libunlynx.DecryptIntWithNeg(secKey, v))
+	}
+	return PCAFromSums(values, parameters)
+}
+
+// PCAFromSums computes the (sample) covariance matrix of the dimensions from their sums and cross-products and gives its
+// eigenvectors by decreasing eigenvalue. The noise of a differentially private query can make some eigenvalues negative,
+// they are then taken as no variance.
+func PCAFromSums(values []float64, parameters libdrynx.PCAParameters) (libdrynx.PCAResult, error) {
+	//get the number of dimensions from d^2 + 3d + 2 = 2*len(values)
+	d := int(math.Round((math.Sqrt(float64(1+8*len(values))) - 3) / 2))
+	if d < 1 || (d*d+3*d+2)/2 != len(values) {
+		return libdrynx.PCAResult{}, fmt.Errorf("%d values are not the sums of a principal component analysis", len(values))
+	}
+	n := values[0]
+	if n < 2 {
+		return libdrynx.PCAResult{}, errors.New("a principal component analysis needs at least 2 records")
+	}
+
+	pcar := libdrynx.PCAResult{Means: make([]float64, d)}
+	for j := range pcar.Means {
+		pcar.Means[j] = values[1+j] / n
+	}
+	covariance := mat.NewSymDense(d, nil)
+	index := 1 + d
+	for j := 0; j < d; j++ {
+		for k := j; k < d; k++ {
+			covariance.SetSym(j, k, (values[index]-n*pcar.Means[j]*pcar.Means[k])/(n-1))
+			index++
+		}
+	}
+
+	var eigen mat.EigenSym
+	if ok := eigen.Factorize(covariance, true); !ok {
+		return libdrynx.PCAResult{}, errors.New("the eigendecomposition of the covariance matrix failed")
+	}
+	eigenvalues := eigen.Values(nil)
+	var eigenvectors mat.Dense
+	eigen.VectorsTo(&eigenvectors)
+
+	order := make([]int, d)
+	total := 0.0
+	for i := range order {
+		order[i] = i
+		eigenvalues[i] = math.Max(0, eigenvalues[i])
+		total += eigenvalues[i]
+	}
+	sort.SliceStable(order, func(a, b int) bool { return eigenvalues[order[a]] > eigenvalues[order[b]] })
+
+	components := libdrynx.PCAComponents(parameters, d)
+	pcar.ExplainedVariance = make([]float64, components)
+	pcar.ExplainedVarianceRatio = make([]float64, components)
+	pcar.Components = make([][]float64, components)
+	pcar.Loadings = make([][]float64, components)
+	for c := 0; c < components; c++ {
+		i := order[c]
+		pcar.ExplainedVariance[c] = eigenvalues[i]
+		pcar.ExplainedVarianceRatio[c] = ratio(eigenvalues[i], total)
+		pcar.Components[c] = mat.Col(nil, i, &eigenvectors)
+
+		// the sign of an eigenvector is arbitrary: its largest coordinate is made positive
+		largest := 0
+		for j, v := range pcar.Components[c] {
+			if math.Abs(v) > math.Abs(pcar.Components[c][largest]) {
+				largest = j
+			}
+		}
+		sign := 1.0
+		if pcar.Components[c][largest] < 0 {
+			sign = -1
+		}
+		pcar.Loadings[c] = make([]float64, d)
+		for j := range pcar.Components[c] {
+			pcar.Components[c][j] *= sign
+			pcar.Loadings[c][j] = pcar.Components[c][j] * math.Sqrt(eigenvalues[i])
+		}
+	}
+	return pcar, nil
+}
+
+// PCAProject gives the coordinates of a record along the components of a principal component analysis
+func PCAProject(data []float64, pcar libdrynx.PCAResult) []float64 {
+	projection := make([]float64, len(pcar.Components))
+	for c, component := range pcar.Components {
+		for j, x := range data {
+			projection[c] += (x - pcar.Means[j]) * component[j]
+		}
+	}
+	return projection
+}
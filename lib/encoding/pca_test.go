@@ -0,0 +1,112 @@
+package libdrynxencoding_test
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/range"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/kyber/v3"
+	"go.dedis.ch/kyber/v3/util/key"
+	"gonum.org/v1/gonum/stat"
+)
+
+// TestEncodeDecodePCA compares the principal component analysis of the encrypted sums of 3 DPs, whose records are close
+// to the line y = 2x, with the covariance matrix of the pooled records
+func TestEncodeDecodePCA(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	secKey, pubKey := keys.Private, keys.Public
+	rng := rand.New(rand.NewSource(9))
+
+	var result []libunlynx.CipherText
+	var xs, ys []float64
+	for dp := 0; dp < 3; dp++ {
+		records := make([][]int64, 15)
+		for i := range records {
+			x := int64(rng.Intn(10))
+			records[i] = []int64{x, 2*x + int64(rng.Intn(3)) - 1}
+			xs = append(xs, float64(records[i][0]))
+			ys = append(ys, float64(records[i][1]))
+		}
+		ciphers, _ := libdrynxencoding.EncodePCA(records, pubKey)
+		if result == nil {
+			result = ciphers
+		} else {
+			for i := range result {
+				result[i].Add(result[i], ciphers[i])
+			}
+		}
+	}
+
+	operation := libdrynx.PCAOperation(2, libdrynx.PCAParameters{})
+	assert.Len(t, result, operation.NbrOutput)
+	var pcar libdrynx.PCAResult
+	pcar.FromFloats(libdrynxencoding.Decode(result, secKey, operation), 2, 2)
+
+	assert.InDeltaSlice(t, []float64{stat.Mean(xs, nil), stat.Mean(ys, nil)}, pcar.Means, 1e-9)
+	// the total variance is the trace of the covariance matrix
+	assert.InDelta(t, stat.Variance(xs, nil)+stat.Variance(ys, nil), pcar.ExplainedVariance[0]+pcar.ExplainedVariance[1], 1e-9)
+	assert.True(t, pcar.ExplainedVariance[0] > pcar.ExplainedVariance[1])
+	assert.InDelta(t, 1, pcar.ExplainedVarianceRatio[0]+pcar.ExplainedVarianceRatio[1], 1e-12)
+	assert.InDeltaSlice(t, []float64{1 / math.Sqrt(5), 2 / math.Sqrt(5)}, pcar.Components[0], 0.05)
+	assert.InDelta(t, 0, pcar.Components[0][0]*pcar.Components[1][0]+pcar.Components[0][1]*pcar.Components[1][1], 1e-9)
+	assert.InDelta(t, math.Sqrt(pcar.ExplainedVariance[0])*pcar.Components[0][1], pcar.Loadings[0][1], 1e-12)
+
+	// the projection of the mean is the origin
+	assert.InDeltaSlice(t, []float64{0, 0}, libdrynxencoding.PCAProject(pcar.Means, pcar), 1e-12)
+}
+
+// TestPCAFromSums tests the analysis of a covariance matrix made indefinite by noise, and the number of components
+func TestPCAFromSums(t *testing.T) {
+	// 3 records of mean 0, whose covariance matrix [[0.5, 2.5], [2.5, 0.5]] has the eigenvalues 3 and -2
+	values := []float64{3, 0, 0, 1, 5, 1}
+
+	pcar, err := libdrynxencoding.PCAFromSums(values, libdrynx.PCAParameters{})
+	assert.NoError(t, err)
+	assert.InDeltaSlice(t, []float64{3, 0}, pcar.ExplainedVariance, 1e-9)
+	assert.InDeltaSlice(t, []float64{1, 0}, pcar.ExplainedVarianceRatio, 1e-9)
+	assert.InDeltaSlice(t, []float64{1 / math.Sqrt(2), 1 / math.Sqrt(2)}, pcar.Components[0], 1e-9)
+
+	pcar, err = libdrynxencoding.PCAFromSums(values, libdrynx.PCAParameters{Components: 1})
+	assert.NoError(t, err)
+	assert.Len(t, pcar.Components, 1)
+	var back libdrynx.PCAResult
+	back.FromFloats(pcar.ToFloats(), 2, 1)
+	assert.Equal(t, pcar, back)
+
+	_, err = libdrynxencoding.PCAFromSums(values[:5], libdrynx.PCAParameters{})
+	assert.Error(t, err)
+	_, err = libdrynxencoding.PCAFromSums([]float64{1, 0, 0, 1, 5, 1}, libdrynx.PCAParameters{})
+	assert.Error(t, err)
+}
+
+// TestEncodePCAWithProofs tests the range proofs of the sums and cross-products
+func TestEncodePCAWithProofs(t *testing.T) {
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	pubKey := keys.Public
+	records := [][]int64{{1, 2}, {3, 0}}
+
+	operation := libdrynx.PCAOperation(2, libdrynx.PCAParameters{})
+	ranges := make([]*[]int64, operation.NbrOutput)
+	ps := make([][]libdrynx.PublishSignature, 2)
+	for i := range ranges {
+		ranges[i] = &[]int64{16, 2}
+	}
+	for j := range ps {
+		ps[j] = make([]libdrynx.PublishSignature, operation.NbrOutput)
+		for i := range ps[j] {
+			ps[j][i] = libdrynxrange.PublishSignatureBytesToPublishSignatures(libdrynxrange.InitRangeProofSignature((*ranges[i])[0]))
+		}
+	}
+
+	_, clear, prf := libdrynxencoding.EncodePCAWithProofs(records, pubKey, ps, ranges)
+	assert.Equal(t, []int64{2, 4, 2, 10, 2, 4}, clear)
+	for i := range prf {
+		ys := []kyber.Point{ps[0][i].Public, ps[1][i].Public}
+		assert.True(t, libdrynxrange.RangeProofVerification(libdrynxrange.CreatePredicateRangeProofForAllServ(prf[i]), (*ranges[i])[0], (*ranges[i])[1], ys, pubKey))
+	}
+}
@@ -32,6 +32,15 @@ func EncodeSumWithProofs(input []int64, pubKey kyber.Point, sigs []libdrynx.Publ
 	return sumEncrypted, []int64{sum}, []libdrynxrange.CreateProof{cp}
 }
 
+// EncodeSumSquaresWithProofs computes the sum of the squares of query results with the proof of range
+func EncodeSumSquaresWithProofs(input []int64, pubKey kyber.Point, sigs []libdrynx.PublishSignature, l int64, u int64) (*libunlynx.CipherText, []int64, []libdrynxrange.CreateProof) {
+	squares := make([]int64, len(input))
+	for i, el := range input {
+		squares[i] = el * el
+	}
+	return EncodeSumWithProofs(squares, pubKey, sigs, l, u)
+}
+
 // DecodeSum computes the sum of local DP's query results
 func DecodeSum(result libunlynx.CipherText, secKey kyber.Scalar) int64 {
 	//decrypt the query results
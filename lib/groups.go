@@ -0,0 +1,151 @@
+package libdrynx
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ldsec/unlynx/data"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/kyber/v3"
+)
+
+// GroupLabels lists the labels of all the groups defined by GroupByValues, always in the same order
+func GroupLabels(groupByValues []int64) []string {
+	groups := make([][]int64, 0)
+	dataunlynx.AllPossibleGroups(groupByValues, make([]int64, 0), 0, &groups)
+
+	labels := make([]string, len(groups))
+	for i, v := range groups {
+		labels[i] = fmt.Sprint(v)
+	}
+	return labels
+}
+
+// GroupIndex gives the position of a group in GroupLabels plus one, so that no group is encoded as zero
+func GroupIndex(groupByValues []int64, label string) (int64, error) {
+	for i, v := range GroupLabels(groupByValues) {
+		if v == label {
+			return int64(i + 1), nil
+		}
+	}
+	return 0, errors.New("unknown group " + label)
+}
+
+// EncryptGroup encrypts a group under the collective key so that the nodes do not learn it. The group is encoded as its
+// GroupIndex.
+func EncryptGroup(pubKey kyber.Point, groupByValues []int64, label string) (string, error) {
+	index, err := GroupIndex(groupByValues, label)
+	if err != nil {
+		return "", err
+	}
+	return libunlynx.EncryptInt(pubKey, index).Serialize()
+}
+
+// DecryptGroup gives back the label of an encrypted group, once it is switched to the key of the querier
+func DecryptGroup(privKey kyber.Scalar, groupByValues []int64, encrypted string) (string, error) {
+	ct, err := libunlynx.NewCipherTextFromBase64(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	labels := GroupLabels(groupByValues)
+	index := libunlynx.DecryptInt(privKey, *ct) - 1
+	if index < 0 || index >= int64(len(labels)) {
+		return "", errors.New("encrypted group is not in the groups of the query")
+	}
+	return labels[index], nil
+}
+
+// MergeTaggedGroups aggregates the responses whose encrypted groups have the same deterministic tag. The encrypted
+// group of the first response with a tag is kept, the groups are in the order in which their tags first appear.
+func MergeTaggedGroups(rad ResponseAllDPs, tags []libunlynx.DeterministCipherText) (*ResponseAllDPs, error) {
+	if len(tags) != len(rad.Data) {
+		return nil, errors.New("there are not as many tags as groups")
+	}
+
+	merged := make([]ResponseDPOneGroup, 0)
+	indexes := make(map[string]int)
+	for i, v := range rad.Data {
+		tag := tags[i].String()
+		if j, ok := indexes[tag]; ok {
+			sum := libunlynx.NewCipherVector(len(v.Data))
+			sum.Add(merged[j].Data, v.Data)
+			merged[j].Data = *sum
+		} else {
+			indexes[tag] = len(merged)
+			merged = append(merged, ResponseDPOneGroup{Group: v.Group, Data: v.Data})
+		}
+	}
+	return &ResponseAllDPs{Data: merged}, nil
+}
+
+// GroupSizeTests gives, for each group, the encrypted differences between its size and each size below minSize, each
+// in a row after the position of the group plus one. The size of a group is the last value of its data. A difference is
+// zero only if the group has less than minSize records. The differences are obfuscated and the rows shuffled before
+// they are tagged (see SizeTestsTaggingTarget), so that the nodes only learn if a group has a zero difference.
+func GroupSizeTests(rad ResponseAllDPs, minSize int64) []libunlynx.CipherVector {
+	tests := make([]libunlynx.CipherVector, 0, len(rad.Data)*int(minSize))
+	for i, v := range rad.Data {
+		size := v.Data[len(v.Data)-1]
+		for j := int64(0); j < minSize; j++ {
+			diff := libunlynx.CipherText{K: size.K, C: libunlynx.SuiTe.Point().Sub(size.C, libunlynx.IntToPoint(j))}
+			tests = append(tests, libunlynx.CipherVector{trivialEncryption(int64(i + 1)), diff})
+		}
+	}
+	return tests
+}
+
+// SizeTestsTaggingTarget lists the values to tag to find the small groups from their obfuscated and shuffled size tests:
+// the two values of each test, the position plus one of each group, and a zero for the tags of the differences to be
+// compared with
+func SizeTestsTaggingTarget(tests []libunlynx.CipherVector, nbrGroups int) libunlynx.CipherVector {
+	target := make(libunlynx.CipherVector, 0, 2*len(tests)+nbrGroups+1)
+	for _, v := range tests {
+		target = append(target, v...)
+	}
+	for i := 0; i < nbrGroups; i++ {
+		target = append(target, trivialEncryption(int64(i+1)))
+	}
+	return append(target, trivialEncryption(0))
+}
+
+// SuppressSmallGroups withholds the groups with a zero difference in their size tests, given the deterministic tags of
+// SizeTestsTaggingTarget, and removes the sizes from the data of the other groups
+func SuppressSmallGroups(rad ResponseAllDPs, tags []libunlynx.DeterministCipherText, minSize int64) (*ResponseAllDPs, error) {
+	nbrTests := len(rad.Data) * int(minSize)
+	if len(tags) != 2*nbrTests+len(rad.Data)+1 {
+		return nil, errors.New("there are not as many tags as size tests")
+	}
+
+	groups := make(map[string]int, len(rad.Data))
+	for i, tag := range tags[2*nbrTests : len(tags)-1] {
+		groups[tag.String()] = i
+	}
+	zero := tags[len(tags)-1]
+	small := make([]bool, len(rad.Data))
+	for i := 0; i < nbrTests; i++ {
+		group, ok := groups[tags[2*i].String()]
+		if !ok {
+			return nil, errors.New("size test of an unknown group")
+		}
+		if tags[2*i+1].Equal(&zero) {
+			small[group] = true
+		}
+	}
+
+	kept := make([]ResponseDPOneGroup, 0)
+	suppressed := append(make([]string, 0), rad.Suppressed...)
+	for i, v := range rad.Data {
+		if small[i] {
+			suppressed = append(suppressed, v.Group)
+		} else {
+			kept = append(kept, ResponseDPOneGroup{Group: v.Group, Data: v.Data[:len(v.Data)-1]})
+		}
+	}
+	return &ResponseAllDPs{Data: kept, Suppressed: suppressed}, nil
+}
+
+// trivialEncryption encrypts a value without randomness
+func trivialEncryption(v int64) libunlynx.CipherText {
+	return libunlynx.CipherText{K: libunlynx.SuiTe.Point().Null(), C: libunlynx.IntToPoint(v)}
+}
@@ -0,0 +1,127 @@
+package libdrynx_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/ldsec/unlynx/lib/deterministic_tag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.dedis.ch/kyber/v3/util/random"
+)
+
+// TestEncryptGroup tests the encryption of the groups and the merge of the ones with the same tag
+func TestEncryptGroup(t *testing.T) {
+	groupByValues := []int64{2, 3}
+	labels := libdrynx.GroupLabels(groupByValues)
+	assert.Equal(t, []string{"[0 0]", "[0 1]", "[0 2]", "[1 0]", "[1 1]", "[1 2]"}, labels)
+
+	secKey, pubKey := libunlynx.GenKey()
+	for _, label := range labels {
+		encrypted, err := libdrynx.EncryptGroup(pubKey, groupByValues, label)
+		require.NoError(t, err)
+		decrypted, err := libdrynx.DecryptGroup(secKey, groupByValues, encrypted)
+		require.NoError(t, err)
+		assert.Equal(t, label, decrypted)
+	}
+	_, err := libdrynx.EncryptGroup(pubKey, groupByValues, "[2 0]")
+	assert.Error(t, err)
+	index, err := libdrynx.GroupIndex(groupByValues, "[1 0]")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), index)
+
+	// with proofs, the encrypted group is proven to be between 1 and 6, each side in [0, 2^3)
+	query := libdrynx.Query{Operation: libdrynx.Operation{NbrOutput: 1}, EncryptedGroups: true, DPDataGen: libdrynx.QueryDPDataGen{GroupByValues: groupByValues}}
+	assert.Empty(t, libdrynx.QueryRangeBounds(query))
+	query.Ranges = []*[]int64{{16, 2}}
+	bounds := libdrynx.QueryRangeBounds(query)
+	assert.Equal(t, []libdrynx.RangeBound{{Min: 1, Max: 6}}, bounds)
+	assert.Equal(t, []*[]int64{{2, 3}, {2, 3}}, libdrynx.BoundRanges(bounds))
+	assert.Equal(t, 3, libdrynx.NbrRanges(query))
+
+	// the tags stand for the groups [0 0], [0 1], [0 0]
+	responses := libdrynx.ResponseAllDPs{Data: []libdrynx.ResponseDPOneGroup{
+		{Group: "a", Data: *libunlynx.EncryptIntVector(pubKey, []int64{1, 2})},
+		{Group: "b", Data: *libunlynx.EncryptIntVector(pubKey, []int64{3, 4})},
+		{Group: "c", Data: *libunlynx.EncryptIntVector(pubKey, []int64{5, 6})},
+	}}
+	tags := []libunlynx.DeterministCipherText{
+		{Point: libunlynx.IntToPoint(1)},
+		{Point: libunlynx.IntToPoint(2)},
+		{Point: libunlynx.IntToPoint(1)},
+	}
+	merged, err := libdrynx.MergeTaggedGroups(responses, tags)
+	require.NoError(t, err)
+	require.Len(t, merged.Data, 2)
+	assert.Equal(t, "a", merged.Data[0].Group)
+	assert.Equal(t, []int64{6, 8}, libunlynx.DecryptIntVector(secKey, &merged.Data[0].Data))
+	assert.Equal(t, "b", merged.Data[1].Group)
+	assert.Equal(t, []int64{3, 4}, libunlynx.DecryptIntVector(secKey, &merged.Data[1].Data))
+
+	_, err = libdrynx.MergeTaggedGroups(responses, tags[:2])
+	assert.Error(t, err)
+}
+
+// TestSuppressSmallGroups tests the suppression of the groups with too few records through the tags of their sizes
+func TestSuppressSmallGroups(t *testing.T) {
+	secKey, pubKey := libunlynx.GenKey()
+	minSize := int64(3)
+
+	// the last value is the size of the group
+	responses := libdrynx.ResponseAllDPs{Data: []libdrynx.ResponseDPOneGroup{
+		{Group: "a", Data: *libunlynx.EncryptIntVector(pubKey, []int64{10, 5})},
+		{Group: "b", Data: *libunlynx.EncryptIntVector(pubKey, []int64{1, 2})},
+		{Group: "c", Data: *libunlynx.EncryptIntVector(pubKey, []int64{7, 3})},
+		{Group: "d", Data: *libunlynx.EncryptIntVector(pubKey, []int64{0, 0})},
+	}}
+	tests := libdrynx.GroupSizeTests(responses, minSize)
+	require.Len(t, tests, len(responses.Data)*int(minSize))
+
+	// obfuscation and shuffling of the tests by a single node
+	obfuscated := make([]libunlynx.CipherVector, len(tests))
+	for i, j := range rand.Perm(len(tests)) {
+		diff := libunlynx.CipherText{}
+		diff.MulCipherTextbyScalar(tests[j][1], libunlynx.SuiTe.Scalar().Pick(random.New()))
+		obfuscated[i] = libunlynx.CipherVector{tests[j][0], diff}
+		obfuscated[i].Add(obfuscated[i], *libunlynx.EncryptIntVector(pubKey, []int64{0, 0}))
+	}
+	target := libdrynx.SizeTestsTaggingTarget(obfuscated, len(responses.Data))
+
+	// deterministic tagging by a single node
+	secret := libunlynx.SuiTe.Scalar().Pick(random.New())
+	for i := range target {
+		target[i].C = libunlynx.SuiTe.Point().Add(target[i].C, libunlynx.SuiTe.Point().Mul(secret, nil))
+	}
+	tagged := libunlynxdetertag.DeterministicTagSequence(target, secKey, secret)
+	tags := make([]libunlynx.DeterministCipherText, len(tagged))
+	for i, v := range tagged {
+		tags[i] = libunlynx.DeterministCipherText{Point: v.C}
+	}
+
+	// the differences are either zero or distinct, they do not tell the sizes apart
+	zeros := 0
+	differences := make(map[string]bool)
+	for i := range tests {
+		if tags[2*i+1].Equal(&tags[len(tags)-1]) {
+			zeros++
+		} else {
+			differences[tags[2*i+1].String()] = true
+		}
+	}
+	assert.Equal(t, 2, zeros)
+	assert.Len(t, differences, len(tests)-zeros)
+
+	result, err := libdrynx.SuppressSmallGroups(responses, tags, minSize)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b", "d"}, result.Suppressed)
+	require.Len(t, result.Data, 2)
+	assert.Equal(t, "a", result.Data[0].Group)
+	assert.Equal(t, []int64{10}, libunlynx.DecryptIntVector(secKey, &result.Data[0].Data))
+	assert.Equal(t, "c", result.Data[1].Group)
+	assert.Equal(t, []int64{7}, libunlynx.DecryptIntVector(secKey, &result.Data[1].Data))
+
+	_, err = libdrynx.SuppressSmallGroups(responses, tags[1:], minSize)
+	assert.Error(t, err)
+}
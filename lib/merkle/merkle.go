@@ -0,0 +1,82 @@
+package libdrynxmerkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+)
+
+// Step is a sibling on the path from a leaf to the root of a Merkle tree
+type Step struct {
+	Hash []byte
+	Left bool // the sibling is on the left of the node
+}
+
+// Leaf computes the leaf of a proof from its identifier and the hash of its data
+func Leaf(proofID string, dataHash []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(dataHash)
+	h.Write([]byte(proofID))
+	return h.Sum(nil)
+}
+
+func node(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// nextLevel hashes the nodes of a level by pairs, the last node of an odd level is moved up as is
+func nextLevel(level [][]byte) [][]byte {
+	next := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 < len(level) {
+			next = append(next, node(level[i], level[i+1]))
+		} else {
+			next = append(next, level[i])
+		}
+	}
+	return next
+}
+
+// Root computes the root of the Merkle tree over the leaves, nil if there are none
+func Root(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		return nil
+	}
+	level := leaves
+	for len(level) > 1 {
+		level = nextLevel(level)
+	}
+	return level[0]
+}
+
+// Path computes the siblings from the leaf at index to the root of the Merkle tree over the leaves
+func Path(leaves [][]byte, index int) []Step {
+	path := make([]Step, 0)
+	level := leaves
+	for len(level) > 1 {
+		sibling := index ^ 1
+		if sibling < len(level) {
+			path = append(path, Step{Hash: level[sibling], Left: sibling < index})
+		}
+		level = nextLevel(level)
+		index /= 2
+	}
+	return path
+}
+
+// Verify checks that the path leads from the leaf to the root
+func Verify(leaf []byte, path []Step, root []byte) bool {
+	current := leaf
+	for _, step := range path {
+		if step.Left {
+			current = node(step.Hash, current)
+		} else {
+			current = node(current, step.Hash)
+		}
+	}
+	return len(root) > 0 && bytes.Equal(current, root)
+}
@@ -0,0 +1,39 @@
+package libdrynxmerkle_test
+
+import (
+	"crypto/sha256"
+	"strconv"
+	"testing"
+
+	"github.com/ldsec/drynx/lib/merkle"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMerklePath tests that the path of each leaf of trees of several sizes leads to their root and not from another
+// leaf, and that a leaf depends on both the proof and its data
+func TestMerklePath(t *testing.T) {
+	assert.Nil(t, libdrynxmerkle.Root(nil))
+
+	for _, nbrLeaves := range []int{1, 2, 3, 7, 8, 13} {
+		leaves := make([][]byte, nbrLeaves)
+		for i := range leaves {
+			hash := sha256.Sum256([]byte("data" + strconv.Itoa(i)))
+			leaves[i] = libdrynxmerkle.Leaf("proof"+strconv.Itoa(i), hash[:])
+		}
+		root := libdrynxmerkle.Root(leaves)
+
+		for i := range leaves {
+			path := libdrynxmerkle.Path(leaves, i)
+			assert.True(t, libdrynxmerkle.Verify(leaves[i], path, root), "leaf %d of %d", i, nbrLeaves)
+			if nbrLeaves > 1 {
+				assert.False(t, libdrynxmerkle.Verify(leaves[(i+1)%nbrLeaves], path, root), "leaf %d of %d", i, nbrLeaves)
+			}
+		}
+	}
+
+	// a leaf depends on the proof and on its data
+	hash := sha256.Sum256([]byte("data"))
+	other := sha256.Sum256([]byte("other"))
+	assert.NotEqual(t, libdrynxmerkle.Leaf("proof", hash[:]), libdrynxmerkle.Leaf("proof", other[:]))
+	assert.NotEqual(t, libdrynxmerkle.Leaf("proof", hash[:]), libdrynxmerkle.Leaf("other", hash[:]))
+}
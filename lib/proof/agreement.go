@@ -0,0 +1,116 @@
+package drynxproof
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+// Agreement is the outcome of the agreement of the VNs on the verdicts of a bitmap
+type Agreement struct {
+	Verdicts      map[string]int64 // verdict agreed on for each proof (by proof ID)
+	Disagreements []libdrynx.Disagreement
+	Deviations    map[string]int64 // number of verdicts of each VN opposite to the agreed one
+}
+
+// FaultTolerance is the number f of faulty VNs tolerated among nbrVNs (nbrVNs >= 3f+1)
+func FaultTolerance(nbrVNs int) int {
+	if nbrVNs < 1 {
+		return 0
+	}
+	return (nbrVNs - 1) / 3
+}
+
+// OwnVerdicts keeps the verdicts of a bitmap that were given by the VN with the given address, as a VN can only
+// report its own verdicts
+func OwnVerdicts(bitmap map[string]int64, vnAddress string) map[string]int64 {
+	own := make(map[string]int64)
+	for key, verdict := range bitmap {
+		if strings.HasSuffix(key, "/"+vnAddress) {
+			own[key] = verdict
+		}
+	}
+	return own
+}
+
+// Agree computes the verdict of the VNs (given by their addresses) on each proof of a bitmap. With at most f faulty
+// VNs, a proof rejected by at least f+1 VNs was rejected by an honest one: it is rejected. Otherwise, a proof verified
+// by at least f+1 VNs was verified by an honest one: it is accepted. A proof rejected by fewer VNs, without enough
+// verifications, is undecided. Any other proof stays unchecked.
+func Agree(bitmap map[string]int64, vns []string) Agreement {
+	valid := make(map[string][]string)
+	rejected := make(map[string][]string)
+	proofIDs := make(map[string]bool)
+	for key, verdict := range bitmap {
+		for _, vn := range vns {
+			if !strings.HasSuffix(key, "/"+vn) {
+				continue
+			}
+			proofID := strings.TrimSuffix(key, "/"+vn)
+			proofIDs[proofID] = true
+			switch verdict {
+			case ProofTrue:
+				valid[proofID] = append(valid[proofID], vn)
+			case proofFalse, proofFalseSign:
+				rejected[proofID] = append(rejected[proofID], vn)
+			}
+			break
+		}
+	}
+
+	quorum := FaultTolerance(len(vns)) + 1
+	agreement := Agreement{Verdicts: make(map[string]int64), Disagreements: make([]libdrynx.Disagreement, 0), Deviations: make(map[string]int64)}
+	for proofID := range proofIDs {
+		sort.Strings(valid[proofID])
+		sort.Strings(rejected[proofID])
+
+		var deviating []string
+		if len(rejected[proofID]) >= quorum {
+			agreement.Verdicts[proofID] = proofFalse
+			deviating = valid[proofID]
+		} else if len(valid[proofID]) >= quorum {
+			agreement.Verdicts[proofID] = ProofTrue
+			deviating = rejected[proofID]
+		} else if len(rejected[proofID]) > 0 {
+			agreement.Verdicts[proofID] = proofUndecided
+		} else {
+			agreement.Verdicts[proofID] = proofReceived
+		}
+		for _, vn := range deviating {
+			agreement.Deviations[vn]++
+		}
+
+		if len(valid[proofID]) > 0 && len(rejected[proofID]) > 0 {
+			agreement.Disagreements = append(agreement.Disagreements, libdrynx.Disagreement{ProofID: proofID, Valid: valid[proofID], Rejected: rejected[proofID]})
+		}
+	}
+	sort.Slice(agreement.Disagreements, func(i, j int) bool {
+		return agreement.Disagreements[i].ProofID < agreement.Disagreements[j].ProofID
+	})
+	return agreement
+}
+
+// Matches tells whether the agreement is the one recorded in a block
+func (a Agreement) Matches(dataBlock *libdrynx.DataBlock) bool {
+	if len(a.Verdicts) != len(dataBlock.Agreed) || len(a.Deviations) != len(dataBlock.Deviations) || len(a.Disagreements) != len(dataBlock.Disagreements) {
+		return false
+	}
+	for proofID, verdict := range a.Verdicts {
+		if recorded, ok := dataBlock.Agreed[proofID]; !ok || recorded != verdict {
+			return false
+		}
+	}
+	for vn, count := range a.Deviations {
+		if dataBlock.Deviations[vn] != count {
+			return false
+		}
+	}
+	for i, d := range a.Disagreements {
+		recorded := dataBlock.Disagreements[i]
+		if d.ProofID != recorded.ProofID || strings.Join(d.Valid, " ") != strings.Join(recorded.Valid, " ") || strings.Join(d.Rejected, " ") != strings.Join(recorded.Rejected, " ") {
+			return false
+		}
+	}
+	return true
+}
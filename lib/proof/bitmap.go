@@ -0,0 +1,127 @@
+package drynxproof
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ldsec/drynx/lib"
+)
+
+// ProofTypes are the names of the proofs (as used in the bitmaps and DB buckets) in the order returned by
+// libdrynx.QueryToProofsNbrs
+var ProofTypes = []string{"range", "shuffle", "aggregation", "obfuscation", "keyswitch"}
+
+// ProofFailure describes a proof type that did not reach its threshold in the bitmap of a block
+type ProofFailure struct {
+	Type      string
+	Expected  int      // number of proofs expected
+	Accepted  int      // number of proofs the VNs agreed to accept, or that too few VNs drew to verify
+	Rejected  []string // IDs of the proofs the VNs agreed to reject
+	Undecided []string // IDs of the proofs rejected by too few VNs to agree on a verdict
+}
+
+// ProofTypeThresholds returns, for each proof type, the fraction of the expected verdicts that must be accepted. A
+// shuffle proof has no threshold of its own, all of them must be accepted (sq.Threshold is a sampling rate).
+func ProofTypeThresholds(sq libdrynx.SurveyQuery) map[string]float64 {
+	return map[string]float64{
+		"range":       sq.RangeProofThreshold,
+		"shuffle":     1.0,
+		"aggregation": sq.AggregationProofThreshold,
+		"obfuscation": sq.ObfuscationProofThreshold,
+		"keyswitch":   sq.KeySwitchingProofThreshold,
+	}
+}
+
+// ProofType extracts the type of proof from a bitmap key (surveyID/type/sender/differInfo/VN)
+func ProofType(surveyID, key string) string {
+	return strings.SplitN(strings.TrimPrefix(key, surveyID+"/"), "/", 2)[0]
+}
+
+// CheckBitmap checks the verdicts of a block's bitmap, given by the VNs with the given addresses, against the proofs
+// required by the query. The VNs first agree on a verdict for each proof (see Agree). A proof type then fails if one
+// of its proofs was rejected or undecided, or if less than its threshold of the expected proofs were accepted. A proof
+// that was not verified by enough VNs does not count as accepted, unless too few of them drew it with the sampling seed
+// of the block: the sampling did not ask for its verification.
+func CheckBitmap(sq libdrynx.SurveyQuery, bitmap map[string]int64, vns []string, seed []byte) []ProofFailure {
+	expected := make(map[string]int)
+	for i, nbr := range libdrynx.QueryToProofsNbrs(sq) {
+		expected[ProofTypes[i]] = nbr
+	}
+
+	rates := sq.SamplingRates()
+	quorum := FaultTolerance(len(vns)) + 1
+	accepted := make(map[string]int)
+	rejected := make(map[string][]string)
+	undecided := make(map[string][]string)
+	for proofID, verdict := range Agree(bitmap, vns).Verdicts {
+		typeProof := ProofType(sq.SurveyID, proofID)
+		switch verdict {
+		case ProofTrue:
+			accepted[typeProof]++
+		case proofUndecided:
+			undecided[typeProof] = append(undecided[typeProof], proofID)
+		case proofReceived:
+			if drawn(seed, proofID, rates.Rate(typeProof), vns) < quorum {
+				accepted[typeProof]++
+			}
+		default:
+			rejected[typeProof] = append(rejected[typeProof], proofID)
+		}
+	}
+
+	thresholds := ProofTypeThresholds(sq)
+	failures := make([]ProofFailure, 0)
+	for _, typeProof := range ProofTypes {
+		if expected[typeProof] == 0 {
+			continue
+		}
+		sort.Strings(rejected[typeProof])
+		sort.Strings(undecided[typeProof])
+		if len(rejected[typeProof]) > 0 || len(undecided[typeProof]) > 0 || float64(accepted[typeProof]) < thresholds[typeProof]*float64(expected[typeProof]) {
+			failures = append(failures, ProofFailure{Type: typeProof, Expected: expected[typeProof], Accepted: accepted[typeProof], Rejected: rejected[typeProof], Undecided: undecided[typeProof]})
+		}
+	}
+	return failures
+}
+
+// drawn counts the VNs that had to verify a proof, given by its ID, with a sampling seed and rate
+func drawn(seed []byte, proofID string, rate float64, vns []string) int {
+	count := 0
+	for _, vn := range vns {
+		if Sampled(seed, proofID+"/"+vn, rate) {
+			count++
+		}
+	}
+	return count
+}
+
+// VerdictName gives a readable name to a verdict of the bitmap
+func VerdictName(verdict int64) string {
+	switch verdict {
+	case ProofTrue:
+		return "verified"
+	case proofReceived:
+		return "unchecked"
+	case proofUndecided:
+		return "undecided"
+	case proofFalse:
+		return "rejected"
+	case proofFalseSign:
+		return "bad-signature"
+	default:
+		return "unknown"
+	}
+}
+
+// CountVerdicts counts, for each type of proof, the number of verdicts of each kind (see VerdictName)
+func CountVerdicts(surveyID string, bitmap map[string]int64) map[string]map[string]int {
+	counts := make(map[string]map[string]int)
+	for key, verdict := range bitmap {
+		typeProof := ProofType(surveyID, key)
+		if _, ok := counts[typeProof]; !ok {
+			counts[typeProof] = make(map[string]int)
+		}
+		counts[typeProof][VerdictName(verdict)]++
+	}
+	return counts
+}
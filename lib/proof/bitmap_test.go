@@ -0,0 +1,165 @@
+package drynxproof_test
+
+import (
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/proof"
+	"github.com/stretchr/testify/assert"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+)
+
+func bitmapQuery() libdrynx.SurveyQuery {
+	local := onet.NewLocalTest(libdrynx.Suite)
+	defer local.CloseAll()
+	_, roster, _ := local.GenTree(3, false)
+
+	return libdrynx.SurveyQuery{
+		SurveyID:                   "query-bitmap",
+		RosterServers:              *roster,
+		ServerToDP:                 map[string]*[]network.ServerIdentity{roster.List[0].String(): {*roster.List[1]}},
+		Query:                      libdrynx.Query{Proofs: 1},
+		Threshold:                  1.0,
+		AggregationProofThreshold:  1.0,
+		RangeProofThreshold:        1.0,
+		KeySwitchingProofThreshold: 1.0,
+	}
+}
+
+func fillBitmap(sq libdrynx.SurveyQuery, vns []string, verdict int64) map[string]int64 {
+	bitmap := make(map[string]int64)
+	for _, vn := range vns {
+		for _, dp := range *sq.ServerToDP[sq.RosterServers.List[0].String()] {
+			bitmap[sq.SurveyID+"/range/"+dp.String()+"//"+vn] = verdict
+		}
+		for _, cn := range sq.RosterServers.List {
+			bitmap[sq.SurveyID+"/aggregation/"+cn.String()+"//"+vn] = verdict
+			bitmap[sq.SurveyID+"/keyswitch/"+cn.String()+"//"+vn] = verdict
+		}
+	}
+	return bitmap
+}
+
+// TestCheckBitmap tests that a bitmap is only accepted when all the required proofs reached their threshold
+func TestCheckBitmap(t *testing.T) {
+	sq := bitmapQuery()
+	vns := []string{"vn1", "vn2", "vn3", "vn4"}
+
+	assert.Empty(t, drynxproof.CheckBitmap(sq, fillBitmap(sq, vns, drynxproof.ProofTrue), vns, nil))
+
+	// a single VN rejecting a proof is tolerated
+	bitmap := fillBitmap(sq, vns, drynxproof.ProofTrue)
+	proofID := sq.SurveyID + "/keyswitch/" + sq.RosterServers.List[1].String() + "/"
+	bitmap[proofID+"/vn2"] = 0
+	assert.Empty(t, drynxproof.CheckBitmap(sq, bitmap, vns, nil))
+
+	// but not f+1 of them
+	bitmap[proofID+"/vn3"] = 0
+	bitmap[proofID+"/vn4"] = 0
+	failures := drynxproof.CheckBitmap(sq, bitmap, vns, nil)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, "keyswitch", failures[0].Type)
+	assert.Equal(t, []string{proofID}, failures[0].Rejected)
+
+	// missing proofs make the types fail
+	failures = drynxproof.CheckBitmap(sq, fillBitmap(sq, vns, drynxproof.ProofTrue), vns[:0], nil)
+	assert.Len(t, failures, 3)
+	assert.Equal(t, 0, failures[0].Accepted)
+	assert.Equal(t, 1, failures[0].Expected)
+
+	bitmap = fillBitmap(sq, vns, drynxproof.ProofTrue)
+	for _, vn := range vns {
+		delete(bitmap, proofID+"/"+vn)
+	}
+	failures = drynxproof.CheckBitmap(sq, bitmap, vns, nil)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, 3, failures[0].Expected)
+	assert.Equal(t, 2, failures[0].Accepted)
+
+	// unless the threshold allows it
+	sq.KeySwitchingProofThreshold = 0.5
+	assert.Empty(t, drynxproof.CheckBitmap(sq, bitmap, vns, nil))
+	sq.KeySwitchingProofThreshold = 1.0
+
+	// a proof not verified by enough VNs is not accepted
+	bitmap = fillBitmap(sq, vns, drynxproof.ProofTrue)
+	for _, vn := range vns[1:] {
+		bitmap[proofID+"/"+vn] = 2
+	}
+	failures = drynxproof.CheckBitmap(sq, bitmap, vns, nil)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, 2, failures[0].Accepted)
+	assert.Empty(t, failures[0].Rejected)
+	assert.Empty(t, failures[0].Undecided)
+
+	// unless the sampling did not ask enough VNs to verify it
+	sampled := sq
+	sampled.Sampling = &libdrynx.ProofsSampling{Range: 1, Shuffle: 1, Aggregation: 1, Obfuscation: 1, KeySwitch: 0}
+	assert.Empty(t, drynxproof.CheckBitmap(sampled, bitmap, vns, nil))
+	// with a rate below 1, the seed of the block tells whether f+1 VNs drew it
+	sampled.Sampling.KeySwitch = 0.5
+	outcomes := make(map[bool]bool)
+	for i := 0; i < 20; i++ {
+		seed := []byte{byte(i)}
+		drawn := 0
+		for _, vn := range vns {
+			if drynxproof.Sampled(seed, proofID+"/"+vn, 0.5) {
+				drawn++
+			}
+		}
+		assert.Equal(t, drawn < 2, len(drynxproof.CheckBitmap(sampled, bitmap, vns, seed)) == 0)
+		outcomes[drawn < 2] = true
+	}
+	assert.Len(t, outcomes, 2)
+
+	// and a rejection without quorum on either side makes it fail
+	bitmap[proofID+"/vn2"] = 0
+	failures = drynxproof.CheckBitmap(sq, bitmap, vns, nil)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, []string{proofID}, failures[0].Undecided)
+
+	// with no faulty VN tolerated, a single rejection is enough
+	bitmap = fillBitmap(sq, vns[:3], drynxproof.ProofTrue)
+	bitmap[proofID+"/vn3"] = 0
+	failures = drynxproof.CheckBitmap(sq, bitmap, vns[:3], nil)
+	assert.Len(t, failures, 1)
+	assert.Equal(t, []string{proofID}, failures[0].Rejected)
+}
+
+// TestAgree tests the agreement of the VNs on the verdicts of a bitmap
+func TestAgree(t *testing.T) {
+	vns := []string{"local://vn1", "local://vn2", "local://vn3", "local://vn4"}
+	assert.Equal(t, 1, drynxproof.FaultTolerance(len(vns)))
+
+	bitmap := map[string]int64{
+		// verified by all
+		"s/range/dp1//local://vn1": 1, "s/range/dp1//local://vn2": 1, "s/range/dp1//local://vn3": 1, "s/range/dp1//local://vn4": 1,
+		// vn4 rejects a valid proof
+		"s/range/dp2//local://vn1": 1, "s/range/dp2//local://vn2": 1, "s/range/dp2//local://vn3": 2, "s/range/dp2//local://vn4": 0,
+		// vn4 accepts an invalid proof
+		"s/range/dp3//local://vn1": 0, "s/range/dp3//local://vn2": 4, "s/range/dp3//local://vn3": 2, "s/range/dp3//local://vn4": 1,
+		// not enough verifications
+		"s/range/dp4//local://vn1": 1, "s/range/dp4//local://vn2": 2, "s/range/dp4//local://vn3": 2, "s/range/dp4//local://vn4": 2,
+		// a single rejection without enough verifications
+		"s/range/dp6//local://vn1": 1, "s/range/dp6//local://vn2": 0, "s/range/dp6//local://vn3": 2, "s/range/dp6//local://vn4": 2,
+		// verdict of an unknown VN
+		"s/range/dp5//local://vn5": 0,
+	}
+
+	agreement := drynxproof.Agree(bitmap, vns)
+	assert.Equal(t, map[string]int64{"s/range/dp1/": 1, "s/range/dp2/": 1, "s/range/dp3/": 0, "s/range/dp4/": 2, "s/range/dp6/": 3}, agreement.Verdicts)
+	assert.Equal(t, "undecided", drynxproof.VerdictName(agreement.Verdicts["s/range/dp6/"]))
+	assert.Equal(t, map[string]int64{"local://vn4": 2}, agreement.Deviations)
+	assert.Equal(t, []libdrynx.Disagreement{
+		{ProofID: "s/range/dp2/", Valid: []string{"local://vn1", "local://vn2"}, Rejected: []string{"local://vn4"}},
+		{ProofID: "s/range/dp3/", Valid: []string{"local://vn4"}, Rejected: []string{"local://vn1", "local://vn2"}},
+		{ProofID: "s/range/dp6/", Valid: []string{"local://vn1"}, Rejected: []string{"local://vn2"}},
+	}, agreement.Disagreements)
+
+	assert.True(t, agreement.Matches(&libdrynx.DataBlock{Agreed: agreement.Verdicts, Disagreements: agreement.Disagreements, Deviations: agreement.Deviations}))
+	assert.False(t, agreement.Matches(&libdrynx.DataBlock{Agreed: agreement.Verdicts, Disagreements: agreement.Disagreements}))
+
+	// a VN can only report its own verdicts
+	assert.Equal(t, map[string]int64{"s/range/dp5//local://vn5": 0}, drynxproof.OwnVerdicts(bitmap, "local://vn5"))
+}
@@ -0,0 +1,44 @@
+package drynxproof
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// ProofID identifies a proof independently of the VN storing it (SurveyID + type_of_proof + senderID + differInfo)
+func ProofID(surveyID, typeProof, senderID, differInfo string) string {
+	return surveyID + "/" + typeProof + "/" + senderID + "/" + differInfo
+}
+
+// ProofKey is the key of a proof in the bitmap and in the DB of a VN
+// (SurveyID + type_of_proof + senderID + differInfo + serverID)
+func ProofKey(surveyID, typeProof, senderID, differInfo, vnAddress string) string {
+	return ProofID(surveyID, typeProof, senderID, differInfo) + "/" + vnAddress
+}
+
+// SamplingSeed derives the seed of the sampling of a survey from the seed of its query and the root of the Merkle tree
+// over its proofs. The root is only known once every proof is committed, so no sender can tell in advance which of its
+// proofs will be verified.
+func SamplingSeed(querySeed, proofsRoot []byte) []byte {
+	h := sha256.New()
+	h.Write(querySeed)
+	h.Write(proofsRoot)
+	return h.Sum(nil)
+}
+
+// Sampled tells whether a VN has to verify the proof with the given key. The choice is derived from the sampling seed
+// of the survey and the key of the proof so that anyone holding the block can reproduce it.
+func Sampled(seed []byte, key string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	if rate <= 0 {
+		return false
+	}
+
+	h := sha256.New()
+	h.Write(seed)
+	h.Write([]byte(key))
+	draw := float64(binary.BigEndian.Uint64(h.Sum(nil))>>11) / float64(uint64(1)<<53)
+	return draw < rate
+}
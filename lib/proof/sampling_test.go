@@ -0,0 +1,44 @@
+package drynxproof_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ldsec/drynx/lib/proof"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSampled tests that the choice of the verified proofs is reproducible and follows the sampling rate
+func TestSampled(t *testing.T) {
+	seed := drynxproof.SamplingSeed([]byte("latest-block-hash"), []byte("proofs-root"))
+	assert.Equal(t, seed, drynxproof.SamplingSeed([]byte("latest-block-hash"), []byte("proofs-root")))
+	// the seed changes with the committed proofs
+	assert.NotEqual(t, seed, drynxproof.SamplingSeed([]byte("latest-block-hash"), []byte("other-root")))
+
+	key := drynxproof.ProofKey("query-sampling", "range", "dp", "", "vn")
+	assert.Equal(t, "query-sampling/range/dp//vn", key)
+
+	assert.True(t, drynxproof.Sampled(seed, key, 1))
+	assert.False(t, drynxproof.Sampled(seed, key, 0))
+
+	nbrKeys := 10000
+	sampled := 0
+	for i := 0; i < nbrKeys; i++ {
+		key := drynxproof.ProofKey("query-sampling", "range", "dp", strconv.Itoa(i), "vn")
+		if drynxproof.Sampled(seed, key, 0.3) {
+			sampled++
+		}
+		assert.Equal(t, drynxproof.Sampled(seed, key, 0.3), drynxproof.Sampled(seed, key, 0.3))
+	}
+	assert.InDelta(t, 0.3, float64(sampled)/float64(nbrKeys), 0.03)
+
+	// another seed chooses other proofs
+	differ := 0
+	for i := 0; i < 100; i++ {
+		key := drynxproof.ProofKey("query-sampling", "range", "dp", strconv.Itoa(i), "vn")
+		if drynxproof.Sampled(seed, key, 0.5) != drynxproof.Sampled([]byte("other"), key, 0.5) {
+			differ++
+		}
+	}
+	assert.NotZero(t, differ)
+}
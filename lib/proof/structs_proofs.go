@@ -16,7 +16,6 @@ import (
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
-	"math/rand"
 )
 
 const proofFalse = int64(0)
@@ -24,6 +23,7 @@ const proofFalse = int64(0)
 // ProofTrue is the constant used to indicate that a proof is true in the bitmap
 const ProofTrue = int64(1)
 const proofReceived = int64(2)
+const proofUndecided = int64(3)
 const proofFalseSign = int64(4)
 
 //----------------------------------------------------------------------------------------------------------------------
@@ -42,8 +42,10 @@ type ProofRequest struct {
 
 // ProofToStoreInDB is the proof format when stored in the DB
 type ProofToStoreInDB struct {
-	Data      []byte
-	Signature []byte
+	SenderID   string
+	DifferInfo string
+	Data       []byte
+	Signature  []byte
 }
 
 // RangeProofRequest is the structure sent by the client to the VNs to verify and store a range proof.
@@ -131,7 +133,8 @@ func NewRangeProofRequest(proof *libdrynxrange.RangeProofList, ID, senderID, dif
 	return rpr
 }
 
-// VerifyProof (RangeProofRequest) checks the correctness of the signature and verifies a list of range proofs
+// VerifyProof (RangeProofRequest) checks the correctness of the signature and verifies a list of range proofs, if they
+// are always checked (see VerifyStoredProof)
 func (rpr *RangeProofRequest) VerifyProof(source network.ServerIdentity, sq libdrynx.SurveyQuery) (int64, error) {
 	log.Lvl2("VN", source.String(), "handles range proof")
 	time := libunlynx.StartTimer(source.String() + "_VerifyRange")
@@ -145,7 +148,8 @@ func (rpr *RangeProofRequest) VerifyProof(source network.ServerIdentity, sq libd
 			verifSign = proofFalseSign
 		}
 	}()
-	verif := verifyRangeProofList(rpr.Data, sq.Threshold, sq.Query.Ranges, sq.Query.IVSigs.InputValidationSigs, sq.RosterServers.Aggregate, sq.RangeProofThreshold)
+	checked := sq.SamplingRates().Rate("range") >= 1
+	verif := verifyRangeProofList(rpr.Data, checked, sq.Query, sq.RosterServers.Aggregate, sq.RangeProofThreshold)
 	log.Lvl2("VN", source.String(), " verified range proof:", verif)
 	libunlynx.EndParallelize(wg)
 	libunlynx.EndTimer(time)
@@ -155,10 +159,9 @@ func (rpr *RangeProofRequest) VerifyProof(source network.ServerIdentity, sq libd
 	return verif, err
 }
 
-func verifyRangeProofList(data []byte, sample float64, ranges []*[]int64, psb []*[]libdrynx.PublishSignatureBytes, p kyber.Point, verifThresold float64) int64 {
+func verifyRangeProofList(data []byte, checked bool, q libdrynx.Query, p kyber.Point, verifThresold float64) int64 {
 	bmInt := proofReceived
-	rando := rand.Float64()
-	if rando <= sample {
+	if checked {
 		// we check the proof
 		_, proofs, err := network.Unmarshal(data, libunlynx.SuiTe)
 		if err != nil {
@@ -168,7 +171,7 @@ func verifyRangeProofList(data []byte, sample float64, ranges []*[]int64, psb []
 		toVerify := &libdrynxrange.RangeProofList{}
 		toVerify.FromBytes(*proofs.(*libdrynxrange.RangeProofListBytes))
 
-		result := libdrynxrange.RangeProofListVerification(*toVerify, ranges, psb, p, verifThresold)
+		result := verifyRangeBounds(*toVerify, q) && libdrynxrange.RangeProofListVerification(*toVerify, q.Ranges, q.IVSigs.InputValidationSigs, p, verifThresold)
 		if result {
 			bmInt = ProofTrue
 		} else {
@@ -181,6 +184,32 @@ func verifyRangeProofList(data []byte, sample float64, ranges []*[]int64, psb []
 	return bmInt
 }
 
+// verifyRangeBounds checks that the proof of the bound of each output (see libdrynx.NbrCountBoundRanges) is on the
+// output, and that the two range proofs of each bound of the query (see libdrynx.QueryRangeBounds) are on the same value
+func verifyRangeBounds(list libdrynxrange.RangeProofList, q libdrynx.Query) bool {
+	bounds := libdrynx.QueryRangeBounds(q)
+	nbrCountBounds := libdrynx.NbrCountBoundRanges(q)
+	if len(bounds) == 0 && nbrCountBounds == 0 {
+		return true
+	}
+	if len(list.Data) != libdrynx.NbrRanges(q) {
+		return false
+	}
+	for i := 0; i < nbrCountBounds; i++ {
+		high := libdrynx.NbrOutputRanges(q) + i
+		if !libdrynxrange.BoundedProofsLinked(list.Data[i], list.Data[high], 0, q.Operation.CountBound) {
+			return false
+		}
+	}
+	for i, b := range bounds {
+		low := libdrynx.NbrOutputRanges(q) + nbrCountBounds + 2*i
+		if !libdrynxrange.BoundedProofsLinked(list.Data[low], list.Data[low+1], b.Min, b.Max) {
+			return false
+		}
+	}
+	return true
+}
+
 // Aggregation Proof
 //______________________________________________________________________________________________________________________
 
@@ -225,7 +254,8 @@ func (apr *AggregationProofRequest) VerifyProof(source network.ServerIdentity, s
 		}
 	}()
 
-	verif := verifyAggregation(apr.Data, sq.AggregationProofThreshold, sq.Threshold)
+	checked := sq.SamplingRates().Rate("aggregation") >= 1
+	verif := verifyAggregation(apr.Data, sq.AggregationProofThreshold, checked)
 	log.Lvl2("VN", source.String(), "verified aggregation proof:", verif)
 	libunlynx.EndParallelize(wg)
 	libunlynx.EndTimer(time)
@@ -235,9 +265,9 @@ func (apr *AggregationProofRequest) VerifyProof(source network.ServerIdentity, s
 	return verif, err
 }
 
-func verifyAggregation(data []byte, insideProofThresold, sample float64) int64 {
+func verifyAggregation(data []byte, insideProofThresold float64, checked bool) int64 {
 	bmInt := proofReceived
-	if rand.Float64() <= sample {
+	if checked {
 		_, proofs, err := network.Unmarshal(data, libunlynx.SuiTe)
 		toVerify := &libunlynxaggr.PublishedAggregationListProof{}
 		toVerify.FromBytes(*proofs.(*libunlynxaggr.PublishedAggregationListProofBytes))
@@ -302,7 +332,8 @@ func (apr *ObfuscationProofRequest) VerifyProof(source network.ServerIdentity, s
 		}
 	}()
 
-	verif := verifyObfuscation(apr.Data, sq.ObfuscationProofThreshold, sq.Threshold)
+	checked := sq.SamplingRates().Rate("obfuscation") >= 1
+	verif := verifyObfuscation(apr.Data, sq.ObfuscationProofThreshold, checked)
 	log.Lvl2("VN", source.String(), "verified obfuscation proof:", verif)
 	libunlynx.EndParallelize(wg)
 	//libunlynx.EndTimer(time)
@@ -312,9 +343,9 @@ func (apr *ObfuscationProofRequest) VerifyProof(source network.ServerIdentity, s
 	return verif, err
 }
 
-func verifyObfuscation(data []byte, insideProofThresold, sample float64) int64 {
+func verifyObfuscation(data []byte, insideProofThresold float64, checked bool) int64 {
 	bmInt := proofReceived
-	if rand.Float64() <= sample {
+	if checked {
 		_, proof, err := network.Unmarshal(data, libunlynx.SuiTe)
 		toVerify := &libdrynxobfuscation.PublishedListObfuscationProof{}
 		toVerify.FromBytes(*proof.(*libdrynxobfuscation.PublishedListObfuscationProofBytes))
@@ -378,7 +409,8 @@ func (spr *ShuffleProofRequest) VerifyProof(source network.ServerIdentity, sq li
 		}
 	}()
 
-	verif := verifyShuffle(spr.Data, sq.Threshold, sq.RosterServers)
+	checked := sq.SamplingRates().Rate("shuffle") >= 1
+	verif := verifyShuffle(spr.Data, checked, sq.RosterServers)
 	log.Lvl2("VN", source.String(), "verified shuffle proof:", verif)
 	libunlynx.EndParallelize(wg)
 	//libunlynx.EndTimer(time)
@@ -388,10 +420,10 @@ func (spr *ShuffleProofRequest) VerifyProof(source network.ServerIdentity, sq li
 	return verif, err
 }
 
-// verifyShuffle verifies a shuffle proof with a given probability
-func verifyShuffle(data []byte, sample float64, roster onet.Roster) int64 {
+// verifyShuffle verifies a shuffle proof if it was sampled
+func verifyShuffle(data []byte, checked bool, roster onet.Roster) int64 {
 	bmInt := proofReceived
-	if rand.Float64() <= sample {
+	if checked {
 		_, proofs, err := network.Unmarshal(data, libunlynx.SuiTe)
 		if err != nil {
 			log.Fatal("Error unmarshalling PublishShufflingProofBytes message")
@@ -456,7 +488,8 @@ func (kpr *KeySwitchProofRequest) VerifyProof(source network.ServerIdentity, sq
 		}
 	}()
 
-	verif := verifyKeySwitch(kpr.Data, sq.KeySwitchingProofThreshold, sq.Threshold)
+	checked := sq.SamplingRates().Rate("keyswitch") >= 1
+	verif := verifyKeySwitch(kpr.Data, sq.KeySwitchingProofThreshold, checked)
 	log.Lvl2("VN", source.String(), "verified key switch proof:", verif)
 	libunlynx.EndParallelize(wg)
 	libunlynx.EndTimer(timeRange)
@@ -466,9 +499,9 @@ func (kpr *KeySwitchProofRequest) VerifyProof(source network.ServerIdentity, sq
 	return verif, err
 }
 
-func verifyKeySwitch(data []byte, insideProofThresold, sample float64) int64 {
+func verifyKeySwitch(data []byte, insideProofThresold float64, checked bool) int64 {
 	bmInt := proofReceived
-	if rand.Float64() <= sample {
+	if checked {
 		// we check the proof
 		_, proofs, err := network.Unmarshal(data, libunlynx.SuiTe)
 		if err != nil {
@@ -503,3 +536,22 @@ func VerifyProofSignature(pubKey kyber.Point, data []byte, signature []byte) err
 	}
 	return nil
 }
+
+// VerifyStoredProof verifies a proof stored by a VN, whatever the sampling rates of the query. The proofs that are not
+// always checked are only checked for their signature on arrival, and verified this way once they are drawn.
+func VerifyStoredProof(source network.ServerIdentity, sq libdrynx.SurveyQuery, typeProof string, p ProofToStoreInDB) (int64, error) {
+	sq.Sampling = &libdrynx.ProofsSampling{Range: 1, Shuffle: 1, Aggregation: 1, Obfuscation: 1, KeySwitch: 1}
+	switch typeProof {
+	case "range":
+		return (&RangeProofRequest{SurveyID: sq.SurveyID, SenderID: p.SenderID, DifferInfo: p.DifferInfo, Data: p.Data, Signature: p.Signature}).VerifyProof(source, sq)
+	case "aggregation":
+		return (&AggregationProofRequest{SurveyID: sq.SurveyID, SenderID: p.SenderID, DifferInfo: p.DifferInfo, Data: p.Data, Signature: p.Signature}).VerifyProof(source, sq)
+	case "obfuscation":
+		return (&ObfuscationProofRequest{SurveyID: sq.SurveyID, SenderID: p.SenderID, DifferInfo: p.DifferInfo, Data: p.Data, Signature: p.Signature}).VerifyProof(source, sq)
+	case "shuffle":
+		return (&ShuffleProofRequest{SurveyID: sq.SurveyID, SenderID: p.SenderID, DifferInfo: p.DifferInfo, Data: p.Data, Signature: p.Signature}).VerifyProof(source, sq)
+	case "keyswitch":
+		return (&KeySwitchProofRequest{SurveyID: sq.SurveyID, SenderID: p.SenderID, DifferInfo: p.DifferInfo, Data: p.Data, Signature: p.Signature}).VerifyProof(source, sq)
+	}
+	return 0, errors.New("unknown proof type " + typeProof)
+}
@@ -287,6 +287,20 @@ func InitRangeProofSignature(u int64) libdrynx.PublishSignatureBytes {
 	return libdrynx.PublishSignatureBytes{Signature: A, Public: kPub}
 }
 
+// InitRangeProofSignatures creates the signatures of each server for all the ranges, in the order of the query
+// (signatures[server][range])
+func InitRangeProofSignatures(nbrServers int, ranges []*[]int64) []*[]libdrynx.PublishSignatureBytes {
+	ps := make([]*[]libdrynx.PublishSignatureBytes, nbrServers)
+	for i := range ps {
+		temp := make([]libdrynx.PublishSignatureBytes, len(ranges))
+		for j := range ranges {
+			temp[j] = InitRangeProofSignature((*ranges[j])[0]) // u is the first elem
+		}
+		ps[i] = &temp
+	}
+	return ps
+}
+
 //PublishSignatureBytesToPublishSignatures creates servers' signatures directly in bytes
 func PublishSignatureBytesToPublishSignatures(sigsBytes libdrynx.PublishSignatureBytes) libdrynx.PublishSignature {
 	suitePair := bn256.NewSuite()
@@ -301,6 +315,32 @@ func PublishSignatureBytesToPublishSignatures(sigsBytes libdrynx.PublishSignatur
 	return libdrynx.PublishSignature{Signature: signatures, Public: sigsBytes.Public}
 }
 
+// BoundedCiphers gives, for a ciphertext of a value v, the ciphertexts of v-min and max-v. Range proofs on both show
+// that min <= v <= max, when their range is below the order of the group.
+func BoundedCiphers(ct libunlynx.CipherText, min, max int64) (libunlynx.CipherText, libunlynx.CipherText) {
+	low := libunlynx.CipherText{K: ct.K.Clone(), C: libunlynx.SuiTe.Point().Sub(ct.C, libunlynx.IntToPoint(min))}
+	high := libunlynx.CipherText{K: libunlynx.SuiTe.Point().Neg(ct.K), C: libunlynx.SuiTe.Point().Sub(libunlynx.IntToPoint(max), ct.C)}
+	return low, high
+}
+
+// BoundedCreateProofs gives the range proofs to create for a value v in [min, max], encrypted in ct with the randomness
+// r: v-min is proven with the signatures sigsLow and max-v with sigsHigh, both in [0, u^l) (see BoundedCiphers)
+func BoundedCreateProofs(sigsLow, sigsHigh []libdrynx.PublishSignature, u, l, v, min, max int64, r kyber.Scalar, caPub kyber.Point, ct libunlynx.CipherText) []CreateProof {
+	low, high := BoundedCiphers(ct, min, max)
+	return []CreateProof{
+		{Sigs: sigsLow, U: u, L: l, Secret: v - min, R: r, CaPub: caPub, Cipher: low},
+		{Sigs: sigsHigh, U: u, L: l, Secret: max - v, R: libunlynx.SuiTe.Scalar().Neg(r), CaPub: caPub, Cipher: high},
+	}
+}
+
+// BoundedProofsLinked checks that two range proofs are on the ciphertexts given by BoundedCiphers for the same
+// ciphertext: their sum is the encryption of max-min without randomness
+func BoundedProofsLinked(low, high RangeProof, min, max int64) bool {
+	k := libunlynx.SuiTe.Point().Add(low.Commit.K, high.Commit.K)
+	c := libunlynx.SuiTe.Point().Add(low.Commit.C, high.Commit.C)
+	return k.Equal(libunlynx.SuiTe.Point().Null()) && c.Equal(libunlynx.IntToPoint(max-min))
+}
+
 //CreatePredicateRangeProofListForAllServers creates range proofs for a list of servers and values
 func CreatePredicateRangeProofListForAllServers(cps []CreateProof) []RangeProof {
 	rps := make([]RangeProof, len(cps))
@@ -75,3 +75,50 @@ func TestOptimizedRangeProofVerification(t *testing.T) {
 
 	assert.True(t, libdrynxrange.RangeProofVerification(publishArgs, u, l, ys, P))
 }
+
+// TestBoundedRangeProofs tests that two linked range proofs bound a value on both sides
+func TestBoundedRangeProofs(t *testing.T) {
+	libunlynx.SuiTe = bn256.NewSuiteG1()
+	if !libdrynx.CurvePairingTest() {
+		t.Skip("no pairing")
+	}
+	u, l := int64(2), int64(3)
+	min, max := int64(1), int64(6)
+
+	keys := key.NewKeyPair(libunlynx.SuiTe)
+	P := keys.Public
+	sigs := make([][]libdrynx.PublishSignature, 2)
+	ys := make([][]kyber.Point, 2)
+	for i := range sigs {
+		for j := 0; j < 3; j++ {
+			sig := libdrynxrange.PublishSignatureBytesToPublishSignatures(libdrynxrange.InitRangeProofSignature(u))
+			sigs[i] = append(sigs[i], sig)
+			ys[i] = append(ys[i], sig.Public)
+		}
+	}
+
+	prove := func(v int64) (bool, bool) {
+		encryption, r := libunlynx.EncryptIntGetR(P, v)
+		rps := libdrynxrange.CreatePredicateRangeProofListForAllServers(libdrynxrange.BoundedCreateProofs(sigs[0], sigs[1], u, l, v, min, max, r, P, *encryption))
+		low, high := libdrynxrange.BoundedCiphers(*encryption, min, max)
+		assert.True(t, low.C.Equal(rps[0].Commit.C) && high.C.Equal(rps[1].Commit.C))
+		verified := libdrynxrange.RangeProofVerification(rps[0], u, l, ys[0], P) && libdrynxrange.RangeProofVerification(rps[1], u, l, ys[1], P)
+		return verified, libdrynxrange.BoundedProofsLinked(rps[0], rps[1], min, max)
+	}
+
+	for _, v := range []int64{min, 4, max} {
+		verified, linked := prove(v)
+		assert.True(t, verified)
+		assert.True(t, linked)
+	}
+	// a value above the bound cannot be proven below it
+	verified, _ := prove(max + 1)
+	assert.False(t, verified)
+
+	// proofs on two different ciphertexts are not linked
+	a, ra := libunlynx.EncryptIntGetR(P, 2)
+	b, rb := libunlynx.EncryptIntGetR(P, 2)
+	rpa := libdrynxrange.CreatePredicateRangeProofListForAllServers(libdrynxrange.BoundedCreateProofs(sigs[0], sigs[1], u, l, 2, min, max, ra, P, *a))
+	rpb := libdrynxrange.CreatePredicateRangeProofListForAllServers(libdrynxrange.BoundedCreateProofs(sigs[0], sigs[1], u, l, 2, min, max, rb, P, *b))
+	assert.False(t, libdrynxrange.BoundedProofsLinked(rpa[0], rpb[1], min, max))
+}
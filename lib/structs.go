@@ -1,7 +1,9 @@
 package libdrynx
 
 import (
+	"github.com/ldsec/drynx/lib/merkle"
 	"github.com/ldsec/unlynx/lib"
+	"github.com/ldsec/unlynx/lib/tools"
 	"github.com/ldsec/unlynx/protocols"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/kyber/v3"
@@ -9,6 +11,9 @@ import (
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
 	"go.etcd.io/bbolt"
+	"math"
+	"math/bits"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -37,14 +42,85 @@ type GetLatestBlock struct {
 	Sb     *skipchain.SkipBlock
 }
 
-// GetProofs is a request to get the proofs from a server, from query with SurveyID given as parameter
+// GetProofs is a request to get the proofs from a server, from query with SurveyID given as parameter. The proofs can
+// be filtered (all the proofs are returned if the filters are empty) and paginated.
 type GetProofs struct {
+	ID       string
+	Types    []string // types of proof, e.g. "range"
+	Sender   string   // ID of the node that sent the proofs
+	Verdicts []int64  // verdicts in the bitmap of the VN
+	Cursor   string   // key of the proof after which to start, from ProofsPage.Next
+	Limit    int64    // maximum number of proofs in the reply, no limit if 0
+}
+
+// ProofsPage is the reply to GetProofs
+type ProofsPage struct {
+	Proofs []StoredProof
+	Next   string // cursor to get the next page, empty if there are no more proofs
+}
+
+// StoredProof is a proof as stored by a VN, with its verdict in the bitmap of the VN (-1 if there is none yet).
+// Once the proof was pruned only the hash of its data is left.
+type StoredProof struct {
+	Key        string // SurveyID + type_of_proof + senderID + differInfo + serverID, as in the bitmap
+	Type       string
+	SenderID   string
+	DifferInfo string
+	Data       []byte
+	Signature  []byte
+	Verdict    int64
+	PrunedHash []byte
+}
+
+// GetProofInclusion is a request to get the path of a proof in the Merkle tree whose root is in the block of a survey
+type GetProofInclusion struct {
+	ID      string
+	ProofID string // SurveyID + type_of_proof + senderID + differInfo
+}
+
+// ProofInclusion shows that a proof is part of the Merkle tree whose root is in the block of its survey
+type ProofInclusion struct {
+	ProofID  string
+	DataHash []byte // sha256 of the data of the proof
+	Path     []libdrynxmerkle.Step
+}
+
+// PruneDB is the request to prune the proofs of the surveys older than MaxAge from the DB of a VN
+type PruneDB struct {
+	MaxAge  time.Duration
+	Compact bool // rewrite the DB file to give back the space freed by the pruning
+}
+
+// PruneDBReply reports what was pruned from the DB of a VN
+type PruneDBReply struct {
+	Surveys []string
+	Proofs  int64
+	Size    int64 // size of the DB file after the pruning
+}
+
+// GetQuery is a request to get the survey query stored by a VN, from query with SurveyID given as parameter
+type GetQuery struct {
 	ID string
 }
 
-// ProofsAsMap is the reply from the Service containing a map as protobuf expect a return struct
-type ProofsAsMap struct {
-	Proofs map[string][]byte
+// ProofsExport is a self-contained bundle of the proofs of a survey, to be re-verified offline
+type ProofsExport struct {
+	Query    SurveyQuery
+	Genesis  *skipchain.SkipBlock // its hash is the trusted anchor of the verification, its roster signs the blocks
+	Block    *skipchain.SkipBlock
+	Previous *skipchain.SkipBlock // holds the forward link signing Block
+	Proofs   []ExportedProof
+}
+
+// ExportedProof is a proof as stored by a VN, together with the verdict recorded in the block
+type ExportedProof struct {
+	Key        string // SurveyID + type_of_proof + senderID + differInfo + serverID, as in the bitmap
+	Type       string
+	SenderID   string
+	DifferInfo string
+	Data       []byte
+	Signature  []byte
+	Verdict    int64
 }
 
 // CloseDB is the struct to close a DB
@@ -66,10 +142,23 @@ type GetBlock struct {
 type DataBlock struct {
 	Roster       *onet.Roster
 	SurveyID     string
-	Sample       float64
+	Sampling     ProofsSampling // probabilities with which the VNs verified each type of proof
+	SamplingSeed []byte         // seed used to choose which proofs were verified, derived from the one of the query and ProofsRoot
+	ProofsRoot   []byte         // root of the Merkle tree over the proofs stored by the VNs
 	Time         time.Time
 	ServerNumber int64
 	Proofs       map[string]int64
+	//Agreement of the VNs on the verdicts of the bitmap
+	Agreed        map[string]int64 // verdict agreed on for each proof (by proof ID)
+	Disagreements []Disagreement
+	Deviations    map[string]int64 // number of verdicts of each VN opposite to the agreed one
+}
+
+// Disagreement lists the VNs that gave opposite verdicts on a proof
+type Disagreement struct {
+	ProofID  string
+	Valid    []string // addresses of the VNs that verified the proof
+	Rejected []string // addresses of the VNs that rejected the proof
 }
 
 //BitMap is used to send a structure containing a map in protobuf. You cannot send a map as protobuf
@@ -93,7 +182,8 @@ type ResponseDPOneGroupBytes struct {
 
 // ResponseAllDPs contain list of DPs answers.
 type ResponseAllDPs struct {
-	Data []ResponseDPOneGroup
+	Data       []ResponseDPOneGroup
+	Suppressed []string // groups withheld because they have less than Query.MinGroupSize records
 }
 
 // ResponseAllDPsBytes will contain the data to be sent to the server.
@@ -125,7 +215,8 @@ type ShufflingBytesMessage struct {
 
 // ResponseDP contains the data provider's response to be sent to the server.
 type ResponseDP struct {
-	Data map[string]libunlynx.CipherVector // group -> value(s)
+	Data       map[string]libunlynx.CipherVector // group -> value(s)
+	Suppressed []string
 }
 
 //PublishSignature contains points signed with a private key and the public key associated to verify the signatures.
@@ -181,6 +272,13 @@ type Query struct {
 	Proofs      int
 	Obfuscation bool
 	DiffP       QueryDiffP
+	// the groups are encrypted by the DPs and matched by deterministic tags, only the querier learns them. With proofs,
+	// the DPs prove that their encrypted groups are groups of the query (see QueryRangeBounds), the tagging and merging
+	// of the groups by the computing nodes is trusted.
+	EncryptedGroups bool
+	// the groups with less records are suppressed from the result, no suppression if 0. With proofs, the DPs prove the
+	// range of the number of records of each group, with the range that follows those of the operation.
+	MinGroupSize int64
 
 	// define how the DPs generate dummy data
 	DPDataGen QueryDPDataGen
@@ -204,6 +302,68 @@ type Operation struct {
 	QueryMin     int64
 	QueryMax     int64
 	LRParameters LogisticRegressionParameters
+	// options of lin_reg, see LinearRegressionOperation
+	LinRegParameters LinearRegressionParameters
+	// options of pca, see PCAOperation
+	PCAParameters PCAParameters
+
+	// one round of the bit-by-bit search of the min or max over a large domain, see MinMaxBitwise
+	MinMaxRound    bool
+	MinMaxBit      int              // bit of (value - QueryMin) searched in the round, from the least significant
+	MinMaxPrefixes map[string]int64 // bits of (value - QueryMin) above MinMaxBit found in the previous rounds, by group
+	MinMaxPrefix   int64            // prefix of the group being encoded by a DP
+
+	// number of hash functions of the Bloom filter of bloom_union and bloom_inter, whose size is NbrOutput
+	BloomHashes int
+	// log2 of the number of HyperLogLog registers of distinctCount
+	HLLPrecision int
+
+	// count-min sketch of topK, of SketchDepth rows of NbrOutput/SketchDepth cells, and the items ranked by the querier
+	SketchDepth int
+	TopK        int
+	Candidates  []string
+
+	// with proofs, each output is also proven to be at most CountBound if it is not 0, e.g. each cell of the sketch of
+	// topK to be at most the number of records of a DP (see CountRanges)
+	CountBound int64
+
+	// number of bins of the histograms of the scores of classifierEval, whose model is in LRParameters
+	ScoreBins int
+
+	// options of naiveBayes, whose records and classes are those of LRParameters, see NaiveBayesOperation
+	NBParameters NaiveBayesParameters
+
+	// options of kmeans, whose records are those of LRParameters, see KMeansOperation
+	KMParameters KMeansParameters
+}
+
+// BloomDefaultHashes is the number of hash functions of the Bloom filters set by ChooseOperation
+const BloomDefaultHashes = 4
+
+// SketchDefaultDepth is the number of rows of the count-min sketches set by ChooseOperation
+const SketchDefaultDepth = 4
+
+// HLLMaxRank is the largest value of a HyperLogLog register: each register is encoded as a max over [0, HLLMaxRank]
+const HLLMaxRank = 32
+
+// MinMaxBitwiseDomain is the size of the domain above which the min and max are searched bit by bit
+const MinMaxBitwiseDomain = 1024
+
+// MinMaxBitwise tells whether the min or max of an operation is searched bit by bit: one survey per bit of the domain
+// with a single OR-encoded bit per DP, instead of one bit per value of the domain. The bits found in a round are part of
+// the query of the next one: the CNs and DPs learn the min or max, and not only the querier.
+func MinMaxBitwise(operation Operation) bool {
+	return (operation.NameOp == "min" || operation.NameOp == "max") && operation.QueryMax-operation.QueryMin+1 > MinMaxBitwiseDomain
+}
+
+// MinMaxRounds gives the number of rounds of the bit-by-bit search of the min or max, i.e. the bits of the domain
+func MinMaxRounds(operation Operation) int {
+	return bits.Len64(uint64(operation.QueryMax - operation.QueryMin))
+}
+
+// MinMaxRoundID is the ID of the survey of a round of the bit-by-bit search of the min or max
+func MinMaxRoundID(surveyID string, bit int) string {
+	return surveyID + "-bit" + strconv.Itoa(bit)
 }
 
 // LogisticRegressionParameters are the parameters specific to logistic regression
@@ -216,6 +376,22 @@ type LogisticRegressionParameters struct {
 	Means              []float64
 	StandardDeviations []float64
 
+	// if Means or StandardDeviations are missing, they are computed by a survey before the training: the DPs send the
+	// sum, number and sum of squares of each feature, scaled to integers by PrecisionStandardisation. Otherwise, with
+	// LocalStandardisation, each DP standardises its records with its own means and standard deviations.
+	PrecisionStandardisation float64
+	StandardisationRound     bool // the survey of the means and standard deviations
+	LocalStandardisation     bool
+	// with proofs, the DPs prove in the survey of the means and standard deviations that their sum, number of records
+	// and sum of squares of each feature are at most those of NbrRecords records of value FeatureBound
+	FeatureBound float64
+
+	// k-fold cross-validation: with more than one fold, a DP shuffles its records with FoldSeed and splits them in
+	// Folds parts, of which it keeps Fold to evaluate the model (classifierEval) and the others to train it
+	Folds    int
+	Fold     int
+	FoldSeed int64
+
 	// parameters
 	Lambda         float64
 	Step           float64
@@ -225,6 +401,456 @@ type LogisticRegressionParameters struct {
 	// approximation
 	K                           int
 	PrecisionApproxCoefficients float64
+
+	// labels are in [0, NbrClasses), binary if it is 0 or 2. With more classes, there is a row of weights per class,
+	// trained one-vs-rest or, in the iterative mode only, jointly with a softmax if Multinomial.
+	NbrClasses  int64
+	Multinomial bool
+
+	// iterative mode: one survey per step of the gradient descent, in which the DPs send their gradient for the current
+	// weights, until the steps are smaller than Tolerance or after MaxIterations
+	Iterative          bool
+	Tolerance          float64
+	PrecisionGradients float64                  // scale of the gradients to integers, the sums of the DPs must stay decryptable
+	GradientRound      bool                     // one step of the iterative mode
+	RoundWeights       map[string]*GroupWeights // current weights by group
+	Weights            []float64                // current weights of the group being encoded by a DP
+}
+
+// StandardisationDefaultPrecision scales the features to integers in the survey of their means and standard deviations
+// if PrecisionStandardisation is not set. The sums of squares grow with its square and must stay decryptable.
+const StandardisationDefaultPrecision = 1e1
+
+// GroupWeights are the weights of the logistic regression of a group, or its centroids in a k-means clustering (a map
+// value cannot be a list in protobuf)
+type GroupWeights struct {
+	Weights []float64
+}
+
+// LogisticRegressionIterative tells whether the logistic regression of an operation is trained with a survey per step
+func LogisticRegressionIterative(operation Operation) bool {
+	return operation.NameOp == "logistic regression" && operation.LRParameters.Iterative
+}
+
+// LogisticRegressionStandardisation tells whether the means and standard deviations of the features of a logistic
+// regression are missing and have to be computed by a survey first
+func LogisticRegressionStandardisation(operation Operation) bool {
+	lrParameters := operation.LRParameters
+	return operation.NameOp == "logistic regression" && !lrParameters.StandardisationRound && !lrParameters.GradientRound &&
+		!lrParameters.LocalStandardisation && (len(lrParameters.Means) == 0 || len(lrParameters.StandardDeviations) == 0)
+}
+
+// StandardisationPrecision gives the scale of the features to integers in the survey of their means and standard
+// deviations
+func StandardisationPrecision(lrParameters LogisticRegressionParameters) float64 {
+	if lrParameters.PrecisionStandardisation == 0 {
+		return StandardisationDefaultPrecision
+	}
+	return lrParameters.PrecisionStandardisation
+}
+
+// StandardisationRanges gives the ranges of the outputs of the survey of the means and standard deviations, the sum,
+// number of records and sum of squares of each feature, bounded by those of NbrRecords records of value FeatureBound.
+// The range proofs only hold for non-negative features.
+func StandardisationRanges(lrParameters LogisticRegressionParameters) []*[]int64 {
+	feature := int64(math.Ceil(lrParameters.FeatureBound * StandardisationPrecision(lrParameters)))
+	bounds := []int64{lrParameters.NbrRecords * feature, lrParameters.NbrRecords, lrParameters.NbrRecords * feature * feature}
+	ranges := make([]*[]int64, 0, 3*lrParameters.NbrFeatures)
+	for j := int64(0); j < lrParameters.NbrFeatures; j++ {
+		for _, b := range bounds {
+			ranges = append(ranges, &[]int64{2, int64(bits.Len64(uint64(b)))})
+		}
+	}
+	return ranges
+}
+
+// StandardisationRoundID is the ID of the survey of the means and standard deviations of a logistic regression
+func StandardisationRoundID(surveyID string) string {
+	return surveyID + "-standardisation"
+}
+
+// LogisticRegressionClasses gives the number of rows of weights of a logistic regression: one if it is binary, one per
+// class otherwise
+func LogisticRegressionClasses(lrParameters LogisticRegressionParameters) int {
+	if lrParameters.NbrClasses <= 2 {
+		return 1
+	}
+	return int(lrParameters.NbrClasses)
+}
+
+// LogisticRegressionGradientOutputs gives the number of values sent by a DP in a step of the iterative logistic
+// regression, i.e. its NbrOutput: its number of records and the positive and negative parts of the gradient, for the
+// range proofs
+func LogisticRegressionGradientOutputs(lrParameters LogisticRegressionParameters) int {
+	return 1 + 2*LogisticRegressionClasses(lrParameters)*int(lrParameters.NbrFeatures+1)
+}
+
+// LogisticRegressionApproxCoefficients gives the number of approximation coefficients of a binary logistic regression
+// with d features and approximation degree k: (d+1)^j coefficients at each degree j from 1 to k
+func LogisticRegressionApproxCoefficients(d int64, k int) int {
+	count, level := 0, 1
+	for j := 0; j < k; j++ {
+		level *= int(d + 1)
+		count += level
+	}
+	return count
+}
+
+// LogisticRegressionOperation sets the parameters of the training of a logistic regression on the records of
+// lrParameters. The DPs send the approximation coefficients of each class, one after the other (a single block if it is
+// binary), or the outputs of a step if it is iterative.
+func LogisticRegressionOperation(lrParameters LogisticRegressionParameters) Operation {
+	nbrOutput := LogisticRegressionClasses(lrParameters) * LogisticRegressionApproxCoefficients(lrParameters.NbrFeatures, lrParameters.K)
+	if lrParameters.Iterative {
+		nbrOutput = LogisticRegressionGradientOutputs(lrParameters)
+	}
+	return Operation{NameOp: "logistic regression", LRParameters: lrParameters, NbrOutput: nbrOutput}
+}
+
+// LogisticRegressionWeightMatrix gives the rows of weights, one per class (a single one if binary), of the flattened
+// weights of a logistic regression
+func LogisticRegressionWeightMatrix(weights []float64, lrParameters LogisticRegressionParameters) [][]float64 {
+	classes := LogisticRegressionClasses(lrParameters)
+	size := len(weights) / classes
+	matrix := make([][]float64, classes)
+	for c := range matrix {
+		matrix[c] = weights[c*size : (c+1)*size]
+	}
+	return matrix
+}
+
+// GradientRoundID is the ID of the survey of a step of the iterative logistic regression
+func GradientRoundID(surveyID string, round int) string {
+	return surveyID + "-round" + strconv.Itoa(round)
+}
+
+// LinearRegressionParameters are the options of a linear regression
+type LinearRegressionParameters struct {
+	// ridge penalty of the coefficients, except the intercept
+	Lambda float64
+	// the DPs also send the sum of the squares of y, for the standard errors of the coefficients and the R²
+	Statistics bool
+}
+
+// FeatureOperation tells whether the DPs answer an operation from records of features and labels, as for the logistic
+// regression, instead of generated values
+func FeatureOperation(operation Operation) bool {
+	return operation.NameOp == "logistic regression" || operation.NameOp == "classifierEval" || operation.NameOp == "naiveBayes" ||
+		operation.NameOp == "kmeans"
+}
+
+// ClassifierClasses gives the number of classes of a classifier: 2 if it is binary
+func ClassifierClasses(lrParameters LogisticRegressionParameters) int {
+	if lrParameters.NbrClasses <= 2 {
+		return 2
+	}
+	return int(lrParameters.NbrClasses)
+}
+
+// ClassifierEvalOperation sets the parameters of the evaluation of a logistic regression model, given by its weights,
+// means and standard deviations in lrParameters, with histograms of the scores of the given number of bins
+func ClassifierEvalOperation(lrParameters LogisticRegressionParameters, bins int) Operation {
+	classes := ClassifierClasses(lrParameters)
+	return Operation{NameOp: "classifierEval", LRParameters: lrParameters, ScoreBins: bins,
+		NbrOutput: classes*classes + 2*classes*bins}
+}
+
+// ClassifierEvaluation is the evaluation of a classifier over the records of all the DPs. The metrics and the ROC curve
+// of each class are one-vs-rest.
+type ClassifierEvaluation struct {
+	// number of records of actual class i predicted as j at i*classes + j
+	Confusion []int64
+	Accuracy  float64
+	Precision []float64
+	Recall    []float64
+	F1        []float64
+	// points of the ROC curve of each class, from (1, 1) to (0, 0) as the threshold on the score increases
+	FalsePositiveRates [][]float64
+	TruePositiveRates  [][]float64
+	AUC                []float64
+}
+
+// ToFloats flattens a ClassifierEvaluation, as returned by the decoding of a query
+func (ce *ClassifierEvaluation) ToFloats() []float64 {
+	result := []float64{ce.Accuracy}
+	result = append(result, ce.Precision...)
+	result = append(result, ce.Recall...)
+	result = append(result, ce.F1...)
+	result = append(result, ce.AUC...)
+	for _, c := range ce.Confusion {
+		result = append(result, float64(c))
+	}
+	for c := range ce.AUC {
+		result = append(result, ce.FalsePositiveRates[c]...)
+		result = append(result, ce.TruePositiveRates[c]...)
+	}
+	return result
+}
+
+// FromFloats creates a ClassifierEvaluation back from the result of a query
+func (ce *ClassifierEvaluation) FromFloats(result []float64, classes int, bins int) {
+	next := func(n int) []float64 {
+		values := result[:n]
+		result = result[n:]
+		return values
+	}
+	ce.Accuracy = next(1)[0]
+	ce.Precision = next(classes)
+	ce.Recall = next(classes)
+	ce.F1 = next(classes)
+	ce.AUC = next(classes)
+	ce.Confusion = make([]int64, classes*classes)
+	for i, c := range next(classes * classes) {
+		ce.Confusion[i] = int64(c)
+	}
+	ce.FalsePositiveRates = make([][]float64, classes)
+	ce.TruePositiveRates = make([][]float64, classes)
+	for c := 0; c < classes; c++ {
+		ce.FalsePositiveRates[c] = next(bins + 1)
+		ce.TruePositiveRates[c] = next(bins + 1)
+	}
+}
+
+// CrossValidationParameters are the parameters of a k-fold cross-validation of a logistic regression, see
+// services.API.CrossValidate
+type CrossValidationParameters struct {
+	Folds     int
+	Seed      int64
+	ScoreBins int // of the histograms of the scores of the evaluations, see ClassifierEvalOperation
+	// grid of hyperparameters, every combination is cross-validated; the values of the query are used if empty
+	Lambdas []float64
+	Ks      []int
+}
+
+// CrossValidationResult is the evaluation of each fold of the cross-validation of a logistic regression with the given
+// hyperparameters, and the mean and variance of the metrics over the folds (without confusion matrix nor ROC curves)
+type CrossValidationResult struct {
+	Lambda   float64
+	K        int
+	Folds    []ClassifierEvaluation
+	Mean     ClassifierEvaluation
+	Variance ClassifierEvaluation
+}
+
+// CrossValidationRoundID is the ID of a survey of the cross-validation of a logistic regression: the training or the
+// evaluation of a fold, for a point of the grid of hyperparameters
+func CrossValidationRoundID(surveyID string, point int, fold int, stage string) string {
+	return surveyID + "-cv" + strconv.Itoa(point) + "-fold" + strconv.Itoa(fold) + "-" + stage
+}
+
+// CrossValidationStatistics gives the mean and the (sample) variance of the metrics of the folds
+func CrossValidationStatistics(folds []ClassifierEvaluation) (ClassifierEvaluation, ClassifierEvaluation) {
+	// accuracy, then the metrics of each class
+	metrics := func(ce ClassifierEvaluation) [][]float64 {
+		return [][]float64{{ce.Accuracy}, ce.Precision, ce.Recall, ce.F1, ce.AUC}
+	}
+
+	n := float64(len(folds))
+	means := metrics(folds[0])
+	variances := metrics(folds[0])
+	for m := range means {
+		means[m] = make([]float64, len(means[m]))
+		variances[m] = make([]float64, len(variances[m]))
+		for _, fold := range folds {
+			for i, v := range metrics(fold)[m] {
+				means[m][i] += v / n
+			}
+		}
+		if n < 2 {
+			continue
+		}
+		for _, fold := range folds {
+			for i, v := range metrics(fold)[m] {
+				variances[m][i] += (v - means[m][i]) * (v - means[m][i]) / (n - 1)
+			}
+		}
+	}
+	return ClassifierEvaluation{Accuracy: means[0][0], Precision: means[1], Recall: means[2], F1: means[3], AUC: means[4]},
+		ClassifierEvaluation{Accuracy: variances[0][0], Precision: variances[1], Recall: variances[2], F1: variances[3], AUC: variances[4]}
+}
+
+// NaiveBayesParameters are the options of a Naive Bayes classifier, Gaussian or, with Categories, categorical
+type NaiveBayesParameters struct {
+	// the features are categorical with values in [0, Categories), Gaussian if it is 0
+	Categories int64
+	// Gaussian: scale of the features to integers, NaiveBayesDefaultPrecision if 0. The sums of squares grow with its
+	// square and must stay decryptable.
+	Precision float64
+	// categorical: additive (Laplace) smoothing of the counts, 1 if 0
+	Smoothing float64
+}
+
+// NaiveBayesDefaultPrecision scales the features of a Gaussian Naive Bayes to integers if Precision is not set
+const NaiveBayesDefaultPrecision = 1e1
+
+// NaiveBayesGaussianStatistics is the number of statistics of a Gaussian feature of a Naive Bayes: the sums of its
+// positive and of its (absolute) negative values, the number of records and the sum of squares
+const NaiveBayesGaussianStatistics = 4
+
+// NaiveBayesOperation sets the parameters of the training of a Naive Bayes classifier on the records of lrParameters.
+// For each class and each feature, the DPs send the statistics of the feature if it is Gaussian (see
+// NaiveBayesGaussianStatistics), or the frequency count of its categories.
+func NaiveBayesOperation(lrParameters LogisticRegressionParameters, nbParameters NaiveBayesParameters) Operation {
+	perFeature := NaiveBayesGaussianStatistics
+	if nbParameters.Categories > 0 {
+		perFeature = int(nbParameters.Categories)
+	}
+	return Operation{NameOp: "naiveBayes", LRParameters: lrParameters, NBParameters: nbParameters,
+		NbrOutput: ClassifierClasses(lrParameters) * int(lrParameters.NbrFeatures) * perFeature}
+}
+
+// NaiveBayesModel is a Naive Bayes classifier: the prior of each class and, for each class and feature, the mean and
+// variance of the feature if it is Gaussian, or the probability of each of its categories
+type NaiveBayesModel struct {
+	Priors        []float64
+	Means         [][]float64
+	Variances     [][]float64
+	Probabilities [][][]float64
+}
+
+// ToFloats flattens a NaiveBayesModel, as returned by the decoding of a query
+func (nbm *NaiveBayesModel) ToFloats() []float64 {
+	result := append(make([]float64, 0), nbm.Priors...)
+	for c := range nbm.Means {
+		result = append(result, nbm.Means[c]...)
+		result = append(result, nbm.Variances[c]...)
+	}
+	for c := range nbm.Probabilities {
+		for _, probabilities := range nbm.Probabilities[c] {
+			result = append(result, probabilities...)
+		}
+	}
+	return result
+}
+
+// FromFloats creates a NaiveBayesModel back from the result of a query, Gaussian if categories is 0
+func (nbm *NaiveBayesModel) FromFloats(result []float64, classes int, features int, categories int) {
+	next := func(n int) []float64 {
+		values := result[:n]
+		result = result[n:]
+		return values
+	}
+	nbm.Priors = next(classes)
+	nbm.Means, nbm.Variances, nbm.Probabilities = nil, nil, nil
+	if categories == 0 {
+		nbm.Means = make([][]float64, classes)
+		nbm.Variances = make([][]float64, classes)
+		for c := 0; c < classes; c++ {
+			nbm.Means[c] = next(features)
+			nbm.Variances[c] = next(features)
+		}
+		return
+	}
+	nbm.Probabilities = make([][][]float64, classes)
+	for c := 0; c < classes; c++ {
+		nbm.Probabilities[c] = make([][]float64, features)
+		for j := range nbm.Probabilities[c] {
+			nbm.Probabilities[c][j] = next(categories)
+		}
+	}
+}
+
+// KMeansParameters are the options of a federated k-means clustering: one survey per round, in which the DPs assign
+// their records to the nearest centroid and send, for each cluster, its number of records and the sums of their
+// coordinates, scaled to integers by Precision. The querier updates the centroids until they move less than Tolerance
+// or after MaxIterations.
+type KMeansParameters struct {
+	K                int
+	InitialCentroids []float64 // K rows of NbrFeatures coordinates, one after the other
+	MaxIterations    int
+	Tolerance        float64
+	Precision        float64 // KMeansDefaultPrecision if 0, the sums of the DPs must stay decryptable
+
+	Round          bool                     // one round of the clustering
+	RoundCentroids map[string]*GroupWeights // current centroids by group, flattened
+	Centroids      []float64                // current centroids of the group being encoded by a DP
+}
+
+// KMeansDefaultPrecision scales the coordinates of the records to integers if Precision is not set
+const KMeansDefaultPrecision = 1e1
+
+// KMeansOperation sets the parameters of the clustering of the records of lrParameters in kmParameters.K clusters. For
+// each cluster, the DPs send the number of their records in it, the sums of their positive coordinates and the sums of
+// the absolute values of their negative ones.
+func KMeansOperation(lrParameters LogisticRegressionParameters, kmParameters KMeansParameters) Operation {
+	return Operation{NameOp: "kmeans", LRParameters: lrParameters, KMParameters: kmParameters,
+		NbrOutput: kmParameters.K * int(2*lrParameters.NbrFeatures+1)}
+}
+
+// KMeansIterative tells whether an operation is a k-means clustering, whose rounds are surveys sent by the querier
+func KMeansIterative(operation Operation) bool {
+	return operation.NameOp == "kmeans" && !operation.KMParameters.Round
+}
+
+// KMeansRoundID is the ID of the survey of a round of a k-means clustering
+func KMeansRoundID(surveyID string, round int) string {
+	return surveyID + "-kmeans" + strconv.Itoa(round)
+}
+
+// KMeansResult is the result of a k-means clustering of a group
+type KMeansResult struct {
+	Centroids [][]float64
+	// number of records of each cluster in the last round
+	Sizes      []float64
+	Iterations int
+}
+
+// ToFloats flattens a KMeansResult, as returned by a query
+func (kmr *KMeansResult) ToFloats() []float64 {
+	result := make([]float64, 0)
+	for _, centroid := range kmr.Centroids {
+		result = append(result, centroid...)
+	}
+	result = append(result, kmr.Sizes...)
+	return append(result, float64(kmr.Iterations))
+}
+
+// FromFloats creates a KMeansResult back from the result of a query
+func (kmr *KMeansResult) FromFloats(result []float64, k int, features int) {
+	kmr.Centroids = make([][]float64, k)
+	for c := range kmr.Centroids {
+		kmr.Centroids[c] = result[c*features : (c+1)*features]
+	}
+	kmr.Sizes = result[k*features : k*features+k]
+	kmr.Iterations = int(result[k*features+k])
+}
+
+// LinearRegressionResult is the result of a linear regression. Without statistics, only the coefficients are set.
+type LinearRegressionResult struct {
+	// intercept first
+	Coefficients   []float64
+	StandardErrors []float64
+	TStatistics    []float64
+	PValues        []float64
+	R2             float64
+	AdjustedR2     float64
+}
+
+// ToFloats flattens a LinearRegressionResult, as returned by the decoding of a query
+func (lrr *LinearRegressionResult) ToFloats() []float64 {
+	result := append(make([]float64, 0), lrr.Coefficients...)
+	if lrr.StandardErrors == nil {
+		return result
+	}
+	result = append(result, lrr.StandardErrors...)
+	result = append(result, lrr.TStatistics...)
+	result = append(result, lrr.PValues...)
+	return append(result, lrr.R2, lrr.AdjustedR2)
+}
+
+// FromFloats creates a LinearRegressionResult back from the result of a query
+func (lrr *LinearRegressionResult) FromFloats(result []float64, statistics bool) {
+	if !statistics {
+		lrr.Coefficients = result
+		return
+	}
+	d := (len(result) - 2) / 4
+	lrr.Coefficients = result[:d]
+	lrr.StandardErrors = result[d : 2*d]
+	lrr.TStatistics = result[2*d : 3*d]
+	lrr.PValues = result[3*d : 4*d]
+	lrr.R2 = result[4*d]
+	lrr.AdjustedR2 = result[4*d+1]
 }
 
 // SurveyQuery is the complete query
@@ -244,6 +870,44 @@ type SurveyQuery struct {
 	ObfuscationProofThreshold  float64
 	RangeProofThreshold        float64
 	KeySwitchingProofThreshold float64
+	//Probability for a VN to verify each type of proof (Threshold is used for all types if nil)
+	Sampling *ProofsSampling
+	//Seed of the query, e.g. the hash of the latest block, combined with the root of the proofs to choose the verified ones
+	SamplingSeed []byte
+}
+
+// ProofsSampling holds the probability with which the VNs verify each type of proof
+type ProofsSampling struct {
+	Range       float64
+	Shuffle     float64
+	Aggregation float64
+	Obfuscation float64
+	KeySwitch   float64
+}
+
+// Rate returns the sampling probability of a type of proof
+func (ps ProofsSampling) Rate(proofType string) float64 {
+	switch proofType {
+	case "range":
+		return ps.Range
+	case "shuffle":
+		return ps.Shuffle
+	case "aggregation":
+		return ps.Aggregation
+	case "obfuscation":
+		return ps.Obfuscation
+	case "keyswitch":
+		return ps.KeySwitch
+	}
+	return 0
+}
+
+// SamplingRates returns the sampling probabilities the VNs use for the query
+func (sq *SurveyQuery) SamplingRates() ProofsSampling {
+	if sq.Sampling != nil {
+		return *sq.Sampling
+	}
+	return ProofsSampling{Range: sq.Threshold, Shuffle: sq.Threshold, Aggregation: sq.Threshold, Obfuscation: sq.Threshold, KeySwitch: sq.Threshold}
 }
 
 // SurveyQueryToVN is the version of the query sent to the VNs
@@ -292,15 +956,16 @@ func (rdog *ResponseDPOneGroup) ToBytes() ResponseDPOneGroupBytes {
 	result.Groups = []byte(rdog.Group)
 	tmp, leng, _ := rdog.Data.ToBytes()
 	result.Data = tmp
-	result.CVLength = []byte{byte(leng)}
+	result.CVLength = libunlynxtools.UnsafeCastIntsToBytes([]int{leng})
 
 	return result
 }
 
 // FromBytes creates a ResponseDPOneGroup struct back from the bytes
 func (rdog *ResponseDPOneGroup) FromBytes(rdogb ResponseDPOneGroupBytes) {
-	tmp := libunlynx.NewCipherVector(int(rdogb.CVLength[0]))
-	tmp.FromBytes(rdogb.Data, int(rdogb.CVLength[0]))
+	length := libunlynxtools.UnsafeCastBytesToInts(rdogb.CVLength)[0]
+	tmp := libunlynx.NewCipherVector(length)
+	tmp.FromBytes(rdogb.Data, length)
 	rdog.Data = *tmp
 	rdog.Group = string(rdogb.Groups)
 }
@@ -356,7 +1021,7 @@ func ConvertFromAggregationStruct(cad protocolsunlynx.CothorityAggregatedData) *
 		response = append(response, ResponseDPOneGroup{Group: string(k), Data: v.AggregatingAttributes})
 	}
 
-	return &ResponseAllDPs{response}
+	return &ResponseAllDPs{Data: response}
 }
 
 // ToBytes converts a ShufflingMessage to a byte array
@@ -452,11 +1117,16 @@ func CheckParameters(sq SurveyQuery, diffP bool) bool {
 				result = false
 				message = message + "obfuscation threshold is 0 while obfuscation is true \n"
 			}
-			if sq.Query.Operation.NameOp != "bool_AND" && sq.Query.Operation.NameOp != "bool_OR" && sq.Query.Operation.NameOp != "min" && sq.Query.Operation.NameOp != "max" && sq.Query.Operation.NameOp != "union" && sq.Query.Operation.NameOp != "inter" {
+			if sq.Query.Operation.NameOp != "bool_AND" && sq.Query.Operation.NameOp != "bool_OR" && sq.Query.Operation.NameOp != "min" && sq.Query.Operation.NameOp != "max" && sq.Query.Operation.NameOp != "union" && sq.Query.Operation.NameOp != "inter" && sq.Query.Operation.NameOp != "bloom_union" && sq.Query.Operation.NameOp != "bloom_inter" && sq.Query.Operation.NameOp != "distinctCount" {
 				result = false
 				message = message + "obfuscation threshold for a non accepted operation \n"
 			}
-			if !checkRangesBits(sq.Query.Ranges) {
+			// the sizes of the groups and the bounds of QueryRangeBounds are not obfuscated
+			obfuscatedRanges := sq.Query.Ranges
+			if len(obfuscatedRanges) > sq.Query.Operation.NbrOutput {
+				obfuscatedRanges = obfuscatedRanges[:sq.Query.Operation.NbrOutput]
+			}
+			if !checkRangesBits(obfuscatedRanges) {
 				result = false
 				message = message + "obfuscation and proofs but ranges not for 0,1 \n"
 			}
@@ -481,8 +1151,18 @@ func CheckParameters(sq SurveyQuery, diffP bool) bool {
 			message = message + "ranges to 0 but signatures also set \n"
 		}
 
+		if sq.Sampling != nil {
+			for _, rate := range []float64{sq.Sampling.Range, sq.Sampling.Shuffle, sq.Sampling.Aggregation, sq.Sampling.Obfuscation, sq.Sampling.KeySwitch} {
+				if rate < 0 || rate > 1 {
+					result = false
+					message = message + "sampling rate not between 0 and 1 \n"
+					break
+				}
+			}
+		}
+
 		if sq.Query.IVSigs.InputValidationSigs != nil && sq.Query.Ranges != nil {
-			if sq.Query.Operation.NbrOutput != len(*sq.Query.IVSigs.InputValidationSigs[0]) || sq.Query.Operation.NbrOutput != len(sq.Query.Ranges) {
+			if NbrRanges(sq.Query) != len(*sq.Query.IVSigs.InputValidationSigs[0]) || NbrRanges(sq.Query) != len(sq.Query.Ranges) {
 				result = false
 				message = message + "ranges or signatures length do not match with nbr output \n"
 			}
@@ -494,6 +1174,11 @@ func CheckParameters(sq SurveyQuery, diffP bool) bool {
 			message = message + "no proofs and one of the threshold not 0 \n"
 		}
 
+		if sq.Sampling != nil {
+			result = false
+			message = message + "no proofs and sampling rates set \n"
+		}
+
 		if sq.Query.Ranges != nil || sq.Query.IVSigs.InputValidationSigs != nil {
 			result = false
 			message = message + "no proofs and some ranges or signatures \n"
@@ -532,6 +1217,63 @@ func CheckParameters(sq SurveyQuery, diffP bool) bool {
 	return result
 }
 
+// RangeBound is a value that the DPs prove to be between Min and Max, with two range proofs (see
+// libdrynxrange.BoundedCiphers)
+type RangeBound struct {
+	Min, Max int64
+}
+
+// QueryRangeBounds lists the values that the DPs prove to be bounded in each group, in addition to the values that they
+// send (see NbrOutputRanges), if the query has ranges: the encrypted group is between 1 and the number of groups
+func QueryRangeBounds(q Query) []RangeBound {
+	bounds := make([]RangeBound, 0)
+	if q.Ranges == nil {
+		return bounds
+	}
+	if q.EncryptedGroups {
+		bounds = append(bounds, RangeBound{Min: 1, Max: int64(len(GroupLabels(q.DPDataGen.GroupByValues)))})
+	}
+	return bounds
+}
+
+// BoundRanges gives the ranges of the two range proofs of each bound, that follow the ranges of the operation in the
+// query
+func BoundRanges(bounds []RangeBound) []*[]int64 {
+	ranges := make([]*[]int64, 0, 2*len(bounds))
+	for _, b := range bounds {
+		l := int64(bits.Len64(uint64(b.Max - b.Min)))
+		if l == 0 {
+			l = 1
+		}
+		ranges = append(ranges, &[]int64{2, l}, &[]int64{2, l})
+	}
+	return ranges
+}
+
+// NbrOutputRanges is the number of ranges of the values that the DPs send in each group: the outputs of the operation,
+// followed by the size of the group if the small groups are suppressed
+func NbrOutputRanges(q Query) int {
+	if q.MinGroupSize > 0 {
+		return q.Operation.NbrOutput + 1
+	}
+	return q.Operation.NbrOutput
+}
+
+// NbrCountBoundRanges is the number of ranges of the proofs that the outputs of the operation are at most
+// Operation.CountBound, that follow those of NbrOutputRanges
+func NbrCountBoundRanges(q Query) int {
+	if q.Operation.CountBound > 0 {
+		return q.Operation.NbrOutput
+	}
+	return 0
+}
+
+// NbrRanges is the number of ranges and signatures of a query with proofs, for the values that the DPs send
+// (NbrOutputRanges), the bound of the outputs (NbrCountBoundRanges) and the bounds of QueryRangeBounds
+func NbrRanges(q Query) int {
+	return NbrOutputRanges(q) + NbrCountBoundRanges(q) + 2*len(QueryRangeBounds(q))
+}
+
 // QueryToProofsNbrs creates the number of required proofs from the query parameters
 func QueryToProofsNbrs(q SurveyQuery) []int {
 	nbrDPs := 0
@@ -543,8 +1285,8 @@ func QueryToProofsNbrs(q SurveyQuery) []int {
 	}
 	nbrServers := len(q.RosterServers.List)
 
-	// range proofs
-	prfRange := nbrDPs
+	// range proofs, one list per group
+	prfRange := nbrDPs * len(GroupLabels(q.Query.DPDataGen.GroupByValues))
 	// aggregation
 	if q.Query.Proofs == 0 {
 		nbrServers = 0
@@ -587,6 +1329,96 @@ func UpdateDB(db *bbolt.DB, bucketName string, key string, value []byte) {
 	}
 }
 
+// LinearRegressionOperation sets the parameters of a linear regression over d dimensions with options
+func LinearRegressionOperation(d int, parameters LinearRegressionParameters) Operation {
+	operation := ChooseOperation("lin_reg", 0, 0, d, 0)
+	operation.LinRegParameters = parameters
+	// the sum of the squares of y
+	if parameters.Statistics {
+		operation.NbrOutput++
+	}
+	return operation
+}
+
+// PCAParameters are the options of a principal component analysis
+type PCAParameters struct {
+	// number of components kept, all if 0
+	Components int
+}
+
+// PCAOperation sets the parameters of a principal component analysis over d dimensions: the DPs send the number of
+// records, the sums of each dimension and the sums of the products of every pair of dimensions, as the first values of
+// lin_reg. With noise (see QueryDiffP), the covariance matrix and thus the analysis are differentially private.
+func PCAOperation(d int, parameters PCAParameters) Operation {
+	operation := ChooseOperation("pca", 0, 0, d, 0)
+	operation.PCAParameters = parameters
+	return operation
+}
+
+// PCAComponents gives the number of components kept by a principal component analysis over d dimensions
+func PCAComponents(parameters PCAParameters, d int) int {
+	if parameters.Components <= 0 || parameters.Components > d {
+		return d
+	}
+	return parameters.Components
+}
+
+// PCAResult is the result of a principal component analysis, by decreasing explained variance
+type PCAResult struct {
+	Means []float64
+	// variance along each component, and its share of the total variance
+	ExplainedVariance      []float64
+	ExplainedVarianceRatio []float64
+	// unit vectors of the components, and their loadings: the vectors scaled by the standard deviation along them
+	Components [][]float64
+	Loadings   [][]float64
+}
+
+// ToFloats flattens a PCAResult, as returned by the decoding of a query
+func (pcar *PCAResult) ToFloats() []float64 {
+	result := append(make([]float64, 0), pcar.Means...)
+	result = append(result, pcar.ExplainedVariance...)
+	result = append(result, pcar.ExplainedVarianceRatio...)
+	for _, component := range pcar.Components {
+		result = append(result, component...)
+	}
+	for _, loading := range pcar.Loadings {
+		result = append(result, loading...)
+	}
+	return result
+}
+
+// FromFloats creates a PCAResult back from the result of a query over d dimensions with the given number of components
+func (pcar *PCAResult) FromFloats(result []float64, d int, components int) {
+	next := func(n int) []float64 {
+		values := result[:n]
+		result = result[n:]
+		return values
+	}
+	pcar.Means = next(d)
+	pcar.ExplainedVariance = next(components)
+	pcar.ExplainedVarianceRatio = next(components)
+	pcar.Components = make([][]float64, components)
+	for c := range pcar.Components {
+		pcar.Components[c] = next(d)
+	}
+	pcar.Loadings = make([][]float64, components)
+	for c := range pcar.Loadings {
+		pcar.Loadings[c] = next(d)
+	}
+}
+
+// CountRanges gives the ranges of n counts of at most rows each: the proofs show that the counts are in [0, 2^l) with
+// 2^l the smallest power of two above rows. With Operation.CountBound set to rows, the same ranges follow for the
+// proofs that rows minus each count is also in [0, 2^l), i.e. that the counts are at most rows (see NbrRanges).
+func CountRanges(rows int64, n int) []*[]int64 {
+	ranges := make([]*[]int64, n)
+	for i := range ranges {
+		ranges[i] = &[]int64{2, int64(bits.Len64(uint64(rows)))}
+	}
+	return ranges
+}
+
 // ChooseOperation sets the parameters according to the operation
 func ChooseOperation(operationName string, queryMin, queryMax, d int, cuttingFactor int) Operation {
 	operation := Operation{}
@@ -618,16 +1450,57 @@ func ChooseOperation(operationName string, queryMin, queryMax, d int, cuttingFac
 		//NbrOutput should be equal to (QueryMax - QueryMin + 1)
 		operation.NbrInput = 1
 		operation.NbrOutput = queryMax - queryMin + 1
+		// a single bit in each round
+		if MinMaxBitwise(operation) {
+			operation.NbrOutput = 1
+		}
 		break
 	case "bool_OR", "bool_AND":
 		operation.NbrInput = 1
 		operation.NbrOutput = 1
 		break
+	case "bloom_union", "bloom_inter":
+		//NbrOutput is the size of the Bloom filter, given by d
+		operation.NbrInput = 1
+		operation.NbrOutput = d
+		operation.BloomHashes = BloomDefaultHashes
+		break
+	case "distinctCount":
+		//there are 2^d HyperLogLog registers
+		operation.NbrInput = 1
+		operation.NbrOutput = (1 << uint(d)) * (HLLMaxRank + 1)
+		operation.HLLPrecision = d
+		break
+	case "classifierEval":
+		//a binary classifier, with histograms of d bins (see ClassifierEvalOperation)
+		operation.NbrOutput = ClassifierEvalOperation(LogisticRegressionParameters{}, d).NbrOutput
+		operation.ScoreBins = d
+		break
+	case "naiveBayes":
+		//a binary Gaussian Naive Bayes over d features (see NaiveBayesOperation)
+		operation.NbrOutput = NaiveBayesOperation(LogisticRegressionParameters{NbrFeatures: int64(d)}, NaiveBayesParameters{}).NbrOutput
+		break
+	case "kmeans":
+		//d clusters of a single feature (see KMeansOperation)
+		operation.NbrOutput = KMeansOperation(LogisticRegressionParameters{NbrFeatures: 1}, KMeansParameters{K: d}).NbrOutput
+		operation.KMParameters.K = d
+		break
+	case "topK":
+		//the count-min sketch has SketchDefaultDepth rows of d cells
+		operation.NbrInput = 1
+		operation.NbrOutput = SketchDefaultDepth * d
+		operation.SketchDepth = SketchDefaultDepth
+		break
 	case "lin_reg":
 		//NbrInput should be equal to d + 1, in the case of linear regression
 		operation.NbrInput = d + 1
 		operation.NbrOutput = (d*d + 5*d + 4) / 2
 		break
+	case "pca":
+		//the number of records, the sums of the d dimensions and of the products of every pair of them
+		operation.NbrInput = d
+		operation.NbrOutput = 1 + d + d*(d+1)/2
+		break
 	case "logistic regression":
 		break
 	default:
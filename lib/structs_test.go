@@ -0,0 +1,74 @@
+package libdrynx_test
+
+import (
+	"testing"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/unlynx/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestResponseDPOneGroupBytes tests the conversion to and from bytes of the answer of a DP for a group, with more
+// ciphertexts than a single byte can count
+func TestResponseDPOneGroupBytes(t *testing.T) {
+	_, pubKey := libunlynx.GenKey()
+
+	values := make([]int64, 300)
+	for i := range values {
+		values[i] = int64(i)
+	}
+	response := libdrynx.ResponseDPOneGroup{Group: "[0 1]", Data: *libunlynx.EncryptIntVector(pubKey, values)}
+
+	result := libdrynx.ResponseDPOneGroup{}
+	result.FromBytes(response.ToBytes())
+	assert.Equal(t, response.Group, result.Group)
+	assert.Equal(t, len(response.Data), len(result.Data))
+	for i := range response.Data {
+		assert.True(t, response.Data[i].K.Equal(result.Data[i].K))
+		assert.True(t, response.Data[i].C.Equal(result.Data[i].C))
+	}
+}
+
+// TestNbrRanges tests the number of ranges of a query whose outputs are bounded, with encrypted groups
+func TestNbrRanges(t *testing.T) {
+	operation := libdrynx.ChooseOperation("topK", 0, 0, 16, 0)
+	ranges := libdrynx.CountRanges(10, operation.NbrOutput)
+	q := libdrynx.Query{Operation: operation, Ranges: ranges, EncryptedGroups: true, DPDataGen: libdrynx.QueryDPDataGen{GroupByValues: []int64{2}}}
+	assert.Equal(t, 0, libdrynx.NbrCountBoundRanges(q))
+	n := operation.NbrOutput
+	assert.Equal(t, n+2, libdrynx.NbrRanges(q))
+
+	q.Operation.CountBound = 10
+	q.MinGroupSize = 2
+	assert.Equal(t, n, libdrynx.NbrCountBoundRanges(q))
+	assert.Equal(t, n+1+n+2, libdrynx.NbrRanges(q))
+	assert.Equal(t, []int64{2, 4}, *ranges[0])
+}
+
+// TestLogisticRegressionOperation tests the number of outputs of a one-vs-rest and of an iterative logistic regression
+func TestLogisticRegressionOperation(t *testing.T) {
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrFeatures: 2, K: 2, NbrClasses: 3}
+	operation := libdrynx.LogisticRegressionOperation(lrParameters)
+	assert.Equal(t, "logistic regression", operation.NameOp)
+	// 3 + 9 coefficients per class
+	assert.Equal(t, 36, operation.NbrOutput)
+
+	lrParameters.NbrClasses = 2
+	assert.Equal(t, 12, libdrynx.LogisticRegressionOperation(lrParameters).NbrOutput)
+
+	lrParameters.Iterative = true
+	assert.Equal(t, libdrynx.LogisticRegressionGradientOutputs(lrParameters), libdrynx.LogisticRegressionOperation(lrParameters).NbrOutput)
+}
+
+// TestStandardisationRanges tests the ranges of the sum, number of records and sum of squares of each feature
+func TestStandardisationRanges(t *testing.T) {
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrRecords: 20, NbrFeatures: 2, FeatureBound: 3}
+	ranges := libdrynx.StandardisationRanges(lrParameters)
+	assert.Len(t, ranges, 6)
+	// 20 records of 30 once scaled
+	for j := 0; j < 2; j++ {
+		assert.Equal(t, []int64{2, 10}, *ranges[3*j])
+		assert.Equal(t, []int64{2, 5}, *ranges[3*j+1])
+		assert.Equal(t, []int64{2, 15}, *ranges[3*j+2])
+	}
+}
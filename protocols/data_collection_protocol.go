@@ -15,14 +15,13 @@ import (
 	"go.dedis.ch/onet/v3/log"
 	"go.dedis.ch/onet/v3/network"
 	"math/rand"
+	"strconv"
 	"sync"
 )
 
 // DataCollectionProtocolName is the registered name for the data provider protocol.
 const DataCollectionProtocolName = "DataCollection"
 
-var mutexGroups sync.Mutex
-
 func init() {
 	network.RegisterMessage(AnnouncementDCMessage{})
 	network.RegisterMessage(DataCollectionMessage{})
@@ -174,25 +173,17 @@ func (p *DataCollectionProtocol) Dispatch() error {
 // Support Functions
 //______________________________________________________________________________________________________________________
 
+// RangePIKey is the key of the proof collection protocol instance with which a DP sends the range proofs of a group
+func RangePIKey(dpID string, group int) string {
+	return "range/" + dpID + "/" + strconv.Itoa(group)
+}
+
 // GenerateData is used to generate data at DPs, this is more for simulation's purposes
 func (p *DataCollectionProtocol) GenerateData() (libdrynx.ResponseDPBytes, error) {
 
 	// Prepare the generation of all possible groups with the query information.
-	numType := make([]int64, len(p.Survey.Query.DPDataGen.GroupByValues))
-	for i, v := range p.Survey.Query.DPDataGen.GroupByValues {
-		numType[i] = v
-	}
-	mutexGroups.Lock()
-
-	groups := make([][]int64, 0)
-	group := make([]int64, 0)
-	dataunlynx.AllPossibleGroups(numType[:], group, 0, &groups)
-	groupsString := make([]string, len(groups))
+	groupsString := libdrynx.GroupLabels(p.Survey.Query.DPDataGen.GroupByValues)
 
-	for i, v := range groups {
-		groupsString[i] = fmt.Sprint(v)
-	}
-	mutexGroups.Unlock()
 	// read the signatures needed to compute the range proofs
 	signatures := make([][]libdrynx.PublishSignature, p.Survey.Query.IVSigs.InputValidationSize1)
 	for i := 0; i < p.Survey.Query.IVSigs.InputValidationSize1; i++ {
@@ -205,11 +196,11 @@ func (p *DataCollectionProtocol) GenerateData() (libdrynx.ResponseDPBytes, error
 	// generate fake random data depending on the operation
 	fakeData := createFakeDataForOperation(p.Survey.Query.Operation, p.Survey.Query.DPDataGen.GenerateRows, p.Survey.Query.DPDataGen.GenerateDataMin, p.Survey.Query.DPDataGen.GenerateDataMax)
 
-	// logistic regression specific
+	// logistic regression and classifier evaluation specific
 	var xFloat [][]float64
 	var yInt []int64
 	lrParameters := p.Survey.Query.Operation.LRParameters
-	if p.Survey.Query.Operation.NameOp == "logistic regression" {
+	if libdrynx.FeatureOperation(p.Survey.Query.Operation) {
 		if lrParameters.FilePath != "" {
 			// note: GetDataForDataProvider(...) business only for testing purpose
 			dataProviderID := p.TreeNode().ServerIdentity
@@ -233,12 +224,26 @@ func (p *DataCollectionProtocol) GenerateData() (libdrynx.ResponseDPBytes, error
 			for i := 0; i < int(lrParameters.NbrRecords); i++ {
 				xFloat[i] = make([]float64, m)
 				yInt[i] = int64(rand.Intn(2)) // sample 0 or 1 randomly for the label
+				if lrParameters.NbrClasses > 2 {
+					yInt[i] = int64(rand.Intn(int(lrParameters.NbrClasses)))
+				}
 				for j := 1; j < m; j++ {
 					r := rand.Intn(limit)
 					xFloat[i][j] = float64(r)
 				}
 			}
 		}
+
+		// cross-validation: the records of the fold evaluate the model, the others train it
+		if lrParameters.Folds > 1 {
+			xTrain, yTrain, xTest, yTest := libdrynxencoding.PartitionFold(xFloat, yInt, lrParameters.Folds, lrParameters.Fold, lrParameters.FoldSeed)
+			if p.Survey.Query.Operation.NameOp == "classifierEval" {
+				xFloat, yInt = xTest, yTest
+			} else {
+				xFloat, yInt = xTrain, yTrain
+			}
+			lrParameters.NbrRecords = int64(len(xFloat))
+		}
 	}
 
 	// ------- START: ENCODING & ENCRYPTION -------
@@ -250,33 +255,107 @@ func (p *DataCollectionProtocol) GenerateData() (libdrynx.ResponseDPBytes, error
 	clearResponse := make([]int64, 0)
 	encryptedResponse := make([]libunlynx.CipherText, 0)
 
+	// the ranges and signatures that follow those of the operation are for the size of the groups, the bound of the
+	// outputs and the bounds of the query
+	bounds := libdrynx.QueryRangeBounds(p.Survey.Query)
+	nbrOutput := p.Survey.Query.Operation.NbrOutput
+	nbrOutputRanges := libdrynx.NbrOutputRanges(p.Survey.Query)
+	boundsRanges := nbrOutputRanges + libdrynx.NbrCountBoundRanges(p.Survey.Query)
+	ranges, opSignatures := p.Survey.Query.Ranges, signatures
+	if len(ranges) > nbrOutput {
+		ranges = ranges[:nbrOutput]
+		opSignatures = make([][]libdrynx.PublishSignature, len(signatures))
+		for i := range signatures {
+			opSignatures[i] = signatures[i][:nbrOutput]
+		}
+	}
+	encryptedGroups := make(map[string]string, len(groupsString))
+
+	// the number of records of the DP in each group is sent with its response for the small groups to be suppressed
+	nbrRecords := p.Survey.Query.DPDataGen.GenerateRows
+	if libdrynx.FeatureOperation(p.Survey.Query.Operation) {
+		nbrRecords = int64(len(xFloat))
+	} else if len(fakeData) > 0 {
+		nbrRecords = int64(len(fakeData[0]))
+	}
+
 	// for all different groups
-	for _, v := range groupsString {
+	for groupIndex, v := range groupsString {
 		if p.Survey.Query.CuttingFactor != 0 {
 			p.Survey.Query.Operation.NbrOutput = int(p.Survey.Query.Operation.NbrOutput / p.Survey.Query.CuttingFactor)
 		}
-		if p.Survey.Query.Operation.NameOp == "logistic regression" {
+		if libdrynx.FeatureOperation(p.Survey.Query.Operation) {
 			//p.Survey.Query.Ranges = nil
+			groupParameters := lrParameters
+			if weights, ok := groupParameters.RoundWeights[v]; ok && groupParameters.GradientRound {
+				groupParameters.Weights = weights.Weights
+			} else if groupParameters.GradientRound {
+				groupParameters.Weights = groupParameters.InitialWeights
+			}
+			operation := p.Survey.Query.Operation
+			if centroids, ok := operation.KMParameters.RoundCentroids[v]; ok {
+				operation.KMParameters.Centroids = centroids.Weights
+			} else {
+				operation.KMParameters.Centroids = operation.KMParameters.InitialCentroids
+			}
 			var err error
-			encryptedResponse, clearResponse, cprf, err = libdrynxencoding.EncodeForFloat(xFloat, yInt, lrParameters, p.Survey.Aggregate, signatures, p.Survey.Query.Ranges, p.Survey.Query.Operation.NameOp)
+			encryptedResponse, clearResponse, cprf, err = libdrynxencoding.EncodeForFloat(xFloat, yInt, groupParameters, p.Survey.Aggregate, opSignatures, ranges, operation)
 			if err != nil {
 				return libdrynx.ResponseDPBytes{}, fmt.Errorf("when getting data for provider: %w", err)
 			}
 		} else {
-			encryptedResponse, clearResponse, cprf = libdrynxencoding.Encode(fakeData, p.Survey.Aggregate, signatures, p.Survey.Query.Ranges, p.Survey.Query.Operation)
+			operation := p.Survey.Query.Operation
+			if operation.MinMaxRound {
+				operation.MinMaxPrefix = operation.MinMaxPrefixes[v]
+			}
+			encryptedResponse, clearResponse, cprf = libdrynxencoding.Encode(fakeData, p.Survey.Aggregate, opSignatures, ranges, operation)
 		}
 
 		log.Lvl2("Data Provider", p.Name(), "computes the query response", clearResponse, "for groups:", groupsString, "with operation:", p.Survey.Query.Operation)
 
 		queryResponse[v] = libunlynx.CipherVector(encryptedResponse)
 
+		if p.Survey.Query.MinGroupSize > 0 {
+			ct, r := libunlynx.EncryptIntGetR(p.Survey.Aggregate, nbrRecords)
+			queryResponse[v] = append(queryResponse[v], *ct)
+			if len(ranges) > 0 && len(signatures) > 0 {
+				rg := *p.Survey.Query.Ranges[nbrOutput]
+				cprf = append(cprf, libdrynxrange.CreateProof{Sigs: libdrynxrange.ReadColumn(signatures, nbrOutput), U: rg[0], L: rg[1], Secret: nbrRecords, R: r, CaPub: p.Survey.Aggregate, Cipher: *ct})
+			}
+		}
+
+		// each output is proven to be at most the bound, with the proof of the output that it is not negative
+		if countBound := p.Survey.Query.Operation.CountBound; countBound > 0 && len(ranges) > 0 && len(signatures) > 0 {
+			for i, output := range cprf[:nbrOutput] {
+				rg := *p.Survey.Query.Ranges[nbrOutputRanges+i]
+				_, high := libdrynxrange.BoundedCiphers(output.Cipher, 0, countBound)
+				cprf = append(cprf, libdrynxrange.CreateProof{Sigs: libdrynxrange.ReadColumn(signatures, nbrOutputRanges+i), U: rg[0], L: rg[1], Secret: countBound - output.Secret, R: libunlynx.SuiTe.Scalar().Neg(output.R), CaPub: p.Survey.Aggregate, Cipher: high})
+			}
+		}
+
+		// the encrypted group is proven to be one of the groups of the query
+		if p.Survey.Query.EncryptedGroups {
+			index, err := libdrynx.GroupIndex(p.Survey.Query.DPDataGen.GroupByValues, v)
+			if err != nil {
+				return libdrynx.ResponseDPBytes{}, fmt.Errorf("when encrypting group: %w", err)
+			}
+			ct, r := libunlynx.EncryptIntGetR(p.Survey.Aggregate, index)
+			if encryptedGroups[v], err = ct.Serialize(); err != nil {
+				return libdrynx.ResponseDPBytes{}, fmt.Errorf("when encrypting group: %w", err)
+			}
+			if len(bounds) > 0 && len(signatures) > 0 {
+				rg := *p.Survey.Query.Ranges[boundsRanges]
+				cprf = append(cprf, libdrynxrange.BoundedCreateProofs(libdrynxrange.ReadColumn(signatures, boundsRanges), libdrynxrange.ReadColumn(signatures, boundsRanges+1), rg[0], rg[1], index, bounds[0].Min, bounds[0].Max, r, p.Survey.Aggregate, *ct)...)
+			}
+		}
+
 		// scaling for simulation purposes
 		qr := queryResponse[v]
 		for i := 0; i < p.Survey.Query.CuttingFactor-1; i++ {
 			queryResponse[v] = append(queryResponse[v], qr...)
 		}
 		if p.Survey.Query.Proofs != 0 {
-			go func() {
+			go func(groupIndex int, cprf []libdrynxrange.CreateProof, cv libunlynx.CipherVector) {
 				startAllProofs := libunlynx.StartTimer(p.Name() + "_AllProofs")
 				rpl := libdrynxrange.RangeProofList{}
 
@@ -284,7 +363,7 @@ func (p *DataCollectionProtocol) GenerateData() (libdrynx.ResponseDPBytes, error
 				// no range proofs (send only the ciphertexts)
 				if len(cprf) == 0 {
 					tmp := make([]libdrynxrange.RangeProof, 0)
-					for _, ct := range queryResponse[v] {
+					for _, ct := range cv {
 						tmp = append(tmp, libdrynxrange.RangeProof{Commit: ct, RP: nil})
 					}
 					rpl = libdrynxrange.RangeProofList{Data: tmp}
@@ -326,8 +405,13 @@ func (p *DataCollectionProtocol) GenerateData() (libdrynx.ResponseDPBytes, error
 					rpl.Data = rplNew.Data
 				}
 
-				pi := p.MapPIs["range/"+p.ServerIdentity().String()]
-				pi.(*ProofCollectionProtocol).Proof = drynxproof.ProofRequest{RangeProof: drynxproof.NewRangeProofRequest(&rpl, p.Survey.SurveyID, p.ServerIdentity().String(), "", p.Survey.Query.RosterVNs, p.Private(), nil)}
+				// the range proofs of each group are told apart when there are several groups
+				differInfo := ""
+				if len(groupsString) > 1 {
+					differInfo = strconv.Itoa(groupIndex)
+				}
+				pi := p.MapPIs[RangePIKey(p.ServerIdentity().String(), groupIndex)]
+				pi.(*ProofCollectionProtocol).Proof = drynxproof.ProofRequest{RangeProof: drynxproof.NewRangeProofRequest(&rpl, p.Survey.SurveyID, p.ServerIdentity().String(), differInfo, p.Survey.Query.RosterVNs, p.Private(), nil)}
 				//libunlynx.EndTimer(rangeProofCreation)
 
 				go func() {
@@ -344,19 +428,29 @@ func (p *DataCollectionProtocol) GenerateData() (libdrynx.ResponseDPBytes, error
 
 				libunlynx.EndTimer(startAllProofs)
 
-			}()
+			}(groupIndex, cprf, queryResponse[v])
 		}
 	}
 	libunlynx.EndTimer(encodeTime)
 	// ------- END -------
 
+	// hide the groups from the computing nodes
+	responseToSend := queryResponse
+	if p.Survey.Query.EncryptedGroups {
+		clearGroups := responseToSend
+		responseToSend = make(map[string]libunlynx.CipherVector, len(clearGroups))
+		for group, cv := range clearGroups {
+			responseToSend[encryptedGroups[group]] = cv
+		}
+	}
+
 	//convert the response to bytes
-	length := len(queryResponse)
+	length := len(responseToSend)
 	queryResponseBytes := make(map[string][]byte, length)
 	lenQueryResponse := 0
 	wg := libunlynx.StartParallelize(length)
 	mutex := sync.Mutex{}
-	for i, v := range queryResponse {
+	for i, v := range responseToSend {
 		go func(group string, cv libunlynx.CipherVector) {
 			defer wg.Done()
 			cvBytes, lenQ, _ := cv.ToBytes()
@@ -7,6 +7,7 @@ import (
 	"github.com/btcsuite/goleveldb/leveldb/errors"
 	"github.com/fanliao/go-concurrentMap"
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/unlynx/lib"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
@@ -22,6 +23,7 @@ func init() {
 	network.RegisterMessage(ProofCollectionMessage{})
 	network.RegisterMessage(BitmapCollectionMessage{})
 	network.RegisterMessage(libdrynx.BitMap{})
+	network.RegisterMessage(drynxproof.ProofToStoreInDB{})
 	if _, err := onet.GlobalProtocolRegister(ProofCollectionProtocolName, NewProofCollectionProtocol); err != nil {
 		log.Fatal("Error registering <ProofCollectionProtocol>:", err)
 	}
@@ -93,6 +95,8 @@ type ProofCollectionProtocol struct {
 	DB     *bbolt.DB
 	//To make everything thread safe (database access and updating parameters)
 	Mutex *sync.Mutex
+	//Root of the Merkle tree over the proofs of a survey stored in DB, from which the sampling seed is derived
+	ProofsRoot func(surveyID string) ([]byte, error)
 	// -------------------
 
 	// Protocol feedback channel
@@ -315,20 +319,21 @@ func (p *ProofCollectionProtocol) storeProof(index int, typeProof, surveyID, sen
 
 		//Put in the bitmap the value of the verification
 		//Key is SurveyID + type_of_proof + senderID + addiInfo + serverID
-		nameOfProof := surveyID + "/" + typeProof + "/" + senderID + "/" + potentialDeterministicInfo + "/" + p.ServerIdentity().Address.String()
+		nameOfProof := drynxproof.ProofKey(surveyID, typeProof, senderID, potentialDeterministicInfo, p.ServerIdentity().Address.String())
 		qi := CastToQueryInfo(p.Request.Get(string(surveyID)))
 		qi.Bitmap[nameOfProof] = verificationResult
 		if _, err := p.Request.Replace(surveyID, qi); err != nil {
 			return nil, err
 		}
 
-		//Put in the DB the proof received. Bucket is queryID + type
+		//Put in the DB the proof received (with its signature). Bucket is queryID + type
 		//Key is SurveyID + type_of_proof + senderID + addiInfo + serverID
-		//TODO: append signature to data
-
-		if typeProof != "shuffle" {
-			libdrynx.UpdateDB(p.DB, surveyID+"/"+typeProof, nameOfProof, data)
+		proofBytes, err := network.Marshal(&drynxproof.ProofToStoreInDB{SenderID: senderID, DifferInfo: potentialDeterministicInfo, Data: data, Signature: signature})
+		if err != nil {
+			p.Mutex.Unlock()
+			return nil, err
 		}
+		libdrynx.UpdateDB(p.DB, surveyID+"/"+typeProof, nameOfProof, proofBytes)
 
 		//Decrease size of proof expected for this type by 1
 		qi.TotalNbrProofs[index]--
@@ -351,6 +356,10 @@ func (p *ProofCollectionProtocol) storeProof(index int, typeProof, surveyID, sen
 		if proofsRemaining == 0 {
 			log.Lvl2("VN", p.ServerIdentity().String(), "received all expected proofs.")
 
+			if err := p.verifySampledProofs(surveyID); err != nil {
+				log.Fatal("Error when verifying the sampled proofs:", err)
+			}
+
 			mapByte, err := network.Marshal(&libdrynx.BitMap{BitMap: CastToQueryInfo(p.Request.Get(string(surveyID))).Bitmap})
 			if err != nil {
 				log.Fatal("Cannot marshalize map", err)
@@ -394,7 +403,8 @@ func (p *ProofCollectionProtocol) storeProof(index int, typeProof, surveyID, sen
 					return nil, nil // terminate
 				}
 
-				p.SharedBMChannel <- bitmap.Bitmap
+				// a VN can only report its own verdicts
+				p.SharedBMChannel <- drynxproof.OwnVerdicts(bitmap.Bitmap, bitmap.ServerIdentity.Address.String())
 			}
 		}
 
@@ -404,3 +414,65 @@ func (p *ProofCollectionProtocol) storeProof(index int, typeProof, surveyID, sen
 	}
 	return nil, nil
 }
+
+// verifySampledProofs verifies the stored proofs drawn by the sampling, once all of them are stored. The sampling seed
+// depends on the root of the stored proofs so that it is only revealed after they are committed.
+func (p *ProofCollectionProtocol) verifySampledProofs(surveyID string) error {
+	// the proofs always checked were verified on arrival
+	rates := p.SQ.SamplingRates()
+	sampled := make([]string, 0)
+	for _, typeProof := range drynxproof.ProofTypes {
+		if rates.Rate(typeProof) > 0 && rates.Rate(typeProof) < 1 {
+			sampled = append(sampled, typeProof)
+		}
+	}
+	if len(sampled) == 0 {
+		return nil
+	}
+
+	root, err := p.ProofsRoot(surveyID)
+	if err != nil {
+		return err
+	}
+	seed := drynxproof.SamplingSeed(p.SQ.SamplingSeed, root)
+
+	p.Mutex.Lock()
+	defer p.Mutex.Unlock()
+
+	qi := CastToQueryInfo(p.Request.Get(surveyID))
+	for _, typeProof := range sampled {
+
+		stored := make(map[string]*drynxproof.ProofToStoreInDB)
+		err := p.DB.View(func(tx *bbolt.Tx) error {
+			b := tx.Bucket([]byte(surveyID + "/" + typeProof))
+			if b == nil {
+				return nil
+			}
+			return b.ForEach(func(k, v []byte) error {
+				_, msg, err := network.Unmarshal(v, libunlynx.SuiTe)
+				if err != nil {
+					return err
+				}
+				stored[string(k)] = msg.(*drynxproof.ProofToStoreInDB)
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		for key, proof := range stored {
+			// a proof with a bad signature stays rejected
+			if drynxproof.VerdictName(qi.Bitmap[key]) != "unchecked" || !drynxproof.Sampled(seed, key, rates.Rate(typeProof)) {
+				continue
+			}
+			verdict, err := drynxproof.VerifyStoredProof(*p.ServerIdentity(), p.SQ, typeProof, *proof)
+			if err != nil {
+				return err
+			}
+			qi.Bitmap[key] = verdict
+		}
+	}
+	_, err = p.Request.Replace(surveyID, qi)
+	return err
+}
@@ -1,6 +1,8 @@
 package services
 
 import (
+	"errors"
+
 	"github.com/ldsec/drynx/lib"
 	"github.com/ldsec/drynx/lib/encoding"
 	"github.com/ldsec/drynx/lib/obfuscation"
@@ -101,8 +103,27 @@ func (c *API) GenerateSurveyQuery(rosterServers, rosterVNs *onet.Roster, dpToSer
 	return sq
 }
 
-// SendSurveyQuery creates a survey based on a set of entities (servers) and a survey description.
+// SendSurveyQuery creates a survey based on a set of entities (servers) and a survey description. The groups suppressed
+// because they have too few records are listed after the others, with a nil result. A min or max searched bit by bit
+// (see libdrynx.MinMaxBitwise) is not private to the querier: the bits found in each round are sent to every CN and DP
+// of the query for the next one, so that they learn the result.
 func (c *API) SendSurveyQuery(sq libdrynx.SurveyQuery) (*[]string, *[][]float64, error) {
+	if libdrynx.LogisticRegressionStandardisation(sq.Query.Operation) {
+		var err error
+		if sq, err = c.standardise(sq, false); err != nil {
+			return nil, nil, err
+		}
+	}
+	if libdrynx.MinMaxBitwise(sq.Query.Operation) && !sq.Query.Operation.MinMaxRound {
+		return c.sendSurveyQueryBitwise(sq)
+	}
+	if libdrynx.LogisticRegressionIterative(sq.Query.Operation) && !sq.Query.Operation.LRParameters.GradientRound {
+		return c.sendSurveyQueryIterative(sq, false)
+	}
+	if libdrynx.KMeansIterative(sq.Query.Operation) {
+		return c.sendSurveyQueryKMeans(sq, false)
+	}
+
 	log.Lvl2("[API] <Drynx> Client", c.clientID, "is creating a query with SurveyID: ", sq.SurveyID)
 
 	//send the query and get the answer
@@ -118,16 +139,259 @@ func (c *API) SendSurveyQuery(sq libdrynx.SurveyQuery) (*[]string, *[][]float64,
 	clientDecode := libunlynx.StartTimer("Decode")
 	log.Lvl2("[API] <Drynx> Client", c.clientID, "is decrypting the results")
 
-	grp := make([]string, len(sr.Data))
-	aggr := make([][]float64, len(sr.Data))
+	grp := make([]string, len(sr.Data)+len(sr.Suppressed))
+	aggr := make([][]float64, len(sr.Data)+len(sr.Suppressed))
 	count := 0
 	for i, res := range sr.Data {
 		grp[count] = i
+		if sq.Query.EncryptedGroups {
+			grp[count], err = libdrynx.DecryptGroup(c.private, sq.Query.DPDataGen.GroupByValues, i)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
 		aggr[count] = libdrynxencoding.Decode(res, c.private, sq.Query.Operation)
 		count++
 	}
+	for _, group := range sr.Suppressed {
+		grp[count] = group
+		if sq.Query.EncryptedGroups {
+			grp[count], err = libdrynx.DecryptGroup(c.private, sq.Query.DPDataGen.GroupByValues, group)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		count++
+	}
 	libunlynx.EndTimer(clientDecode)
 
 	log.Lvl2("[API] <Drynx> Client", c.clientID, "finished decrypting the results")
 	return &grp, &aggr, nil
 }
+
+// sendSurveyQueryBitwise searches the min or max bit by bit, from the most significant: each round is a survey in which
+// the DPs tell (under OR) if their min or max has the bits found so far followed by a 0 or a 1 respectively. The bits
+// found are part of the query of the next round, the nodes thus learn the result.
+func (c *API) sendSurveyQueryBitwise(sq libdrynx.SurveyQuery) (*[]string, *[][]float64, error) {
+	operation := sq.Query.Operation
+	prefixes := make(map[string]int64)
+	grp := &[]string{}
+	for bit := libdrynx.MinMaxRounds(operation) - 1; bit >= 0; bit-- {
+		round := minMaxRound(sq, bit, prefixes)
+
+		var found *[][]float64
+		var err error
+		grp, found, err = c.SendSurveyQuery(round)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		next := make(map[string]int64, len(*grp))
+		for i, group := range *grp {
+			// the group is suppressed
+			if (*found)[i] == nil {
+				continue
+			}
+			next[group] = libdrynxencoding.NextMinMaxPrefix(operation, prefixes[group], (*found)[i][0] == 1)
+		}
+		prefixes = next
+	}
+
+	aggr := make([][]float64, len(*grp))
+	for i, group := range *grp {
+		if prefix, ok := prefixes[group]; ok {
+			aggr[i] = []float64{float64(operation.QueryMin + prefix)}
+		}
+	}
+	return grp, &aggr, nil
+}
+
+// minMaxRound gives the survey of the round of the bit-by-bit search of the min or max for a bit. The rounds are
+// obfuscated, for the querier to only learn whether a DP has the bits searched and not how many of them have. The
+// prefixes found so far are part of the query, which every CN and DP receives. With proofs, all the obfuscation proofs
+// are verified unless the query sets their threshold.
+func minMaxRound(sq libdrynx.SurveyQuery, bit int, prefixes map[string]int64) libdrynx.SurveyQuery {
+	round := sq
+	round.SurveyID = libdrynx.MinMaxRoundID(sq.SurveyID, bit)
+	round.Query.Operation.MinMaxRound = true
+	round.Query.Operation.MinMaxBit = bit
+	round.Query.Operation.MinMaxPrefixes = prefixes
+	round.Query.Obfuscation = true
+	if sq.Query.Proofs == 1 && round.ObfuscationProofThreshold == 0 {
+		round.ObfuscationProofThreshold = 1.0
+	}
+	return round
+}
+
+// standardise runs the survey of the means and standard deviations of the features of a logistic regression and gives
+// the logistic regression survey with their global values, over all the groups. With proofs, the DPs prove the sums of
+// their features against StandardisationRanges, with new signatures, followed by the ranges of the query for the size and
+// the bounds of the groups. The sums are not obfuscated, which would change them. The survey is registered with the VNs
+// as it starts and verified if asked, and so is the logistic regression with the means and standard deviations found
+// unless its steps are registered one by one.
+func (c *API) standardise(sq libdrynx.SurveyQuery, verified bool) (libdrynx.SurveyQuery, error) {
+	roundSQ := sq
+	roundSQ.SurveyID = libdrynx.StandardisationRoundID(sq.SurveyID)
+	roundSQ.Query.Obfuscation = false
+	roundSQ.ObfuscationProofThreshold = 0
+	roundSQ.Query.Operation.NbrOutput = 3 * int(sq.Query.Operation.LRParameters.NbrFeatures)
+	roundSQ.Query.Operation.LRParameters.StandardisationRound = true
+	roundSQ.Query.Operation.LRParameters.Iterative = false
+
+	proofs := sq.Query.Proofs != 0 && sq.Query.RosterVNs != nil
+	if sq.Query.Ranges != nil {
+		if sq.Query.Operation.LRParameters.FeatureBound <= 0 {
+			return sq, errors.New("the features need a bound to prove their means and standard deviations")
+		}
+		if err := setOperationRanges(sq, &roundSQ, libdrynx.StandardisationRanges(sq.Query.Operation.LRParameters)); err != nil {
+			return sq, err
+		}
+	}
+
+	if proofs {
+		if err := c.SendSurveyQueryToVNs(sq.Query.RosterVNs, &roundSQ); err != nil {
+			return sq, err
+		}
+	}
+	_, sums, err := c.SendSurveyQuery(roundSQ)
+	if err != nil {
+		return sq, err
+	}
+	if verified {
+		if _, err := c.VerifySurvey(roundSQ); err != nil {
+			return sq, err
+		}
+	}
+	groups := make([][]float64, 0, len(*sums))
+	for _, s := range *sums {
+		// the group is suppressed
+		if s != nil {
+			groups = append(groups, s)
+		}
+	}
+	if len(groups) == 0 {
+		return sq, errors.New("no records to standardise the features with")
+	}
+
+	sq.Query.Operation.LRParameters.Means, sq.Query.Operation.LRParameters.StandardDeviations = libdrynxencoding.StandardisationFromSums(groups...)
+	log.Lvl2("[API] <Drynx> Client", c.clientID, "standardises the features with means", sq.Query.Operation.LRParameters.Means,
+		"and standard deviations", sq.Query.Operation.LRParameters.StandardDeviations)
+
+	if proofs && !libdrynx.LogisticRegressionIterative(sq.Query.Operation) {
+		if err := c.SendSurveyQueryToVNs(sq.Query.RosterVNs, &sq); err != nil {
+			return sq, err
+		}
+	}
+	return sq, nil
+}
+
+// setOperationRanges gives a survey run for sq with another operation the ranges of the outputs of this operation, with
+// new signatures, followed by the ranges of sq for the size and the bounds of the groups. Its outputs have no bound.
+func setOperationRanges(sq libdrynx.SurveyQuery, round *libdrynx.SurveyQuery, ranges []*[]int64) error {
+	q := sq.Query
+	if len(q.Ranges) != libdrynx.NbrRanges(q) {
+		return errors.New("the ranges of the query do not match its outputs")
+	}
+	ranges = append(ranges, q.Ranges[q.Operation.NbrOutput:libdrynx.NbrOutputRanges(q)]...)
+	ranges = append(ranges, q.Ranges[libdrynx.NbrOutputRanges(q)+libdrynx.NbrCountBoundRanges(q):]...)
+	ps := libdrynxrange.InitRangeProofSignatures(len(sq.RosterServers.List), ranges)
+
+	round.Query.Operation.CountBound = 0
+	round.Query.Ranges = ranges
+	round.Query.IVSigs = libdrynx.QueryIVSigs{InputValidationSigs: ps, InputValidationSize1: len(ps), InputValidationSize2: len(ranges)}
+	return nil
+}
+
+// sendSurveyQueryRounds runs a survey per round of an iterative query until the states of all the groups move less than
+// the tolerance, or for maxIterations rounds. round gives the survey of a round from the states of the previous one, and
+// step the next state of a group and how much it moved from the group's result, starting from initial. The surveys are
+// registered with the VNs as they start if there are proofs, and verified if asked. It gives the groups, their states
+// and the number of rounds.
+func (c *API) sendSurveyQueryRounds(sq libdrynx.SurveyQuery, verified bool, maxIterations int, tolerance float64, initial []float64,
+	round func(round int, states map[string]*libdrynx.GroupWeights) libdrynx.SurveyQuery,
+	step func(group string, state []float64, result []float64) ([]float64, float64)) (*[]string, map[string]*libdrynx.GroupWeights, int, error) {
+	states := make(map[string]*libdrynx.GroupWeights)
+	grp := &[]string{}
+	iterations := 0
+	for r := 0; r < maxIterations; r++ {
+		roundSQ := round(r, states)
+
+		if sq.Query.Proofs != 0 && sq.Query.RosterVNs != nil {
+			if err := c.SendSurveyQueryToVNs(sq.Query.RosterVNs, &roundSQ); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+
+		var results *[][]float64
+		var err error
+		grp, results, err = c.SendSurveyQuery(roundSQ)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		if verified {
+			if _, err := c.VerifySurvey(roundSQ); err != nil {
+				return nil, nil, 0, err
+			}
+		}
+		iterations++
+
+		next := make(map[string]*libdrynx.GroupWeights, len(*grp))
+		converged := true
+		for i, group := range *grp {
+			// the group is suppressed
+			if (*results)[i] == nil {
+				continue
+			}
+			current := initial
+			if s, ok := states[group]; ok {
+				current = s.Weights
+			}
+			updated, size := step(group, current, (*results)[i])
+			next[group] = &libdrynx.GroupWeights{Weights: updated}
+			if size >= tolerance {
+				converged = false
+			}
+		}
+		states = next
+		log.Lvl2("[API] <Drynx> Client", c.clientID, "finished round", r, "of survey", sq.SurveyID)
+		if converged {
+			break
+		}
+	}
+	return grp, states, iterations, nil
+}
+
+// sendSurveyQueryIterative trains a logistic regression with a survey per step of the gradient descent: the DPs send
+// their gradient for the weights of the previous step, which are part of the query, and the querier updates them until
+// the steps are smaller than the tolerance. The result of each group is its weights.
+func (c *API) sendSurveyQueryIterative(sq libdrynx.SurveyQuery, verified bool) (*[]string, *[][]float64, error) {
+	parameters := sq.Query.Operation.LRParameters
+	initialWeights := parameters.InitialWeights
+	if size := libdrynx.LogisticRegressionClasses(parameters) * int(parameters.NbrFeatures+1); len(initialWeights) != size {
+		initialWeights = make([]float64, size)
+	}
+
+	round := func(round int, weights map[string]*libdrynx.GroupWeights) libdrynx.SurveyQuery {
+		roundSQ := sq
+		roundSQ.SurveyID = libdrynx.GradientRoundID(sq.SurveyID, round)
+		roundSQ.Query.Operation.NbrOutput = libdrynx.LogisticRegressionGradientOutputs(parameters)
+		roundSQ.Query.Operation.LRParameters.GradientRound = true
+		roundSQ.Query.Operation.LRParameters.RoundWeights = weights
+		return roundSQ
+	}
+	step := func(group string, weights []float64, gradient []float64) ([]float64, float64) {
+		return libdrynxencoding.LogisticRegressionStep(weights, gradient, parameters)
+	}
+	grp, weights, _, err := c.sendSurveyQueryRounds(sq, verified, parameters.MaxIterations, parameters.Tolerance, initialWeights, round, step)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aggr := make([][]float64, len(*grp))
+	for i, group := range *grp {
+		if w, ok := weights[group]; ok {
+			aggr[i] = w.Weights
+		}
+	}
+	return grp, &aggr, nil
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/ldsec/drynx/lib"
+	"go.dedis.ch/onet/v3/log"
+)
+
+// Cross-validation
+//______________________________________________________________________________________________________________________
+
+// CrossValidate evaluates the logistic regression of a survey query by k-fold cross-validation, for every combination
+// of the grid of hyperparameters. For each fold, a survey trains the model on the records of the other folds, with the
+// means and standard deviations of these records if the query has none, and a classifierEval survey evaluates it on
+// the records of the fold. The DPs split their records with the same seed. With proofs, every survey is registered with
+// the VNs; the evaluation proves its counts with CountRanges of the query's NbrRecords, which bounds those of a DP.
+// The query has a single group, or a single one that is not suppressed.
+func (c *API) CrossValidate(sq libdrynx.SurveyQuery, parameters libdrynx.CrossValidationParameters) ([]libdrynx.CrossValidationResult, error) {
+	return c.crossValidate(sq, parameters, false)
+}
+
+// CrossValidateVerified cross-validates the logistic regression of a survey query (as CrossValidate) and only returns
+// the results once the VNs have verified the proofs of all its surveys
+func (c *API) CrossValidateVerified(sq libdrynx.SurveyQuery, parameters libdrynx.CrossValidationParameters) ([]libdrynx.CrossValidationResult, error) {
+	if sq.Query.RosterVNs == nil {
+		return nil, errors.New("no verifying nodes to check the proofs")
+	}
+	return c.crossValidate(sq, parameters, true)
+}
+
+func (c *API) crossValidate(sq libdrynx.SurveyQuery, parameters libdrynx.CrossValidationParameters, verified bool) ([]libdrynx.CrossValidationResult, error) {
+	if sq.Query.Operation.NameOp != "logistic regression" {
+		return nil, errors.New("only a logistic regression can be cross-validated")
+	}
+	if parameters.Folds < 2 {
+		return nil, errors.New("a cross-validation needs at least 2 folds")
+	}
+	if parameters.ScoreBins < 1 {
+		return nil, errors.New("the ROC curves need at least one bin")
+	}
+
+	lambdas := parameters.Lambdas
+	if len(lambdas) == 0 {
+		lambdas = []float64{sq.Query.Operation.LRParameters.Lambda}
+	}
+	ks := parameters.Ks
+	if len(ks) == 0 {
+		ks = []int{sq.Query.Operation.LRParameters.K}
+	}
+
+	results := make([]libdrynx.CrossValidationResult, 0, len(lambdas)*len(ks))
+	for _, lambda := range lambdas {
+		for _, k := range ks {
+			result := libdrynx.CrossValidationResult{Lambda: lambda, K: k, Folds: make([]libdrynx.ClassifierEvaluation, parameters.Folds)}
+			for fold := 0; fold < parameters.Folds; fold++ {
+				evaluation, err := c.crossValidateFold(sq, parameters, len(results), fold, lambda, k, verified)
+				if err != nil {
+					return nil, err
+				}
+				result.Folds[fold] = evaluation
+			}
+			result.Mean, result.Variance = libdrynx.CrossValidationStatistics(result.Folds)
+			log.Lvl2("[API] <Drynx> Client", c.clientID, "cross-validated lambda", lambda, "and K", k, "with accuracy",
+				result.Mean.Accuracy, "and variance", result.Variance.Accuracy)
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// crossValidateFold trains the model without the records of the fold and evaluates it on them
+func (c *API) crossValidateFold(sq libdrynx.SurveyQuery, parameters libdrynx.CrossValidationParameters, point int, fold int, lambda float64, k int, verified bool) (libdrynx.ClassifierEvaluation, error) {
+	trainSQ := sq
+	trainSQ.SurveyID = libdrynx.CrossValidationRoundID(sq.SurveyID, point, fold, "train")
+	lrParameters := &trainSQ.Query.Operation.LRParameters
+	lrParameters.Lambda = lambda
+	lrParameters.K = k
+	lrParameters.Folds = parameters.Folds
+	lrParameters.Fold = fold
+	lrParameters.FoldSeed = parameters.Seed
+	// the querier decodes the model with the number of records it is trained on
+	lrParameters.NbrRecords = lrParameters.NbrRecords * int64(parameters.Folds-1) / int64(parameters.Folds)
+	// the copy of the query shares the initial weights, which the gradient descent modifies
+	lrParameters.InitialWeights = append([]float64(nil), lrParameters.InitialWeights...)
+
+	send := c.SendSurveyQuery
+	if verified {
+		send = c.SendSurveyQueryVerified
+	}
+	proofs := sq.Query.Proofs != 0 && sq.Query.RosterVNs != nil
+
+	// a training without means and standard deviations is skipped by SendSurveyQueryToVNs, standardise registers it
+	// once they are known
+	if proofs {
+		if err := c.SendSurveyQueryToVNs(trainSQ.Query.RosterVNs, &trainSQ); err != nil {
+			return libdrynx.ClassifierEvaluation{}, err
+		}
+	}
+
+	// the model needs the means and standard deviations it is trained with
+	if libdrynx.LogisticRegressionStandardisation(trainSQ.Query.Operation) {
+		var err error
+		if trainSQ, err = c.standardise(trainSQ, verified); err != nil {
+			return libdrynx.ClassifierEvaluation{}, err
+		}
+	}
+	_, weights, err := send(trainSQ)
+	if err != nil {
+		return libdrynx.ClassifierEvaluation{}, err
+	}
+	var model []float64
+	for _, w := range *weights {
+		// the group is suppressed
+		if w == nil {
+			continue
+		}
+		if model != nil {
+			return libdrynx.ClassifierEvaluation{}, errors.New("a cross-validation trains a single model, of a single group")
+		}
+		model = w
+	}
+	if model == nil {
+		return libdrynx.ClassifierEvaluation{}, errors.New("no records to train the model with")
+	}
+
+	evalParameters := trainSQ.Query.Operation.LRParameters
+	evalParameters.Weights = model
+	evalSQ := sq
+	evalSQ.SurveyID = libdrynx.CrossValidationRoundID(sq.SurveyID, point, fold, "eval")
+	evalSQ.Query.Operation = libdrynx.ClassifierEvalOperation(evalParameters, parameters.ScoreBins)
+	// the counts are not obfuscated, which would change them
+	evalSQ.Query.Obfuscation = false
+	evalSQ.ObfuscationProofThreshold = 0
+	if sq.Query.Ranges != nil {
+		ranges := libdrynx.CountRanges(sq.Query.Operation.LRParameters.NbrRecords, evalSQ.Query.Operation.NbrOutput)
+		if err := setOperationRanges(sq, &evalSQ, ranges); err != nil {
+			return libdrynx.ClassifierEvaluation{}, err
+		}
+	}
+	if proofs {
+		if err := c.SendSurveyQueryToVNs(evalSQ.Query.RosterVNs, &evalSQ); err != nil {
+			return libdrynx.ClassifierEvaluation{}, err
+		}
+	}
+
+	_, aggr, err := send(evalSQ)
+	if err != nil {
+		return libdrynx.ClassifierEvaluation{}, err
+	}
+	// the groups are those of the training, of which a single one is not suppressed
+	for _, result := range *aggr {
+		if result != nil {
+			var evaluation libdrynx.ClassifierEvaluation
+			evaluation.FromFloats(result, libdrynx.ClassifierClasses(evalParameters), parameters.ScoreBins)
+			return evaluation, nil
+		}
+	}
+	return libdrynx.ClassifierEvaluation{}, errors.New("no records to evaluate the model with")
+}
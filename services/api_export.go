@@ -0,0 +1,269 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/merkle"
+	"github.com/ldsec/drynx/lib/proof"
+	"github.com/ldsec/unlynx/lib"
+	"go.dedis.ch/cothority/v3/skipchain"
+	"go.dedis.ch/onet/v3"
+	"go.dedis.ch/onet/v3/network"
+)
+
+// Proofs export
+//______________________________________________________________________________________________________________________
+
+// exportPageSize is the number of proofs fetched at once from a VN when exporting
+const exportPageSize = 100
+
+// SendGetQuery requests the survey query stored by a VN
+func (c *API) SendGetQuery(serverID *network.ServerIdentity, surveyID string) (*libdrynx.SurveyQuery, error) {
+	sq := libdrynx.SurveyQuery{}
+	if err := c.SendProtobuf(serverID, &libdrynx.GetQuery{ID: surveyID}, &sq); err != nil {
+		return nil, err
+	}
+	sq.Query.IVSigs.InputValidationSigs = recreateRangeSignatures(sq.Query.IVSigs)
+	return &sq, nil
+}
+
+// ExportProofs collects from a VN everything needed to re-verify the proofs of a survey without the network: the
+// query, the stored proofs with their signatures, the block (and its signing predecessor) recording the verdicts and
+// the genesis block of the chain
+func (c *API) ExportProofs(serverID *network.ServerIdentity, surveyID string) (*libdrynx.ProofsExport, error) {
+	sq, err := c.SendGetQuery(serverID, surveyID)
+	if err != nil {
+		return nil, err
+	}
+	rosterVNs := sq.Query.RosterVNs
+
+	sb, err := c.SendGetBlock(rosterVNs, surveyID)
+	if err != nil {
+		return nil, err
+	}
+	if sb.Index == 0 {
+		return nil, errors.New("block of survey " + surveyID + " is the genesis block")
+	}
+	previous, err := skipchain.NewClient().GetSingleBlock(rosterVNs, sb.BackLinkIDs[0])
+	if err != nil {
+		return nil, err
+	}
+	genesis, err := skipchain.NewClient().GetSingleBlock(rosterVNs, sb.SkipChainID())
+	if err != nil {
+		return nil, err
+	}
+	dataBlock, err := DecodeDataBlock(sb)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := c.SendGetAllProofs(serverID, &libdrynx.GetProofs{ID: surveyID, Limit: exportPageSize})
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) == 0 {
+		return nil, errors.New("no proofs stored for survey " + surveyID)
+	}
+
+	proofs := make([]libdrynx.ExportedProof, 0, len(stored))
+	for _, p := range stored {
+		if len(p.PrunedHash) > 0 {
+			return nil, errors.New("proofs of survey " + surveyID + " were pruned")
+		}
+
+		verdict, ok := dataBlock.Proofs[p.Key]
+		if !ok {
+			verdict = -1
+		}
+		proofs = append(proofs, libdrynx.ExportedProof{
+			Key:        p.Key,
+			Type:       p.Type,
+			SenderID:   p.SenderID,
+			DifferInfo: p.DifferInfo,
+			Data:       p.Data,
+			Signature:  p.Signature,
+			Verdict:    verdict,
+		})
+	}
+
+	return &libdrynx.ProofsExport{Query: *sq, Genesis: genesis, Block: sb, Previous: previous, Proofs: proofs}, nil
+}
+
+// EncodeProofsExport serializes an export bundle
+func EncodeProofsExport(export *libdrynx.ProofsExport) ([]byte, error) {
+	return network.Marshal(export)
+}
+
+// DecodeProofsExport deserializes an export bundle created by EncodeProofsExport
+func DecodeProofsExport(data []byte) (*libdrynx.ProofsExport, error) {
+	_, msg, err := network.Unmarshal(data, libunlynx.SuiTe)
+	if err != nil {
+		return nil, err
+	}
+	export, ok := msg.(*libdrynx.ProofsExport)
+	if !ok {
+		return nil, errors.New("data does not contain a proofs export")
+	}
+	export.Query.Query.IVSigs.InputValidationSigs = recreateRangeSignatures(export.Query.Query.IVSigs)
+	return export, nil
+}
+
+// ProofCheck is the outcome of the offline verification of one exported proof
+type ProofCheck struct {
+	Key      string
+	Type     string
+	Recorded string // verdict found in the block
+	Verified string // verdict obtained by re-running the verification
+	Errors   []string
+}
+
+// ExportReport is the outcome of the offline verification of an export bundle
+type ExportReport struct {
+	SurveyID string
+	Block    string
+	Errors   []string // problems with the block itself
+	Proofs   []ProofCheck
+}
+
+// Failed returns the number of proofs whose verification failed or disagrees with the block
+func (r ExportReport) Failed() int {
+	failed := 0
+	for _, p := range r.Proofs {
+		if len(p.Errors) > 0 {
+			failed++
+		}
+	}
+	return failed
+}
+
+// VerifyProofsExport re-verifies offline every proof of an export bundle against the query it contains, and checks
+// that the verdicts agree with the ones recorded in the block. The block must belong to the chain of the trusted genesis
+// block and be collectively signed by the VNs of its roster: the roster of the query in the bundle is not trusted.
+func VerifyProofsExport(export *libdrynx.ProofsExport, genesisID skipchain.SkipBlockID) ExportReport {
+	report := ExportReport{SurveyID: export.Query.SurveyID, Errors: make([]string, 0), Proofs: make([]ProofCheck, 0)}
+
+	if export.Block == nil {
+		report.Errors = append(report.Errors, "no block in export")
+	} else {
+		report.Block = fmt.Sprintf("%x", export.Block.Hash)
+		if err := verifyGenesis(export.Genesis, genesisID); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		} else if !export.Block.SkipChainID().Equal(genesisID) {
+			report.Errors = append(report.Errors, "block is not in the chain of the trusted genesis block")
+		} else if err := verifyBlockLink(export.Genesis.Roster, export.Block, export.Previous); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		}
+		if dataBlock, err := DecodeDataBlock(export.Block); err != nil {
+			report.Errors = append(report.Errors, err.Error())
+		} else if dataBlock.SurveyID != export.Query.SurveyID {
+			report.Errors = append(report.Errors, "block is for survey "+dataBlock.SurveyID)
+		} else if !bytes.Equal(exportedProofsRoot(export), dataBlock.ProofsRoot) {
+			report.Errors = append(report.Errors, "proofs do not match the root in the block")
+		}
+	}
+
+	// every proof is checked, regardless of the sampling used by the VNs
+	source := network.ServerIdentity{}
+	for _, p := range export.Proofs {
+		check := ProofCheck{Key: p.Key, Type: p.Type, Recorded: drynxproof.VerdictName(p.Verdict), Errors: make([]string, 0)}
+
+		stored := drynxproof.ProofToStoreInDB{SenderID: p.SenderID, DifferInfo: p.DifferInfo, Data: p.Data, Signature: p.Signature}
+		verdict, err := drynxproof.VerifyStoredProof(source, export.Query, p.Type, stored)
+		check.Verified = drynxproof.VerdictName(verdict)
+		if err != nil {
+			check.Errors = append(check.Errors, err.Error())
+		}
+		if verdict != drynxproof.ProofTrue {
+			check.Errors = append(check.Errors, "proof does not verify")
+		}
+		// an unchecked proof (not sampled by the VN) cannot disagree with the block
+		if p.Verdict != verdict && drynxproof.VerdictName(p.Verdict) != "unchecked" {
+			check.Errors = append(check.Errors, "verdict differs from the one recorded in the block")
+		}
+
+		report.Proofs = append(report.Proofs, check)
+	}
+	return report
+}
+
+// exportedProofsRoot computes the root of the Merkle tree over the exported proofs, as done by the VNs
+func exportedProofsRoot(export *libdrynx.ProofsExport) []byte {
+	proofIDs := make([]string, 0, len(export.Proofs))
+	dataHashes := make(map[string][]byte)
+	for _, p := range export.Proofs {
+		proofID := drynxproof.ProofID(export.Query.SurveyID, p.Type, p.SenderID, p.DifferInfo)
+		hash := sha256.Sum256(p.Data)
+		proofIDs = append(proofIDs, proofID)
+		dataHashes[proofID] = hash[:]
+	}
+	sort.Strings(proofIDs)
+
+	leaves := make([][]byte, len(proofIDs))
+	for i, proofID := range proofIDs {
+		leaves[i] = libdrynxmerkle.Leaf(proofID, dataHashes[proofID])
+	}
+	return libdrynxmerkle.Root(leaves)
+}
+
+// verifyGenesis checks that a block is the trusted genesis block
+func verifyGenesis(genesis *skipchain.SkipBlock, genesisID skipchain.SkipBlockID) error {
+	if genesis == nil || genesisID.IsNull() {
+		return errors.New("no genesis block to trust")
+	}
+	if genesis.Index != 0 || !genesis.Hash.Equal(genesisID) || !genesis.Hash.Equal(genesis.CalculateHash()) {
+		return errors.New("genesis block is not the trusted one")
+	}
+	return nil
+}
+
+// verifyBlockLink checks a block and the forward link of its predecessor that holds its collective signature by the
+// trusted VNs, without contacting them. The genesis block holds no survey and has no predecessor: it is rejected.
+func verifyBlockLink(rosterVNs *onet.Roster, sb, previous *skipchain.SkipBlock) error {
+	if !sb.Hash.Equal(sb.CalculateHash()) {
+		return errors.New("hash of block does not match its content")
+	}
+	if sb.Index == 0 {
+		return errors.New("genesis block holds no survey and is not signed by the verifying nodes")
+	}
+	if !sameRoster(sb.Roster, rosterVNs) {
+		return errors.New("block was not created by the verifying nodes")
+	}
+	if err := sb.VerifyForwardSignatures(); err != nil {
+		return err
+	}
+
+	if previous == nil || len(sb.BackLinkIDs) == 0 || !previous.Hash.Equal(sb.BackLinkIDs[0]) {
+		return errors.New("missing previous block")
+	}
+	// the signature of the forward link is checked against the keys of the roster of the previous block
+	if !sameRoster(previous.Roster, rosterVNs) {
+		return errors.New("previous block was not created by the verifying nodes")
+	}
+	if err := previous.VerifyForwardSignatures(); err != nil {
+		return err
+	}
+	for _, fl := range previous.ForwardLink {
+		if fl.From.Equal(previous.Hash) && fl.To.Equal(sb.Hash) {
+			return nil
+		}
+	}
+	return errors.New("no signed forward link to the block")
+}
+
+// sameRoster tells whether a roster holds the same nodes, with the same keys, as a trusted one
+func sameRoster(roster, trusted *onet.Roster) bool {
+	if roster == nil || trusted == nil || len(roster.List) != len(trusted.List) {
+		return false
+	}
+	for i, si := range roster.List {
+		if !si.Public.Equal(trusted.List[i].Public) || !si.ServicePublic(skipchain.ServiceName).Equal(trusted.List[i].ServicePublic(skipchain.ServiceName)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+
+	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/encoding"
+)
+
+// K-means
+//______________________________________________________________________________________________________________________
+
+// sendSurveyQueryKMeans clusters the records of the DPs with a survey per round: the DPs assign their records to the
+// centroids of the previous round, which are part of the query, and send the number of records and the sums of their
+// coordinates in each cluster. The querier moves the centroids to the means of their clusters until they move less than
+// the tolerance. The result of each group is a libdrynx.KMeansResult.
+func (c *API) sendSurveyQueryKMeans(sq libdrynx.SurveyQuery, verified bool) (*[]string, *[][]float64, error) {
+	parameters := sq.Query.Operation.KMParameters
+	d := int(sq.Query.Operation.LRParameters.NbrFeatures)
+	if parameters.K < 1 || d < 1 || len(parameters.InitialCentroids) != parameters.K*d {
+		return nil, nil, errors.New("a k-means clustering needs K initial centroids of the features")
+	}
+
+	round := func(round int, centroids map[string]*libdrynx.GroupWeights) libdrynx.SurveyQuery {
+		roundSQ := sq
+		roundSQ.SurveyID = libdrynx.KMeansRoundID(sq.SurveyID, round)
+		roundSQ.Query.Operation.NbrOutput = libdrynx.KMeansOperation(sq.Query.Operation.LRParameters, parameters).NbrOutput
+		roundSQ.Query.Operation.KMParameters.Round = true
+		roundSQ.Query.Operation.KMParameters.RoundCentroids = centroids
+		return roundSQ
+	}
+	sizes := make(map[string][]float64)
+	step := func(group string, centroids []float64, sums []float64) ([]float64, float64) {
+		updated, groupSizes, shift := libdrynxencoding.KMeansStep(centroids, sums, d)
+		sizes[group] = groupSizes
+		return updated, shift
+	}
+	grp, centroids, iterations, err := c.sendSurveyQueryRounds(sq, verified, parameters.MaxIterations, parameters.Tolerance, parameters.InitialCentroids, round, step)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	aggr := make([][]float64, len(*grp))
+	for i, group := range *grp {
+		if cs, ok := centroids[group]; ok {
+			result := libdrynx.KMeansResult{Centroids: libdrynxencoding.KMeansCentroidMatrix(cs.Weights, d),
+				Sizes: sizes[group], Iterations: iterations}
+			aggr[i] = result.ToFloats()
+		}
+	}
+	return grp, &aggr, nil
+}
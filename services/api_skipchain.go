@@ -1,8 +1,19 @@
 package services
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
 	"github.com/btcsuite/goleveldb/leveldb/errors"
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/merkle"
+	"github.com/ldsec/drynx/lib/proof"
+	"github.com/ldsec/unlynx/lib"
 	"go.dedis.ch/cothority/v3/skipchain"
 	"go.dedis.ch/onet/v3"
 	"go.dedis.ch/onet/v3/log"
@@ -13,16 +24,62 @@ import (
 //______________________________________________________________________________________________________________________
 
 // SendSurveyQueryToVNs creates a survey based on a set of entities (servers) and a survey description.
+// If the query has no sampling seed, the hash of the latest block of the VNs' skipchain is used. The VNs derive the seed
+// of the sampling from it and the root of the proofs once they are all stored. A min or max searched bit by bit gives
+// one survey per round. The rounds of an iterative logistic regression or of a k-means clustering are registered as they
+// start, their number depends on the convergence, and so are the survey of the means and standard deviations of a
+// logistic regression and the logistic regression with them.
 func (c *API) SendSurveyQueryToVNs(entities *onet.Roster, query *libdrynx.SurveyQuery) error {
-	for _, si := range entities.List {
-		err := c.SendProtobuf(si, &libdrynx.SurveyQueryToVN{SQ: *query}, nil)
-		if err != nil {
+	if query.SamplingSeed == nil {
+		// no genesis block means that this is the first survey, the seed then stays empty
+		genesis, err := c.SendGetGenesis(entities.List[0])
+		if err == nil {
+			latest, err := c.SendGetLatestBlock(entities, genesis)
+			if err != nil {
+				return err
+			}
+			query.SamplingSeed = latest.Hash
+		} else if !strings.Contains(err.Error(), ErrNoGenesis) {
 			return err
 		}
 	}
+
+	if libdrynx.LogisticRegressionIterative(query.Query.Operation) && !query.Query.Operation.LRParameters.GradientRound {
+		return nil
+	}
+	if libdrynx.KMeansIterative(query.Query.Operation) {
+		return nil
+	}
+	// the query changes with the means and standard deviations, it is registered once they are known
+	if libdrynx.LogisticRegressionStandardisation(query.Query.Operation) {
+		return nil
+	}
+
+	for _, sq := range minMaxRounds(*query) {
+		for _, si := range entities.List {
+			err := c.SendProtobuf(si, &libdrynx.SurveyQueryToVN{SQ: sq}, nil)
+			if err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
+// minMaxRounds gives the surveys of the rounds of a min or max searched bit by bit, from the first one, or the query
+// itself. The VNs do not need the bits searched to verify the proofs.
+func minMaxRounds(sq libdrynx.SurveyQuery) []libdrynx.SurveyQuery {
+	if !libdrynx.MinMaxBitwise(sq.Query.Operation) || sq.Query.Operation.MinMaxRound {
+		return []libdrynx.SurveyQuery{sq}
+	}
+
+	rounds := make([]libdrynx.SurveyQuery, 0)
+	for bit := libdrynx.MinMaxRounds(sq.Query.Operation) - 1; bit >= 0; bit-- {
+		rounds = append(rounds, minMaxRound(sq, bit, nil))
+	}
+	return rounds
+}
+
 // Wait for proofs' verification
 //______________________________________________________________________________________________________________________
 
@@ -37,6 +94,145 @@ func (c *API) SendEndVerification(si *network.ServerIdentity, queryInfoID string
 	return reply.Latest, nil
 }
 
+// Verified results
+//______________________________________________________________________________________________________________________
+
+// ProofVerificationError is returned when the block of a survey shows that some of its proofs did not reach their threshold
+type ProofVerificationError struct {
+	SurveyID string
+	Failures []drynxproof.ProofFailure
+}
+
+func (e *ProofVerificationError) Error() string {
+	failed := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		failed[i] = fmt.Sprintf("%s (%d/%d accepted, %d rejected, %d undecided)", f.Type, f.Accepted, f.Expected, len(f.Rejected), len(f.Undecided))
+	}
+	return "proofs of survey " + e.SurveyID + " failed verification: " + strings.Join(failed, ", ")
+}
+
+// SendSurveyQueryVerified sends the query (as SendSurveyQuery) and only returns the result once the verifying nodes
+// have added the survey's block to the skipchain and all the required proofs reached their threshold
+func (c *API) SendSurveyQueryVerified(sq libdrynx.SurveyQuery) (*[]string, *[][]float64, error) {
+	if sq.Query.RosterVNs == nil {
+		return nil, nil, errors.New("no verifying nodes to check the proofs")
+	}
+	if libdrynx.LogisticRegressionStandardisation(sq.Query.Operation) {
+		var err error
+		if sq, err = c.standardise(sq, true); err != nil {
+			return nil, nil, err
+		}
+	}
+	if libdrynx.LogisticRegressionIterative(sq.Query.Operation) && !sq.Query.Operation.LRParameters.GradientRound {
+		return c.sendSurveyQueryIterative(sq, true)
+	}
+	if libdrynx.KMeansIterative(sq.Query.Operation) {
+		return c.sendSurveyQueryKMeans(sq, true)
+	}
+
+	grp, aggr, err := c.SendSurveyQuery(sq)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, round := range minMaxRounds(sq) {
+		if _, err := c.VerifySurvey(round); err != nil {
+			return nil, nil, err
+		}
+	}
+	return grp, aggr, nil
+}
+
+// VerifySurvey waits for the verification of the survey's proofs and checks its block: the collective signature of the
+// VNs and the verdicts of the bitmap. It returns a *ProofVerificationError if some proofs did not reach their threshold.
+func (c *API) VerifySurvey(sq libdrynx.SurveyQuery) (*libdrynx.DataBlock, error) {
+	rosterVNs := sq.Query.RosterVNs
+
+	sbEnd, err := c.SendEndVerification(rosterVNs.List[0], sq.SurveyID)
+	if err != nil {
+		return nil, err
+	}
+	sb, err := c.SendGetBlock(rosterVNs, sq.SurveyID)
+	if err != nil {
+		return nil, err
+	}
+	if !sb.Hash.Equal(sbEnd.Hash) {
+		return nil, errors.New("block of survey " + sq.SurveyID + " does not match the one created at the end of the verification")
+	}
+	if err := VerifyProofBlock(rosterVNs, sb); err != nil {
+		return nil, err
+	}
+
+	dataBlock, err := DecodeDataBlock(sb)
+	if err != nil {
+		return nil, err
+	}
+	if dataBlock.SurveyID != sq.SurveyID {
+		return nil, errors.New("block contains survey " + dataBlock.SurveyID + " instead of " + sq.SurveyID)
+	}
+
+	failures := drynxproof.CheckBitmap(sq, dataBlock.Proofs, vnAddresses(rosterVNs), dataBlock.SamplingSeed)
+	if len(failures) > 0 {
+		return dataBlock, &ProofVerificationError{SurveyID: sq.SurveyID, Failures: failures}
+	}
+	return dataBlock, nil
+}
+
+// VerifyProofBlock checks that a block is consistent with its hash and that it was collectively signed by the trusted
+// VNs. The signature of a block is held by the forward link of its predecessor: the genesis block, which holds no
+// survey, cannot be verified.
+func VerifyProofBlock(rosterVNs *onet.Roster, sb *skipchain.SkipBlock) error {
+	var previous *skipchain.SkipBlock
+	if sb.Index > 0 && len(sb.BackLinkIDs) > 0 {
+		var err error
+		previous, err = skipchain.NewClient().GetSingleBlock(rosterVNs, sb.BackLinkIDs[0])
+		if err != nil {
+			return err
+		}
+	}
+	return verifyBlockLink(rosterVNs, sb, previous)
+}
+
+// DecodeDataBlock extracts the drynx data from a skipblock
+func DecodeDataBlock(sb *skipchain.SkipBlock) (*libdrynx.DataBlock, error) {
+	_, msg, err := network.Unmarshal(sb.Data, libunlynx.SuiTe)
+	if err != nil {
+		return nil, err
+	}
+	dataBlock, ok := msg.(*libdrynx.DataBlock)
+	if !ok {
+		return nil, errors.New("block does not contain a DataBlock")
+	}
+	return dataBlock, nil
+}
+
+// SendGetProofInclusion requests from a VN the path of a proof in the Merkle tree whose root is in the block of a survey
+func (c *API) SendGetProofInclusion(serverID *network.ServerIdentity, surveyID, proofID string) (*libdrynx.ProofInclusion, error) {
+	result := libdrynx.ProofInclusion{}
+	err := c.SendProtobuf(serverID, &libdrynx.GetProofInclusion{ID: surveyID, ProofID: proofID}, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// VerifyProofInclusion checks that the data of a proof is the one committed to in a block. The block itself should be
+// checked with VerifyProofBlock.
+func VerifyProofInclusion(sb *skipchain.SkipBlock, inclusion *libdrynx.ProofInclusion, data []byte) error {
+	dataBlock, err := DecodeDataBlock(sb)
+	if err != nil {
+		return err
+	}
+	dataHash := sha256.Sum256(data)
+	if !bytes.Equal(dataHash[:], inclusion.DataHash) {
+		return errors.New("data does not match the hash of proof " + inclusion.ProofID)
+	}
+	if !libdrynxmerkle.Verify(libdrynxmerkle.Leaf(inclusion.ProofID, inclusion.DataHash), inclusion.Path, dataBlock.ProofsRoot) {
+		return errors.New("proof " + inclusion.ProofID + " is not included in the block")
+	}
+	return nil
+}
+
 // Skipchain utilities
 //______________________________________________________________________________________________________________________
 
@@ -67,6 +263,120 @@ func (c *API) SendGetGenesis(toAsk *network.ServerIdentity) (*skipchain.SkipBloc
 	return reply.Latest, nil
 }
 
+// SendGetChain requests all the blocks of the skipchain, from the genesis to the latest block
+func (c *API) SendGetChain(roster *onet.Roster) ([]*skipchain.SkipBlock, error) {
+	genesis, err := c.SendGetGenesis(roster.List[0])
+	if err != nil {
+		return nil, err
+	}
+	latest, err := c.SendGetLatestBlock(roster, genesis)
+	if err != nil {
+		return nil, err
+	}
+
+	// only follow the direct forward links to get every block
+	blocks, err := skipchain.NewClient().GetUpdateChainLevel(roster, genesis.Hash, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	if !blocks[len(blocks)-1].Hash.Equal(latest.Hash) {
+		return nil, errors.New("chain does not end with the latest block")
+	}
+	return blocks, nil
+}
+
+// BlockAudit is the report of the verification of one block of the skipchain
+type BlockAudit struct {
+	Index      int
+	Hash       string
+	SurveyID   string
+	Time       time.Time
+	Roster     []string
+	Verdicts   map[string]map[string]int // proof type -> verdict name -> count
+	ProofsRoot string                    // root of the Merkle tree over the proofs
+	// VNs that gave opposite verdicts on a proof, and number of verdicts of each VN opposite to the agreed one
+	Disagreements []libdrynx.Disagreement
+	Deviations    map[string]int64
+	Errors        []string
+}
+
+// AuditChain decodes and verifies a list of consecutive blocks: their hash, links, signatures and content
+func AuditChain(blocks []*skipchain.SkipBlock) []BlockAudit {
+	audits := make([]BlockAudit, len(blocks))
+	for i, sb := range blocks {
+		audit := BlockAudit{Index: sb.Index, Hash: hex.EncodeToString(sb.Hash), Errors: make([]string, 0)}
+		fail := func(msg string) {
+			audit.Errors = append(audit.Errors, msg)
+		}
+
+		if !sb.Hash.Equal(sb.CalculateHash()) {
+			fail("hash does not match the content of the block")
+		}
+		if err := sb.VerifyForwardSignatures(); err != nil {
+			fail(err.Error())
+		}
+		if i > 0 {
+			previous := blocks[i-1]
+			if sb.Index != previous.Index+1 {
+				fail(fmt.Sprintf("index %d does not follow %d", sb.Index, previous.Index))
+			}
+			if len(sb.BackLinkIDs) == 0 || !sb.BackLinkIDs[0].Equal(previous.Hash) {
+				fail("back link does not point to the previous block")
+			}
+			if len(previous.ForwardLink) == 0 || !previous.ForwardLink[0].To.Equal(sb.Hash) {
+				fail("forward link of the previous block does not point to the block")
+			}
+		} else if sb.Index != 0 {
+			fail("chain does not start with the genesis block")
+		}
+
+		if dataBlock, err := DecodeDataBlock(sb); err != nil {
+			fail(err.Error())
+		} else {
+			audit.SurveyID = dataBlock.SurveyID
+			audit.Time = dataBlock.Time
+			audit.Verdicts = drynxproof.CountVerdicts(dataBlock.SurveyID, dataBlock.Proofs)
+			audit.ProofsRoot = hex.EncodeToString(dataBlock.ProofsRoot)
+			audit.Disagreements = dataBlock.Disagreements
+			audit.Deviations = dataBlock.Deviations
+			if !drynxproof.Agree(dataBlock.Proofs, vnAddresses(dataBlock.Roster)).Matches(dataBlock) {
+				fail("agreement does not match the bitmap")
+			}
+			if dataBlock.Roster != nil {
+				for _, si := range dataBlock.Roster.List {
+					audit.Roster = append(audit.Roster, si.Address.String())
+				}
+			}
+		}
+		audits[i] = audit
+	}
+	return audits
+}
+
+// FlagDeviatingVNs returns the VNs whose verdicts differed from the agreed ones in most of the audited blocks they
+// were part of
+func FlagDeviatingVNs(audits []BlockAudit) []string {
+	blocks := make(map[string]int)
+	deviating := make(map[string]int)
+	for _, audit := range audits {
+		for _, vn := range audit.Roster {
+			blocks[vn]++
+			if audit.Deviations[vn] > 0 {
+				deviating[vn]++
+			}
+		}
+	}
+
+	flagged := make([]string, 0)
+	for vn, nbr := range deviating {
+		if 2*nbr > blocks[vn] {
+			flagged = append(flagged, vn)
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
+
 // SendGetBlock requests the block for a specific query
 func (c *API) SendGetBlock(entities *onet.Roster, surveyID string) (*skipchain.SkipBlock, error) {
 	reply := &libdrynx.Reply{}
@@ -82,16 +392,45 @@ func (c *API) SendGetBlock(entities *onet.Roster, surveyID string) (*skipchain.S
 // DB utilities
 //______________________________________________________________________________________________________________________
 
-// SendGetProofs requests proofs for a specific query
-func (c *API) SendGetProofs(serverID *network.ServerIdentity, surveyID string) (map[string][]byte, error) {
-	result := libdrynx.ProofsAsMap{}
+// SendGetProofs requests a page of the proofs for a specific query matching the filters of the request
+func (c *API) SendGetProofs(serverID *network.ServerIdentity, request *libdrynx.GetProofs) (*libdrynx.ProofsPage, error) {
+	result := libdrynx.ProofsPage{}
 
-	err := c.SendProtobuf(serverID, &libdrynx.GetProofs{ID: surveyID}, &result)
+	err := c.SendProtobuf(serverID, request, &result)
 	if err != nil {
 		return nil, err
 	}
 
-	return result.Proofs, err
+	return &result, err
+}
+
+// SendGetAllProofs requests all the proofs for a specific query matching the filters of the request, page by page
+func (c *API) SendGetAllProofs(serverID *network.ServerIdentity, request *libdrynx.GetProofs) ([]libdrynx.StoredProof, error) {
+	proofs := make([]libdrynx.StoredProof, 0)
+	pageRequest := *request
+	for {
+		page, err := c.SendGetProofs(serverID, &pageRequest)
+		if err != nil {
+			return nil, err
+		}
+		proofs = append(proofs, page.Proofs...)
+		if page.Next == "" {
+			return proofs, nil
+		}
+		pageRequest.Cursor = page.Next
+	}
+}
+
+// SendPruneDB requests the VNs to prune the proofs of the surveys older than a given age
+func (c *API) SendPruneDB(entities *onet.Roster, request *libdrynx.PruneDB) ([]libdrynx.PruneDBReply, error) {
+	replies := make([]libdrynx.PruneDBReply, len(entities.List))
+	for i, si := range entities.List {
+		err := c.SendProtobuf(si, request, &replies[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return replies, nil
 }
 
 // SendCloseDB requests the closure of the DB of some nodes
@@ -33,11 +33,20 @@ const ServiceName = "drynx"
 
 const gobFile = "pre_compute_multiplications.gob"
 
+// the protocols that obfuscate and shuffle the size tests of the groups in the suppression of the small groups, without
+// proofs
+const (
+	suppressionObfuscationProtocolName = "SuppressionObfuscation"
+	suppressionShufflingProtocolName   = "SuppressionShuffling"
+)
+
 // Survey represents a survey with the corresponding params
 type Survey struct {
 	SurveyQuery        libdrynx.SurveyQuery
 	QueryResponseState libdrynx.ResponseAllDPs // QueryResponse keeps track of the response from the data providers, the aggregated data, and the final results
 	Noises             libunlynx.CipherVector
+	TaggingTarget      libunlynx.CipherVector   // the values tagged in the deterministic tagging protocol
+	SizeTests          []libunlynx.CipherVector // the size tests of the groups, obfuscated and shuffled before they are tagged
 	ShufflePrecompute  []libunlynxshuffle.CipherVectorScalar
 	MapPIs             map[string]onet.ProtocolInstance
 
@@ -96,10 +105,8 @@ type ServiceDrynx struct {
 	DB     *bbolt.DB
 	//To make everything thread safe (database access and updating parameters)
 	Mutex *sync.Mutex
-	// Too receive the bitmaps from the nodes
-	SharedBMChannel chan map[string]int64
-	// To finish the protocols
-	SharedBMChannelToTerminate chan struct{}
+	// To append the blocks of concurrent surveys in the order in which they were received
+	blocks blockQueue
 	// -------------------------
 }
 
@@ -117,6 +124,10 @@ var msgTypes = MsgTypes{}
 func init() {
 	_, err := onet.RegisterNewService(ServiceName, NewService)
 	log.ErrFatal(err)
+	_, err = onet.GlobalProtocolRegister(suppressionObfuscationProtocolName, protocols.NewObfuscationProtocol)
+	log.ErrFatal(err)
+	_, err = onet.GlobalProtocolRegister(suppressionShufflingProtocolName, protocolsunlynx.NewShufflingProtocol)
+	log.ErrFatal(err)
 	//onet.RegisterNewServiceWithSuite(ServiceName, libunlynx.SuiTe, NewService)
 
 	msgTypes.msgSurveyQuery = network.RegisterMessage(&libdrynx.SurveyQuery{})
@@ -135,6 +146,13 @@ func init() {
 	network.RegisterMessage(&libdrynx.GetGenesis{})
 	network.RegisterMessage(&libdrynx.GetBlock{})
 	network.RegisterMessage(&libdrynx.GetProofs{})
+	network.RegisterMessage(&libdrynx.ProofsPage{})
+	network.RegisterMessage(&libdrynx.GetQuery{})
+	network.RegisterMessage(&libdrynx.GetProofInclusion{})
+	network.RegisterMessage(&libdrynx.ProofInclusion{})
+	network.RegisterMessage(&libdrynx.PruneDB{})
+	network.RegisterMessage(&libdrynx.PruneDBReply{})
+	network.RegisterMessage(&libdrynx.ProofsExport{})
 	network.RegisterMessage(&libdrynx.CloseDB{})
 }
 
@@ -143,6 +161,7 @@ func NewService(c *onet.Context) (onet.Service, error) {
 	newDrynxInstance := &ServiceDrynx{
 		ServiceProcessor: onet.NewServiceProcessor(c),
 		Survey:           concurrent.NewConcurrentMap(),
+		Request:          concurrent.NewConcurrentMap(),
 		Mutex:            &sync.Mutex{},
 	}
 	var cerr error
@@ -162,9 +181,9 @@ func NewService(c *onet.Context) (onet.Service, error) {
 		if cerr = newDrynxInstance.RegisterHandler(newDrynxInstance.HandleSyncDCP); cerr != nil {
 			log.Fatal("[SERVICE] <drynx> Server, Wrong Handler.", cerr)
 		}
-		if cerr = newDrynxInstance.RegisterHandler(newDrynxInstance.HandleDPdataFinished); cerr != nil {
-			log.Fatal("[SERVICE] <drynx> Server, Wrong Handler.", cerr)
-		}
+	}
+	if cerr = newDrynxInstance.RegisterHandler(newDrynxInstance.HandleDPdataFinished); cerr != nil {
+		log.Fatal("[SERVICE] <drynx> Server, Wrong Handler.", cerr)
 	}
 	if cerr = newDrynxInstance.RegisterHandler(newDrynxInstance.HandleEndVerification); cerr != nil {
 		log.Fatal("[SERVICE] <drynx> Server, Wrong Handler.", cerr)
@@ -181,6 +200,15 @@ func NewService(c *onet.Context) (onet.Service, error) {
 	if cerr = newDrynxInstance.RegisterHandler(newDrynxInstance.HandleGetProofs); cerr != nil {
 		log.Fatal("[SERVICE] <drynx> Server, Wrong Handler.", cerr)
 	}
+	if cerr = newDrynxInstance.RegisterHandler(newDrynxInstance.HandleGetQuery); cerr != nil {
+		log.Fatal("[SERVICE] <drynx> Server, Wrong Handler.", cerr)
+	}
+	if cerr = newDrynxInstance.RegisterHandler(newDrynxInstance.HandleGetProofInclusion); cerr != nil {
+		log.Fatal("[SERVICE] <drynx> Server, Wrong Handler.", cerr)
+	}
+	if cerr = newDrynxInstance.RegisterHandler(newDrynxInstance.HandlePruneDB); cerr != nil {
+		log.Fatal("[SERVICE] <drynx> Server, Wrong Handler.", cerr)
+	}
 	if cerr = newDrynxInstance.RegisterHandler(newDrynxInstance.HandleCloseDB); cerr != nil {
 		log.Fatal("[SERVICE] <drynx> Server, Wrong Handler.", cerr)
 	}
@@ -190,8 +218,8 @@ func NewService(c *onet.Context) (onet.Service, error) {
 	if waitOnLocalChans {
 		c.RegisterProcessor(newDrynxInstance, msgTypes.msgDPqueryReceived)
 		c.RegisterProcessor(newDrynxInstance, msgTypes.msgSyncDCP)
-		c.RegisterProcessor(newDrynxInstance, msgTypes.msgDPdataFinished)
 	}
+	c.RegisterProcessor(newDrynxInstance, msgTypes.msgDPdataFinished)
 
 	//Register new verifFunction
 	if err := skipchain.RegisterVerification(c, VerifyBitmap, newDrynxInstance.verifyFuncBitmap); err != nil {
@@ -219,7 +247,7 @@ func (s *ServiceDrynx) Process(msg *network.Envelope) {
 		tmp := (msg.Msg).(*SyncDCP)
 		_, err := s.HandleSyncDCP(tmp)
 		log.ErrFatal(err)
-	} else if waitOnLocalChans && msg.MsgType.Equal(msgTypes.msgDPdataFinished) {
+	} else if msg.MsgType.Equal(msgTypes.msgDPdataFinished) {
 		tmp := (msg.Msg).(*DPdataFinished)
 		_, err := s.HandleDPdataFinished(tmp)
 		log.ErrFatal(err)
@@ -302,7 +330,7 @@ func (s *ServiceDrynx) HandleSurveyQuery(recq *libdrynx.SurveyQuery) (network.Me
 		SurveyQuery:    *recq,
 		DPqueryChannel: make(chan int, nbrDPs),
 		SyncDCPChannel: make(chan int, nbrDPs),
-		DPdataChannel:  make(chan int, nbrDPs),
+		DPdataChannel:  make(chan int, len(recq.RosterServers.List)),
 		DiffPChannel:   make(chan int, nbrDPs),
 		MapPIs:         mapPIs,
 	})
@@ -388,15 +416,15 @@ func (s *ServiceDrynx) HandleSurveyQuery(recq *libdrynx.SurveyQuery) (network.Me
 		libunlynx.EndTimer(startDataCollectionProtocol)
 	}
 
-	if waitOnLocalChans {
-		//startWaitTimeDPs := libunlynx.StartTimer(s.ServerIdentity().String() + "_WaitTimeDPs")
-		// signal other nodes that the data provider(s) already sent their data (response)
-		info("broadcasting [DPdataFinished]", err)
-		err = libunlynxtools.SendISMOthers(s.ServiceProcessor, &recq.RosterServers, &DPdataFinished{recq.SurveyID})
-		if err != nil {
-			die("broadcasting [DPdataFinished] error", err)
-		}
-
+	// signal other nodes that the data provider(s) already sent their data (response), the root waits for the data of
+	// all the CNs before the collective aggregation, in which they contribute the data they have at that time
+	//startWaitTimeDPs := libunlynx.StartTimer(s.ServerIdentity().String() + "_WaitTimeDPs")
+	info("broadcasting [DPdataFinished]", err)
+	err = libunlynxtools.SendISMOthers(s.ServiceProcessor, &recq.RosterServers, &DPdataFinished{recq.SurveyID})
+	if err != nil {
+		die("broadcasting [DPdataFinished] error", err)
+	}
+	if waitOnLocalChans || recq.IntraMessage == false {
 		counter := len(recq.RosterServers.List) - 1
 		for counter > 0 {
 			info("is waiting for", counter, "servers to finish collecting their data")
@@ -469,6 +497,15 @@ func (s *ServiceDrynx) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.Generic
 
 		return pi, nil
 
+	case protocolsunlynx.DeterministicTaggingProtocolName:
+		survey := castToSurvey(s.Survey.Get(target))
+		pi, err = s.NewDeterministicTaggingProtocol(tn, survey)
+		if err != nil {
+			return nil, err
+		}
+
+		return pi, nil
+
 	case protocols.ObfuscationProtocolName:
 		survey := castToSurvey(s.Survey.Get(target))
 		pi, err = protocols.NewObfuscationProtocol(tn)
@@ -483,6 +520,32 @@ func (s *ServiceDrynx) NewProtocol(tn *onet.TreeNodeInstance, conf *onet.Generic
 		obfuscation.Query = &survey.SurveyQuery
 		obfuscation.MapPIs = survey.MapPIs
 
+	case suppressionObfuscationProtocolName:
+		survey := castToSurvey(s.Survey.Get(target))
+		pi, err = protocols.NewObfuscationProtocol(tn)
+		if err != nil {
+			return nil, err
+		}
+
+		obfuscation := pi.(*protocols.ObfuscationProtocol)
+		obfuscation.ToObfuscateData = make(libunlynx.CipherVector, len(survey.SizeTests))
+		for i, v := range survey.SizeTests {
+			obfuscation.ToObfuscateData[i] = v[1]
+		}
+		return pi, nil
+
+	case suppressionShufflingProtocolName:
+		survey := castToSurvey(s.Survey.Get(target))
+		pi, err = protocolsunlynx.NewShufflingProtocol(tn)
+		if err != nil {
+			return nil, err
+		}
+
+		if tn.IsRoot() {
+			pi.(*protocolsunlynx.ShufflingProtocol).ShuffleTarget = &survey.SizeTests
+		}
+		return pi, nil
+
 	case protocolsunlynx.DROProtocolName:
 		survey := castToSurvey(s.Survey.Get(target))
 		log.Lvl2("SERVICE] <drynx> Server", s.ServerIdentity(), " Servers collectively add noise for differential privacy")
@@ -530,14 +593,8 @@ func (s *ServiceDrynx) NewCollectiveAggregationProtocol(tn *onet.TreeNodeInstanc
 	collectiveAggr.MapPIs = survey.MapPIs
 	collectiveAggr.ProofFunc = func(data []libunlynx.CipherVector, res libunlynx.CipherVector) *libunlynxaggr.PublishedAggregationListProof {
 		go func() {
-			//TODO: find a better way to do this (cleaner way)
-			cvMap := make(map[libunlynx.GroupingKey][]libunlynx.CipherVector)
-			survey.QueryResponseState.FormatAggregationProofs(cvMap)
-			aggrLocalProof := libunlynxaggr.PublishedAggregationListProof{}
-			aggrLocalProof.List = make([]libunlynxaggr.PublishedAggregationProof, 0)
-			for k, v := range cvMap {
-				aggrLocalProof.List = append(aggrLocalProof.List, libunlynxaggr.AggregationListProofCreation(v, groupedData[k].AggregatingAttributes).List...)
-			}
+			// the values aggregated by the node are its own and those of its children, for each value of each group
+			aggrLocalProof := libunlynxaggr.AggregationListProofCreation(data, res)
 
 			pi := survey.MapPIs["aggregation/"+s.ServerIdentity().String()]
 			pi.(*protocols.ProofCollectionProtocol).Proof = drynxproof.ProofRequest{AggregationProof: drynxproof.NewAggregationProofRequest(&aggrLocalProof, target, s.ServerIdentity().String(), "", survey.SurveyQuery.Query.RosterVNs, tn.Private(), nil)}
@@ -560,6 +617,24 @@ func (s *ServiceDrynx) NewCollectiveAggregationProtocol(tn *onet.TreeNodeInstanc
 	return pi, nil
 }
 
+// NewDeterministicTaggingProtocol defines a new deterministic tagging protocol
+func (s *ServiceDrynx) NewDeterministicTaggingProtocol(tn *onet.TreeNodeInstance, survey Survey) (onet.ProtocolInstance, error) {
+	pi, err := protocolsunlynx.NewDeterministicTaggingProtocol(tn)
+	if err != nil {
+		return nil, err
+	}
+	tagging := pi.(*protocolsunlynx.DeterministicTaggingProtocol)
+	// the groups are tagged once per survey, so a fresh secret is enough for the tags to be unlinkable across surveys
+	secret := libunlynx.SuiTe.Scalar().Pick(random.New())
+	tagging.SurveySecretKey = &secret
+
+	if tn.IsRoot() {
+		target := survey.TaggingTarget
+		tagging.TargetOfSwitch = &target
+	}
+	return pi, nil
+}
+
 // NewKeySwitchingProtocol defines a new key switching protocol
 func (s *ServiceDrynx) NewKeySwitchingProtocol(tn *onet.TreeNodeInstance, target string, survey Survey) (onet.ProtocolInstance, error) {
 	pi, err := protocolsunlynx.NewKeySwitchingProtocol(tn)
@@ -601,6 +676,14 @@ func (s *ServiceDrynx) NewKeySwitchingProtocol(tn *onet.TreeNodeInstance, target
 			}
 		}
 		keySwitch.TargetOfSwitch = convertToCipherVector(&survey.QueryResponseState)
+		// the encrypted groups are switched with the data for the querier to decrypt them
+		if survey.SurveyQuery.Query.EncryptedGroups {
+			groups, err := encryptedGroups(&survey.QueryResponseState)
+			if err != nil {
+				return nil, err
+			}
+			*keySwitch.TargetOfSwitch = append(*keySwitch.TargetOfSwitch, *groups...)
+		}
 		tmp := survey.SurveyQuery.ClientPubKey
 		keySwitch.TargetPublicKey = &tmp
 
@@ -687,6 +770,19 @@ func (s *ServiceDrynx) StartProtocol(name string, targetSurvey string) (onet.Pro
 	if err != nil {
 		return nil, err
 	}
+	// the root of the key switching computes its contribution in Start, which Dispatch uses as soon as the children
+	// answered, i.e. right away if there is only one CN
+	if name == protocolsunlynx.KeySwitchingProtocolName {
+		if err := pi.Start(); err != nil {
+			return nil, err
+		}
+		go func() {
+			if err := pi.Dispatch(); err != nil {
+				log.Fatal(err)
+			}
+		}()
+		return pi, nil
+	}
 	go func() {
 		if err := pi.Dispatch(); err != nil {
 			log.Fatal(err)
@@ -718,6 +814,22 @@ func (s *ServiceDrynx) StartService(targetSurvey string) error {
 	}
 	libunlynx.EndTimer(aggregationTimer)
 
+	if target.SurveyQuery.Query.EncryptedGroups {
+		err := s.TaggingPhase(target.SurveyQuery.SurveyID)
+		if err != nil {
+			log.Fatal("Error in the Tagging Phase")
+		}
+	}
+
+	if target.SurveyQuery.Query.MinGroupSize > 0 {
+		err := s.SuppressionPhase(target.SurveyQuery.SurveyID)
+		if err != nil {
+			log.Fatal("Error in the Suppression Phase")
+		}
+	}
+
+	// the next phases also run when all the groups have been suppressed, for the verifying nodes to receive their proofs
+	target = castToSurvey(s.Survey.Get((string)(targetSurvey)))
 	if target.SurveyQuery.Query.Obfuscation {
 		//obfuscationTimer := libDrynx.StartTimer(s.ServerIdentity().String() + "_ObfuscationPhase")
 		err := s.ObfuscationPhase(target.SurveyQuery.SurveyID)
@@ -786,6 +898,99 @@ func (s *ServiceDrynx) AggregationPhase(targetSurvey string) error {
 	return nil
 }
 
+// TaggingPhase collectively tags the encrypted groups of the aggregated data and merges the groups with the same tag
+func (s *ServiceDrynx) TaggingPhase(targetSurvey string) error {
+	survey := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	groups, err := encryptedGroups(&survey.QueryResponseState)
+	if err != nil {
+		return err
+	}
+	survey.TaggingTarget = *groups
+	_, err = s.Survey.Put(string(targetSurvey), survey)
+	if err != nil {
+		return err
+	}
+
+	pi, err := s.StartProtocol(protocolsunlynx.DeterministicTaggingProtocolName, targetSurvey)
+	if err != nil {
+		return err
+	}
+	tags := <-pi.(*protocolsunlynx.DeterministicTaggingProtocol).FeedbackChannel
+
+	survey = castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	merged, err := libdrynx.MergeTaggedGroups(survey.QueryResponseState, tags)
+	if err != nil {
+		return err
+	}
+	survey.QueryResponseState = *merged
+	_, err = s.Survey.Put(string(targetSurvey), survey)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// SuppressionPhase withholds the groups with less than Query.MinGroupSize records. The differences between the size of
+// each group and the sizes below the minimum are obfuscated and shuffled before they are tagged, so that the nodes only
+// learn which groups are too small. As the tagging of the groups, the suppression is not covered by the proofs.
+func (s *ServiceDrynx) SuppressionPhase(targetSurvey string) error {
+	survey := castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	if len(survey.QueryResponseState.Data) == 0 {
+		return nil
+	}
+	survey.SizeTests = libdrynx.GroupSizeTests(survey.QueryResponseState, survey.SurveyQuery.Query.MinGroupSize)
+	_, err := s.Survey.Put(string(targetSurvey), survey)
+	if err != nil {
+		return err
+	}
+
+	pi, err := s.StartProtocol(suppressionObfuscationProtocolName, targetSurvey)
+	if err != nil {
+		return err
+	}
+	obfuscated := <-pi.(*protocols.ObfuscationProtocol).FeedbackChannel
+
+	survey = castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	for i, v := range survey.SizeTests {
+		survey.SizeTests[i] = libunlynx.CipherVector{v[0], obfuscated[i]}
+	}
+	_, err = s.Survey.Put(string(targetSurvey), survey)
+	if err != nil {
+		return err
+	}
+
+	pi, err = s.StartProtocol(suppressionShufflingProtocolName, targetSurvey)
+	if err != nil {
+		return err
+	}
+	shuffled := <-pi.(*protocolsunlynx.ShufflingProtocol).FeedbackChannel
+
+	survey = castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	survey.TaggingTarget = libdrynx.SizeTestsTaggingTarget(shuffled, len(survey.QueryResponseState.Data))
+	_, err = s.Survey.Put(string(targetSurvey), survey)
+	if err != nil {
+		return err
+	}
+
+	pi, err = s.StartProtocol(protocolsunlynx.DeterministicTaggingProtocolName, targetSurvey)
+	if err != nil {
+		return err
+	}
+	tags := <-pi.(*protocolsunlynx.DeterministicTaggingProtocol).FeedbackChannel
+
+	survey = castToSurvey(s.Survey.Get((string)(targetSurvey)))
+	suppressed, err := libdrynx.SuppressSmallGroups(survey.QueryResponseState, tags, survey.SurveyQuery.Query.MinGroupSize)
+	if err != nil {
+		return err
+	}
+	survey.QueryResponseState = *suppressed
+	_, err = s.Survey.Put(string(targetSurvey), survey)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
 // ObfuscationPhase performs the obfuscation phase (multiply the aggregated data by a random value from each server)
 func (s *ServiceDrynx) ObfuscationPhase(targetSurvey string) error {
 	pi, err := s.StartProtocol(protocols.ObfuscationProtocolName, targetSurvey)
@@ -856,7 +1061,26 @@ func (s *ServiceDrynx) KeySwitchingPhase(targetSurvey string) error {
 	keySwitchedAggregatedResponses := <-pi.(*protocolsunlynx.KeySwitchingProtocol).FeedbackChannel
 
 	survey := castToSurvey(s.Survey.Get((string)(targetSurvey)))
-	survey.QueryResponseState = *convertFromKeySwitchingStruct(keySwitchedAggregatedResponses, survey.QueryResponseState)
+	if survey.SurveyQuery.Query.EncryptedGroups {
+		nbrData := len(keySwitchedAggregatedResponses) - len(survey.QueryResponseState.Data) - len(survey.QueryResponseState.Suppressed)
+		groups := keySwitchedAggregatedResponses[nbrData:]
+		survey.QueryResponseState = *convertFromKeySwitchingStruct(keySwitchedAggregatedResponses[:nbrData], survey.QueryResponseState)
+		for i := range survey.QueryResponseState.Data {
+			survey.QueryResponseState.Data[i].Group, err = groups[i].Serialize()
+			if err != nil {
+				return err
+			}
+		}
+		groups = groups[len(survey.QueryResponseState.Data):]
+		for i := range survey.QueryResponseState.Suppressed {
+			survey.QueryResponseState.Suppressed[i], err = groups[i].Serialize()
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		survey.QueryResponseState = *convertFromKeySwitchingStruct(keySwitchedAggregatedResponses, survey.QueryResponseState)
+	}
 	_, err = s.Survey.Put(targetSurvey, survey)
 	if err != nil {
 		return err
@@ -878,6 +1102,9 @@ func convertToCipherVector(ad *libdrynx.ResponseAllDPs) *libunlynx.CipherVector
 
 func convertFromKeySwitchingStruct(cv libunlynx.CipherVector, dpResponses libdrynx.ResponseAllDPs) *libdrynx.ResponseAllDPs {
 	data := make([]libdrynx.ResponseDPOneGroup, 0)
+	if len(dpResponses.Data) == 0 {
+		return &libdrynx.ResponseAllDPs{Data: data, Suppressed: dpResponses.Suppressed}
+	}
 
 	length := len(dpResponses.Data[0].Data)
 	init := 0
@@ -890,10 +1117,29 @@ func convertFromKeySwitchingStruct(cv libunlynx.CipherVector, dpResponses libdry
 			groupIndex++
 		}
 	}
-	return &libdrynx.ResponseAllDPs{Data: data}
+	return &libdrynx.ResponseAllDPs{Data: data, Suppressed: dpResponses.Suppressed}
 
 }
 
+// encryptedGroups gives the encrypted groups of the responses followed by the suppressed ones, in the same order
+func encryptedGroups(ad *libdrynx.ResponseAllDPs) (*libunlynx.CipherVector, error) {
+	groups := make([]string, 0, len(ad.Data)+len(ad.Suppressed))
+	for _, response := range ad.Data {
+		groups = append(groups, response.Group)
+	}
+	groups = append(groups, ad.Suppressed...)
+
+	cv := make(libunlynx.CipherVector, len(groups))
+	for i, group := range groups {
+		ct, err := libunlynx.NewCipherTextFromBase64(group)
+		if err != nil {
+			return nil, err
+		}
+		cv[i] = *ct
+	}
+	return &cv, nil
+}
+
 func generateDataCollectionRoster(root *network.ServerIdentity, serverToDP map[string]*[]network.ServerIdentity) *onet.Roster {
 	for key, value := range serverToDP {
 		if key == root.String() {
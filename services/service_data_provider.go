@@ -51,11 +51,14 @@ func (s *ServiceDrynx) generateRangePI(query *libdrynx.SurveyQueryToDP) (map[str
 		if dp.String() == s.ServerIdentity().String() {
 			tree := generateProofCollectionRoster(&dp, query.SQ.Query.RosterVNs).GenerateStar()
 
-			pi, err := s.CreateProofCollectionPIs(tree, query.SQ.SurveyID, protocols.ProofCollectionProtocolName)
-			if err != nil {
-				return nil, err
+			// the range proofs of each group are sent separately
+			for i := range libdrynx.GroupLabels(query.SQ.Query.DPDataGen.GroupByValues) {
+				pi, err := s.CreateProofCollectionPIs(tree, query.SQ.SurveyID, protocols.ProofCollectionProtocolName)
+				if err != nil {
+					return nil, err
+				}
+				mapPIs[protocols.RangePIKey(s.ServerIdentity().String(), i)] = pi
 			}
-			mapPIs["range/"+s.ServerIdentity().String()] = pi
 			break
 		}
 
@@ -1,13 +1,19 @@
 package services
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 
 	"time"
 
-	"github.com/fanliao/go-concurrentMap"
 	"github.com/ldsec/drynx/lib"
+	"github.com/ldsec/drynx/lib/merkle"
+	"github.com/ldsec/drynx/lib/proof"
 	"github.com/ldsec/drynx/protocols"
 	"github.com/ldsec/unlynx/lib"
 	"go.dedis.ch/cothority/v3/skipchain"
@@ -36,19 +42,21 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 	var totalNbrProofs int
 	log.Lvl2("[SERVICE] <VN> Server", s.ServerIdentity().String(), "received a Survey Query")
 
+	// each survey has its own state: a survey cannot replace another one that is still running under the same ID
+	if previous := protocols.CastToQueryInfo(s.Request.Get(recq.SQ.SurveyID)); previous != nil && surveyRunning(previous) {
+		s.Mutex.Unlock()
+		return nil, errors.New("survey " + recq.SQ.SurveyID + " is already in progress")
+	}
+
 	_, err := s.Survey.Put(recq.SQ.SurveyID, Survey{
 		SurveyQuery: recq.SQ,
 		Mutex:       &sync.Mutex{},
 	})
 	if err != nil {
+		s.Mutex.Unlock()
 		return nil, err
 	}
 
-	// create the objects if it is the first time
-	if s.Request == nil {
-		s.Request = concurrent.NewConcurrentMap()
-	}
-
 	sizeQuery := make([]int, 0)
 	proofsVerified := make(map[string]int64)
 	//Put in the concurrent map the info that were calculated.
@@ -62,16 +70,15 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 	sizeQuery = append(sizeQuery, size[4])
 	totalNbrProofs = size[0] + size[2] + size[3] + size[1] + size[4]
 
-	if s.ServerIdentity().String() == recq.SQ.Query.RosterVNs.List[0].String() {
-		_, err := s.Request.Put(recq.SQ.SurveyID, &libdrynx.QueryInfo{Bitmap: proofsVerified, TotalNbrProofs: sizeQuery, Query: &recq.SQ, SharedBMChannel: make(chan map[string]int64, 100), SharedBMChannelToTerminate: make(chan struct{}, 100), EndVerificationChannel: make(chan skipchain.SkipBlock, 100)})
-		if err != nil {
-			return nil, err
-		}
+	isRoot := s.ServerIdentity().String() == recq.SQ.Query.RosterVNs.List[0].String()
+	if isRoot {
+		_, err = s.Request.Put(recq.SQ.SurveyID, &libdrynx.QueryInfo{Bitmap: proofsVerified, TotalNbrProofs: sizeQuery, Query: &recq.SQ, SharedBMChannel: make(chan map[string]int64, 100), SharedBMChannelToTerminate: make(chan struct{}, 100), EndVerificationChannel: make(chan skipchain.SkipBlock, 100)})
 	} else {
-		_, err := s.Request.Put(recq.SQ.SurveyID, &libdrynx.QueryInfo{Bitmap: proofsVerified, TotalNbrProofs: sizeQuery, Query: &recq.SQ})
-		if err != nil {
-			return nil, err
-		}
+		_, err = s.Request.Put(recq.SQ.SurveyID, &libdrynx.QueryInfo{Bitmap: proofsVerified, TotalNbrProofs: sizeQuery, Query: &recq.SQ})
+	}
+	if err != nil {
+		s.Mutex.Unlock()
+		return nil, err
 	}
 
 	if s.DBPath == "" {
@@ -85,13 +92,34 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 		s.DB = db
 	}
 
+	// keep the query so that the proofs can later be exported and verified offline
+	sqBytes, err := network.Marshal(&recq.SQ)
+	if err != nil {
+		s.Mutex.Unlock()
+		return nil, err
+	}
+	libdrynx.UpdateDB(s.DB, "queries", recq.SQ.SurveyID, sqBytes)
+	// and when it was received, for the retention of the proofs
+	receivedAt, err := time.Now().MarshalBinary()
+	if err != nil {
+		s.Mutex.Unlock()
+		return nil, err
+	}
+	libdrynx.UpdateDB(s.DB, "times", recq.SQ.SurveyID, receivedAt)
+
 	if s.Skipchain == nil {
 		s.Skipchain = skipchain.NewClient()
 	}
 
+	// the block of the survey is appended after the ones of the surveys received before it
+	var appendInTurn func(func()) bool
+	if isRoot {
+		appendInTurn = s.blocks.enqueue()
+	}
+
 	s.Mutex.Unlock()
 
-	if s.ServerIdentity().String() == recq.SQ.Query.RosterVNs.List[0].String() {
+	if isRoot {
 		go func() {
 			// read all bitmaps
 			aggregateBitmap := make(map[string]int64)
@@ -112,49 +140,76 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 
 			//Create the data structure that will be inserted in the block
 			dataBlock := new(libdrynx.DataBlock)
-			dataBlock.Sample = 0.4
+			dataBlock.Sampling = recq.SQ.SamplingRates()
 			dataBlock.SurveyID = recq.SQ.SurveyID
 			dataBlock.Time = time.Now()
 			dataBlock.Proofs = aggregateBitmap
 			dataBlock.ServerNumber = int64(len(recq.SQ.Query.RosterVNs.List))
 			dataBlock.Roster = recq.SQ.Query.RosterVNs
+			proofsRoot, err := s.proofsRoot(recq.SQ.SurveyID)
+			if err != nil {
+				log.Fatal("Error computing the root of the proofs:", err)
+			}
+			dataBlock.ProofsRoot = proofsRoot
+			dataBlock.SamplingSeed = drynxproof.SamplingSeed(recq.SQ.SamplingSeed, proofsRoot)
+
+			agreement := drynxproof.Agree(aggregateBitmap, vnAddresses(recq.SQ.Query.RosterVNs))
+			dataBlock.Agreed = agreement.Verdicts
+			dataBlock.Disagreements = agreement.Disagreements
+			dataBlock.Deviations = agreement.Deviations
+			for vn, nbr := range agreement.Deviations {
+				log.Warn("VN", vn, "disagrees with the other VNs on", nbr, "proofs of survey", recq.SQ.SurveyID)
+			}
 
 			dataBytes, err := network.Marshal(dataBlock)
 			if err != nil {
 				log.Fatal("Error in marshaling proofs data to insert ,", err)
 			}
 
-			// no skipchain yet created
 			var newSB *skipchain.SkipBlock
+			appended := appendInTurn(func() {
+				s.Mutex.Lock()
+				defer s.Mutex.Unlock()
 
-			s.Mutex.Lock()
-			if s.LastSkipBlock == nil {
-				newSB, err = CreateProofSkipchain(s.Skipchain, recq.SQ.Query.RosterVNs, dataBytes)
-				if err != nil || newSB == nil {
-					log.Fatal("Error creating the genesis block:", err)
+				latest, err := s.latestBlock(recq.SQ.Query.RosterVNs)
+				if err != nil {
+					log.Fatal("Error getting the last block of the chain:", err)
 				}
 
-				//Store Genesis in DB
-				genesisBytes, _ := network.Marshal(newSB)
-				libdrynx.UpdateDB(s.DB, "genesis", "genesis", genesisBytes)
-
-				s.LastSkipBlock = newSB
+				// no skipchain yet created: the genesis block holds no survey, so that the block of every survey is
+				// collectively signed by the VNs through the forward link of its predecessor
+				if latest == nil {
+					genesisData, err := network.Marshal(&libdrynx.DataBlock{Roster: recq.SQ.Query.RosterVNs, Time: time.Now(), ServerNumber: int64(len(recq.SQ.Query.RosterVNs.List))})
+					if err != nil {
+						log.Fatal("Error in marshaling the genesis data:", err)
+					}
+					latest, err = CreateProofSkipchain(s.Skipchain, recq.SQ.Query.RosterVNs, genesisData)
+					if err != nil || latest == nil {
+						log.Fatal("Error creating the genesis block:", err)
+					}
+
+					//Store Genesis in DB
+					genesisBytes, _ := network.Marshal(latest)
+					libdrynx.UpdateDB(s.DB, "genesis", "genesis", genesisBytes)
+				}
 
-			} else {
-				newSB, err = AppendProofSkipchain(s.Skipchain, recq.SQ.Query.RosterVNs, dataBytes, s.LastSkipBlock, recq.SQ.SurveyID)
+				newSB, err = AppendProofSkipchain(s.Skipchain, recq.SQ.Query.RosterVNs, dataBytes, latest, recq.SQ.SurveyID)
 				if err != nil || newSB == nil {
 					log.Fatal("Error appending the block to the chain:", err)
 				}
 
 				//Store new block in DB
 				libdrynx.UpdateDB(s.DB, "mapping", recq.SQ.SurveyID, []byte(newSB.Hash))
-
 				s.LastSkipBlock = newSB
-			}
-			s.Mutex.Unlock()
+			})
 
 			libunlynx.EndTimer(startBI)
 
+			// an empty block tells the querier that the block of the survey was dropped
+			if !appended {
+				log.Warn("Block of survey", recq.SQ.SurveyID, "dropped as a survey received after it timed out waiting for it")
+				newSB = &skipchain.SkipBlock{}
+			}
 			protocols.CastToQueryInfo(s.Request.Get(recq.SQ.SurveyID)).EndVerificationChannel <- *newSB
 		}()
 	}
@@ -166,15 +221,29 @@ func (s *ServiceDrynx) HandleSurveyQueryToVN(recq *libdrynx.SurveyQueryToVN) (ne
 func (s *ServiceDrynx) HandleEndVerification(msg *libdrynx.EndVerificationRequest) (network.Message, error) {
 	//block until all verification of the proofs is done (and of course inserted in the skipchain)
 	sb := <-protocols.CastToQueryInfo(s.Request.Get(msg.QueryInfoID)).EndVerificationChannel
+	if sb.Hash == nil {
+		return nil, errors.New("block of survey " + msg.QueryInfoID + " was dropped as it was not appended in turn")
+	}
 	return &libdrynx.Reply{Latest: &sb}, nil
 }
 
+// ErrNoGenesis is the error returned when a genesis block is requested before the VNs created the skipchain
+const ErrNoGenesis = "no genesis block stored yet"
+
 // HandleGetGenesis handles the reception of a genesis block request
 func (s *ServiceDrynx) HandleGetGenesis(request *libdrynx.GetGenesis) (network.Message, error) {
 
+	// the DB is opened with the first survey
+	if s.DB == nil {
+		return nil, errors.New(ErrNoGenesis)
+	}
+
 	genesisBytes := make([]byte, 0)
 	err := s.DB.View(func(tx *bbolt.Tx) error {
 		b := tx.Bucket([]byte("genesis"))
+		if b == nil {
+			return errors.New(ErrNoGenesis)
+		}
 		genesisBytes = b.Get([]byte("genesis"))
 		return nil
 	})
@@ -213,17 +282,16 @@ func (s *ServiceDrynx) HandleGetBlock(request *libdrynx.GetBlock) (network.Messa
 	}
 
 	err := s.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte("genesis"))
-		blockIDbytes := b.Get([]byte(request.ID))
-		if len(blockIDbytes) == 0 {
-			b = tx.Bucket([]byte("mapping"))
-			blockIDbytes = b.Get([]byte(request.ID))
+		b := tx.Bucket([]byte("mapping"))
+		if b == nil {
+			return errors.New("no block stored yet")
 		}
-		blockID = skipchain.SkipBlockID(blockIDbytes)
+		blockID = skipchain.SkipBlockID(b.Get([]byte(request.ID)))
 		return nil
 	})
 	if err != nil {
 		log.Error("Error getting index" + err.Error())
+		return nil, err
 	}
 
 	block, err := s.Skipchain.GetSingleBlock(request.Roster, blockID)
@@ -235,8 +303,8 @@ func (s *ServiceDrynx) HandleGetBlock(request *libdrynx.GetBlock) (network.Messa
 	return &libdrynx.Reply{Latest: block}, nil
 }
 
-//HandleGetProofs handle the request to send back proof for a given query ID
-//It is sent as a key,value (string,[]byte)
+// HandleGetProofs handles the request to send back the proofs of a given query ID matching some filters, with
+// their verdict in the bitmap of the VN. The proofs are sent by pages, ordered by key.
 func (s *ServiceDrynx) HandleGetProofs(request *libdrynx.GetProofs) (network.Message, error) {
 	//Open the DB if it is not open
 	timeGetProof := libunlynx.StartTimer(s.ServerIdentity().String() + "_GetProofs")
@@ -247,76 +315,243 @@ func (s *ServiceDrynx) HandleGetProofs(request *libdrynx.GetProofs) (network.Mes
 		}
 		s.DB = db
 	}
-	//Iterate over each bucket
-	result := make(map[string][]byte)
 
+	page := &libdrynx.ProofsPage{Proofs: make([]libdrynx.StoredProof, 0)}
 	if err := s.DB.View(func(tx *bbolt.Tx) error {
-		b := tx.Bucket([]byte(request.ID + "/range"))
-		if b == nil {
-			log.Info("No bucket - range")
-		} else {
-			c := b.Cursor()
-			//For each key in this bucket (each proofs)
-			for k, v := c.First(); k != nil; k, v = c.Next() {
-				result[string(k)] = v
+		verdicts := make(map[string]int64)
+		if b := tx.Bucket([]byte(s.ServerIdentity().Address)); b != nil {
+			if v := b.Get([]byte(request.ID + "/map")); v != nil {
+				_, msg, err := network.Unmarshal(v, libunlynx.SuiTe)
+				if err != nil {
+					return err
+				}
+				verdicts = msg.(*libdrynx.BitMap).BitMap
 			}
 		}
 
-		//Bucket aggregation
-		b = tx.Bucket([]byte(request.ID + "/aggregation"))
-		if b == nil {
-			log.Info("No bucket - aggregation")
-		} else {
-			c := b.Cursor()
-			//For each key in this bucket (each proofs)
-			for k, v := c.First(); k != nil; k, v = c.Next() {
-				result[string(k)] = v
-			}
+		//The proofs of a pruned survey are all replaced by their hashes
+		if b := tx.Bucket([]byte(request.ID + "/pruned")); b != nil {
+			return collectProofs(b, request, verdicts, true, page)
 		}
 
-		//Bucket obfuscation
-		b = tx.Bucket([]byte(request.ID + "/obfuscation"))
-		if b == nil {
-			log.Info("No bucket - obfuscation")
-		} else {
-			c := b.Cursor()
-			//For each key in this bucket (each proofs)
-			for k, v := c.First(); k != nil; k, v = c.Next() {
-				result[string(k)] = v
+		//Keys start with the type of proof, iterating over the buckets in this order keeps the keys sorted
+		proofTypes := request.Types
+		if len(proofTypes) == 0 {
+			proofTypes = drynxproof.ProofTypes
+		}
+		proofTypes = append([]string{}, proofTypes...)
+		sort.Strings(proofTypes)
+
+		for _, proofType := range proofTypes {
+			b := tx.Bucket([]byte(request.ID + "/" + proofType))
+			if b == nil {
+				log.Lvl3("No bucket -", proofType)
+				continue
+			}
+			if err := collectProofs(b, request, verdicts, false, page); err != nil {
+				return err
+			}
+			if page.Next != "" {
+				break
 			}
 		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
 
-		//Bucket shuffle
-		b = tx.Bucket([]byte(request.ID + "/shuffle"))
-		if b == nil {
-			log.Info("No bucket - shuffle")
+	libunlynx.EndTimer(timeGetProof)
+	return page, nil
+}
+
+// collectProofs adds to the page the proofs of a bucket following the cursor and matching the filters of the request
+func collectProofs(b *bbolt.Bucket, request *libdrynx.GetProofs, verdicts map[string]int64, pruned bool, page *libdrynx.ProofsPage) error {
+	c := b.Cursor()
+	k, v := c.First()
+	if request.Cursor != "" {
+		k, v = c.Seek([]byte(request.Cursor))
+		if k != nil && string(k) == request.Cursor {
+			k, v = c.Next()
+		}
+	}
+
+	for ; k != nil; k, v = c.Next() {
+		key := string(k)
+		proofType := drynxproof.ProofType(request.ID, key)
+		verdict, ok := verdicts[key]
+		if !ok {
+			verdict = -1
+		}
+		if !matchProof(request, key, proofType, verdict) {
+			continue
+		}
+
+		if request.Limit > 0 && int64(len(page.Proofs)) == request.Limit {
+			page.Next = page.Proofs[len(page.Proofs)-1].Key
+			return nil
+		}
+
+		proof := libdrynx.StoredProof{Key: key, Type: proofType, Verdict: verdict}
+		if pruned {
+			proof.PrunedHash = append([]byte{}, v...)
 		} else {
-			c := b.Cursor()
-			//For each key in this bucket (each proofs)
-			for k, v := c.First(); k != nil; k, v = c.Next() {
-				result[string(k)] = v
+			_, msg, err := network.Unmarshal(v, libunlynx.SuiTe)
+			if err != nil {
+				return err
 			}
+			stored := msg.(*drynxproof.ProofToStoreInDB)
+			proof.SenderID = stored.SenderID
+			proof.DifferInfo = stored.DifferInfo
+			proof.Data = stored.Data
+			proof.Signature = stored.Signature
 		}
+		page.Proofs = append(page.Proofs, proof)
+	}
+	return nil
+}
 
-		//Bucket keyswitch
-		b = tx.Bucket([]byte(request.ID + "/keyswitch"))
+func matchProof(request *libdrynx.GetProofs, key, proofType string, verdict int64) bool {
+	if len(request.Types) > 0 {
+		found := false
+		for _, t := range request.Types {
+			found = found || t == proofType
+		}
+		if !found {
+			return false
+		}
+	}
+	if request.Sender != "" && !strings.HasPrefix(key, request.ID+"/"+proofType+"/"+request.Sender+"/") {
+		return false
+	}
+	if len(request.Verdicts) > 0 {
+		found := false
+		for _, v := range request.Verdicts {
+			found = found || v == verdict
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// HandleGetQuery handles the request to send back the survey query stored for a given query ID
+func (s *ServiceDrynx) HandleGetQuery(request *libdrynx.GetQuery) (network.Message, error) {
+	if s.DB == nil {
+		return nil, errors.New("no DB opened at " + s.ServerIdentity().String())
+	}
+
+	var sqBytes []byte
+	if err := s.DB.View(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("queries"))
 		if b == nil {
-			log.Info("No bucket - keyswitch")
-		} else {
-			c := b.Cursor()
-			//For each key in this bucket (each proofs)
-			for k, v := c.First(); k != nil; k, v = c.Next() {
-				result[string(k)] = v
+			return errors.New("no query stored")
+		}
+		v := b.Get([]byte(request.ID))
+		if v == nil {
+			return errors.New("no query stored for survey " + request.ID)
+		}
+		sqBytes = append([]byte{}, v...)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	_, msg, err := network.Unmarshal(sqBytes, libunlynx.SuiTe)
+	if err != nil {
+		return nil, err
+	}
+	return msg.(*libdrynx.SurveyQuery), nil
+}
+
+// HandleGetProofInclusion handles the request to send back the path of a proof in the Merkle tree of its survey
+func (s *ServiceDrynx) HandleGetProofInclusion(request *libdrynx.GetProofInclusion) (network.Message, error) {
+	if s.DB == nil {
+		return nil, errors.New("no DB opened at " + s.ServerIdentity().String())
+	}
+
+	var proofIDs []string
+	var dataHashes, leaves [][]byte
+	if err := s.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		proofIDs, dataHashes, leaves, err = surveyLeaves(tx, request.ID, s.ServerIdentity().Address.String())
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	index := sort.SearchStrings(proofIDs, request.ProofID)
+	if index == len(proofIDs) || proofIDs[index] != request.ProofID {
+		return nil, errors.New("no proof " + request.ProofID + " stored")
+	}
+	return &libdrynx.ProofInclusion{ProofID: request.ProofID, DataHash: dataHashes[index], Path: libdrynxmerkle.Path(leaves, index)}, nil
+}
+
+// HandlePruneDB handles the request to prune the proofs of the surveys older than a given age. Only the hashes of
+// the pruned proofs are kept so that they can still be matched against the verdicts in the skipchain.
+func (s *ServiceDrynx) HandlePruneDB(request *libdrynx.PruneDB) (network.Message, error) {
+	// surveys are registered under the same lock, none can start while the DB is pruned
+	s.Mutex.Lock()
+	defer s.Mutex.Unlock()
+
+	if s.DB == nil {
+		return nil, errors.New("no DB opened at " + s.ServerIdentity().String())
+	}
+	if surveyID := s.surveyInProgress(); surveyID != "" {
+		return nil, errors.New("survey " + surveyID + " is in progress")
+	}
+
+	reply := &libdrynx.PruneDBReply{Surveys: make([]string, 0)}
+	cutoff := time.Now().Add(-request.MaxAge)
+	if err := s.DB.Update(func(tx *bbolt.Tx) error {
+		times := tx.Bucket([]byte("times"))
+		if times == nil {
+			return nil
+		}
+
+		surveyIDs := make([]string, 0)
+		if err := times.ForEach(func(k, v []byte) error {
+			var receivedAt time.Time
+			if err := receivedAt.UnmarshalBinary(v); err != nil {
+				return err
+			}
+			if receivedAt.Before(cutoff) {
+				surveyIDs = append(surveyIDs, string(k))
 			}
+			return nil
+		}); err != nil {
+			return err
 		}
 
+		for _, surveyID := range surveyIDs {
+			nbrPruned, err := pruneSurveyProofs(tx, surveyID)
+			if err != nil {
+				return err
+			}
+			if nbrPruned > 0 {
+				reply.Surveys = append(reply.Surveys, surveyID)
+				reply.Proofs += nbrPruned
+			}
+		}
 		return nil
 	}); err != nil {
 		return nil, err
 	}
+	log.Lvl2("VN", s.ServerIdentity().String(), "pruned", reply.Proofs, "proofs of", len(reply.Surveys), "surveys")
 
-	libunlynx.EndTimer(timeGetProof)
-	return &libdrynx.ProofsAsMap{Proofs: result}, nil
+	if request.Compact {
+		if err := s.compactDB(); err != nil {
+			return nil, err
+		}
+	}
+
+	info, err := os.Stat(s.DBPath)
+	if err != nil {
+		return nil, err
+	}
+	reply.Size = info.Size()
+
+	return reply, nil
 }
 
 // HandleCloseDB handles the request to close database
@@ -361,6 +596,7 @@ func (s *ServiceDrynx) NewProofCollectionProtocolInstance(tn *onet.TreeNodeInsta
 		proofCollection.Skipchain = s.Skipchain
 		proofCollection.Request = s.Request
 		proofCollection.DB = s.DB
+		proofCollection.ProofsRoot = s.proofsRoot
 
 		// if root of the VN
 		if s.ServerIdentity().String() == survey.SurveyQuery.Query.RosterVNs.List[0].String() {
@@ -421,6 +657,32 @@ func (s *ServiceDrynx) verifyFuncBitmap(newID []byte, newSB *skipchain.SkipBlock
 		return nil
 	})
 
+	//Check that the block records the sampling rates the VN used for the query
+	sq := castToSurvey(s.Survey.Get(blockData.SurveyID)).SurveyQuery
+	if blockData.Sampling != sq.SamplingRates() {
+		log.Lvl2("Sampling in the block does not match the query")
+		return false
+	}
+
+	//Check that the block records the agreement of the VNs on its bitmap
+	if !drynxproof.Agree(blockData.Proofs, vnAddresses(blockData.Roster)).Matches(blockData) {
+		log.Lvl2("Agreement in the block does not match its bitmap")
+		return false
+	}
+
+	//Check that the block commits to the proofs stored by the VN
+	root, err := s.proofsRoot(blockData.SurveyID)
+	if err != nil || !bytes.Equal(root, blockData.ProofsRoot) {
+		log.Lvl2("Root of the proofs in the block does not match the stored proofs")
+		return false
+	}
+
+	//Check that the sampling seed was derived once the proofs were committed
+	if !bytes.Equal(blockData.SamplingSeed, drynxproof.SamplingSeed(sq.SamplingSeed, root)) {
+		log.Lvl2("Sampling seed in the block is not derived from the stored proofs")
+		return false
+	}
+
 	//Compare the bitmap you get from DB to all bitmap Stored in Block
 	for i, v := range bitMapFromServ {
 		if bitMap[i] != v {
@@ -437,6 +699,17 @@ func (s *ServiceDrynx) verifyFuncBitmap(newID []byte, newSB *skipchain.SkipBlock
 // Support Functions
 //______________________________________________________________________________________________________________________
 
+func vnAddresses(rosterVNs *onet.Roster) []string {
+	addresses := make([]string, 0)
+	if rosterVNs == nil {
+		return addresses
+	}
+	for _, vn := range rosterVNs.List {
+		addresses = append(addresses, vn.Address.String())
+	}
+	return addresses
+}
+
 func generateProofCollectionRoster(root *network.ServerIdentity, rosterVNs *onet.Roster) *onet.Roster {
 	roster := make([]*network.ServerIdentity, 0)
 	roster = append(roster, root)
@@ -494,6 +767,276 @@ func OpenDB(path string) (*bbolt.DB, error) {
 	return db, nil
 }
 
+// proofsRoot computes the root of the Merkle tree over the proofs of a survey stored by the VN
+func (s *ServiceDrynx) proofsRoot(surveyID string) ([]byte, error) {
+	var leaves [][]byte
+	err := s.DB.View(func(tx *bbolt.Tx) error {
+		var err error
+		_, _, leaves, err = surveyLeaves(tx, surveyID, s.ServerIdentity().Address.String())
+		return err
+	})
+	return libdrynxmerkle.Root(leaves), err
+}
+
+// surveyLeaves returns the IDs, the hashes of the data and the Merkle leaves of the proofs of a survey stored by a VN,
+// sorted by ID. The hashes of pruned proofs are used, so that the tree does not change once the proofs are pruned.
+func surveyLeaves(tx *bbolt.Tx, surveyID, vnAddress string) ([]string, [][]byte, [][]byte, error) {
+	dataHashes := make(map[string][]byte)
+	if b := tx.Bucket([]byte(surveyID + "/pruned")); b != nil {
+		if err := b.ForEach(func(k, v []byte) error {
+			dataHashes[strings.TrimSuffix(string(k), "/"+vnAddress)] = append([]byte{}, v...)
+			return nil
+		}); err != nil {
+			return nil, nil, nil, err
+		}
+	} else {
+		for _, proofType := range drynxproof.ProofTypes {
+			b := tx.Bucket([]byte(surveyID + "/" + proofType))
+			if b == nil {
+				continue
+			}
+			if err := b.ForEach(func(k, v []byte) error {
+				_, msg, err := network.Unmarshal(v, libunlynx.SuiTe)
+				if err != nil {
+					return err
+				}
+				stored := msg.(*drynxproof.ProofToStoreInDB)
+				hash := sha256.Sum256(stored.Data)
+				dataHashes[drynxproof.ProofID(surveyID, proofType, stored.SenderID, stored.DifferInfo)] = hash[:]
+				return nil
+			}); err != nil {
+				return nil, nil, nil, err
+			}
+		}
+	}
+
+	proofIDs := make([]string, 0, len(dataHashes))
+	for proofID := range dataHashes {
+		proofIDs = append(proofIDs, proofID)
+	}
+	sort.Strings(proofIDs)
+
+	hashes := make([][]byte, len(proofIDs))
+	leaves := make([][]byte, len(proofIDs))
+	for i, proofID := range proofIDs {
+		hashes[i] = dataHashes[proofID]
+		leaves[i] = libdrynxmerkle.Leaf(proofID, hashes[i])
+	}
+	return proofIDs, hashes, leaves, nil
+}
+
+// surveyInProgress returns the ID of a survey whose proofs are still expected, if any
+func (s *ServiceDrynx) surveyInProgress() string {
+	for _, entry := range s.Request.ToSlice() {
+		if surveyRunning(entry.Value().(*libdrynx.QueryInfo)) {
+			return entry.Key().(string)
+		}
+	}
+	return ""
+}
+
+// surveyRunning tells whether proofs of a survey are still expected
+func surveyRunning(queryInfo *libdrynx.QueryInfo) bool {
+	for _, remaining := range queryInfo.TotalNbrProofs {
+		if remaining > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// latestBlock returns the last block of the skipchain of the VNs, or nil if there is none yet. The chain is looked
+// up from the genesis block stored in the DB if this VN did not append a block since it started.
+func (s *ServiceDrynx) latestBlock(rosterVNs *onet.Roster) (*skipchain.SkipBlock, error) {
+	if s.LastSkipBlock != nil {
+		return s.LastSkipBlock, nil
+	}
+
+	var genesisBytes []byte
+	if err := s.DB.View(func(tx *bbolt.Tx) error {
+		if b := tx.Bucket([]byte("genesis")); b != nil {
+			genesisBytes = b.Get([]byte("genesis"))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if genesisBytes == nil {
+		return nil, nil
+	}
+
+	_, msg, err := network.Unmarshal(genesisBytes, libunlynx.SuiTe)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := s.Skipchain.GetUpdateChain(rosterVNs, msg.(*skipchain.SkipBlock).Hash)
+	if err != nil {
+		return nil, err
+	}
+	return chain.Update[len(chain.Update)-1], nil
+}
+
+// blockQueueTimeout is how long the block of a survey waits for the block of the survey received before it
+const blockQueueTimeout = 5 * time.Minute
+
+// blockQueue orders the blocks of concurrent surveys: they are appended to the skipchain of the VNs one after the
+// other, in the order in which the surveys were received by the VN. Each survey gets its position when it is received:
+// a block whose turn has passed, because a survey after it stopped waiting for it, is dropped instead of being appended
+// out of order.
+type blockQueue struct {
+	mutex   sync.Mutex
+	last    chan struct{} // closed once the block of the last survey in the queue is appended or dropped
+	next    int64         // position of the next survey received
+	turn    int64         // lowest position whose block can still be appended
+	timeout time.Duration // blockQueueTimeout if zero
+
+	appendMutex sync.Mutex // appends run one at a time, in the order in which they passed their turn
+}
+
+// enqueue takes the next place in the queue. The returned function runs an append once all the appends before it
+// are done or blockQueueTimeout has passed, so that a survey that never ends does not block the ones after it. It
+// tells whether the block was appended: the blocks before one that stopped waiting are dropped.
+func (q *blockQueue) enqueue() func(func()) bool {
+	q.mutex.Lock()
+	previous := q.last
+	done := make(chan struct{})
+	q.last = done
+	position := q.next
+	q.next++
+	timeout := q.timeout
+	if timeout == 0 {
+		timeout = blockQueueTimeout
+	}
+	q.mutex.Unlock()
+
+	return func(appendBlock func()) bool {
+		defer close(done)
+		if previous != nil {
+			select {
+			case <-previous:
+			case <-time.After(timeout):
+				log.Warn("Timeout waiting for the block of a previous survey")
+			}
+		}
+
+		q.appendMutex.Lock()
+		defer q.appendMutex.Unlock()
+
+		q.mutex.Lock()
+		if position < q.turn {
+			q.mutex.Unlock()
+			return false
+		}
+		q.turn = position + 1
+		q.mutex.Unlock()
+
+		appendBlock()
+		return true
+	}
+}
+
+// pruneSurveyProofs replaces the proofs of a survey by the hashes of their data
+func pruneSurveyProofs(tx *bbolt.Tx, surveyID string) (int64, error) {
+	nbrPruned := int64(0)
+	for _, proofType := range drynxproof.ProofTypes {
+		b := tx.Bucket([]byte(surveyID + "/" + proofType))
+		if b == nil {
+			continue
+		}
+
+		hashes, err := tx.CreateBucketIfNotExists([]byte(surveyID + "/pruned"))
+		if err != nil {
+			return 0, err
+		}
+		if err := b.ForEach(func(k, v []byte) error {
+			_, msg, err := network.Unmarshal(v, libunlynx.SuiTe)
+			if err != nil {
+				return err
+			}
+			hash := sha256.Sum256(msg.(*drynxproof.ProofToStoreInDB).Data)
+			nbrPruned++
+			return hashes.Put(k, hash[:])
+		}); err != nil {
+			return 0, err
+		}
+
+		if err := tx.DeleteBucket([]byte(surveyID + "/" + proofType)); err != nil {
+			return 0, err
+		}
+	}
+	return nbrPruned, nil
+}
+
+// compactDB rewrites the DB in a new file, as bbolt never shrinks its file when data is deleted. If the new file cannot
+// replace the DB, the original one is kept and reopened.
+func (s *ServiceDrynx) compactDB() error {
+	compactPath := s.DBPath + ".compact"
+	compacted, err := OpenDB(compactPath)
+	if err != nil {
+		return err
+	}
+
+	if err := s.DB.View(func(src *bbolt.Tx) error {
+		return compacted.Update(func(dst *bbolt.Tx) error {
+			return src.ForEach(func(name []byte, b *bbolt.Bucket) error {
+				copied, err := dst.CreateBucket(name)
+				if err != nil {
+					return err
+				}
+				return b.ForEach(func(k, v []byte) error {
+					return copied.Put(k, v)
+				})
+			})
+		})
+	}); err != nil {
+		compacted.Close()
+		os.Remove(compactPath)
+		return err
+	}
+
+	if err := compacted.Close(); err != nil {
+		os.Remove(compactPath)
+		return err
+	}
+
+	// reopen puts back the original DB after an error
+	backupPath := s.DBPath + ".backup"
+	backedUp := false
+	reopen := func(err error) error {
+		os.Remove(compactPath)
+		if backedUp {
+			if errRename := os.Rename(backupPath, s.DBPath); errRename != nil {
+				return errRename
+			}
+		}
+		// a DB that failed to close may still hold its lock
+		db, errOpen := bbolt.Open(s.DBPath, 0600, &bbolt.Options{Timeout: time.Second})
+		if errOpen != nil {
+			return errOpen
+		}
+		s.DB = db
+		return err
+	}
+
+	if err := s.DB.Close(); err != nil {
+		return reopen(err)
+	}
+	s.DB = nil
+	if err := os.Rename(s.DBPath, backupPath); err != nil {
+		return reopen(err)
+	}
+	backedUp = true
+	if err := os.Rename(compactPath, s.DBPath); err != nil {
+		return reopen(err)
+	}
+	db, err := OpenDB(s.DBPath)
+	if err != nil {
+		return reopen(err)
+	}
+	s.DB = db
+	return os.Remove(backupPath)
+}
+
 // CreateProofSkipchain creates the skipchain
 func CreateProofSkipchain(sk *skipchain.Client, roster *onet.Roster, dataBytes []byte) (*skipchain.SkipBlock, error) {
 	timeGenesis := libunlynx.StartTimer("Genesis")
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBlockQueue tests that the blocks are appended in the order in which the surveys were received, and that a block
+// whose turn has passed is dropped
+func TestBlockQueue(t *testing.T) {
+	q := blockQueue{timeout: 100 * time.Millisecond}
+	first := q.enqueue()
+	second := q.enqueue()
+	third := q.enqueue()
+
+	order := make(chan string, 3)
+	appended := make(chan bool, 3)
+	go func() { appended <- third(func() { order <- "third" }) }()
+	go func() { appended <- second(func() { order <- "second" }) }()
+	time.Sleep(10 * time.Millisecond)
+	assert.True(t, first(func() { order <- "first" }))
+
+	assert.True(t, <-appended)
+	assert.True(t, <-appended)
+	assert.Equal(t, "first", <-order)
+	assert.Equal(t, "second", <-order)
+	assert.Equal(t, "third", <-order)
+
+	// the next survey stops waiting for a survey that does not end, whose block is then dropped
+	late := q.enqueue()
+	next := q.enqueue()
+	assert.True(t, next(func() { order <- "next" }))
+	assert.Equal(t, "next", <-order)
+	assert.False(t, late(func() { order <- "late" }))
+	assert.Empty(t, order)
+}
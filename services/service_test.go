@@ -1,12 +1,16 @@
 package services_test
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"io/ioutil"
 	"math"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -21,6 +25,7 @@ import (
 
 	"github.com/ldsec/drynx/lib"
 	"github.com/ldsec/drynx/lib/encoding"
+	"github.com/ldsec/drynx/lib/proof"
 	"github.com/ldsec/drynx/lib/range"
 	"github.com/ldsec/drynx/services"
 	"github.com/ldsec/unlynx/lib"
@@ -65,6 +70,63 @@ func repartitionDPs(elServers *onet.Roster, elDPs *onet.Roster, dpRepartition []
 	return dpToServers
 }
 
+// testNodes are the nodes of a test: the CNs, the DPs split evenly among them and the VNs
+type testNodes struct {
+	servers, dps, vns *onet.Roster
+	dpToServers       map[string]*[]network.ServerIdentity
+	idToPublic        map[string]kyber.Point
+}
+
+// newTestNodes generates the nodes of a test
+func newTestNodes(local *onet.LocalTest, nbrServers int, nbrDPs int, nbrVNs int) testNodes {
+	elServers, elDPs, elVNs := generateNodes(local, nbrServers, nbrDPs, nbrVNs)
+	repartition := make([]int64, nbrServers)
+	for i := 0; i < nbrDPs; i++ {
+		repartition[i%nbrServers]++
+	}
+
+	rosters := []*onet.Roster{elServers, elDPs}
+	if nbrVNs > 0 {
+		rosters = append(rosters, elVNs)
+	}
+	idToPublic := make(map[string]kyber.Point)
+	for _, roster := range rosters {
+		for _, v := range roster.List {
+			idToPublic[v.String()] = v.ServicePublic(services.ServiceName)
+		}
+	}
+	return testNodes{servers: elServers, dps: elDPs, vns: elVNs, dpToServers: repartitionDPs(elServers, elDPs, repartition), idToPublic: idToPublic}
+}
+
+// surveyQuery generates the survey query of an operation, with proofs verified by the VNs if there are ranges
+func (tn testNodes) surveyQuery(client *services.API, surveyID string, operation libdrynx.Operation, ranges []*[]int64, dpData libdrynx.QueryDPDataGen) libdrynx.SurveyQuery {
+	if ranges == nil {
+		return client.GenerateSurveyQuery(tn.servers, nil, tn.dpToServers, tn.idToPublic, surveyID, operation, nil, nil, 0, false, []float64{0, 0, 0, 0, 0}, libdrynx.QueryDiffP{}, dpData, 0)
+	}
+	ps := libdrynxrange.InitRangeProofSignatures(len(tn.servers.List), ranges)
+	return client.GenerateSurveyQuery(tn.servers, tn.vns, tn.dpToServers, tn.idToPublic, surveyID, operation, ranges, ps, 1, false, []float64{1.0, 1.0, 1.0, 0.0, 1.0}, libdrynx.QueryDiffP{}, dpData, 0)
+}
+
+// proofsQuery generates the survey query of a sum with range proofs (one DP output in [0, 16^16)) to be verified by
+// the VNs
+func (tn testNodes) proofsQuery(client *services.API, surveyID string) libdrynx.SurveyQuery {
+	operation := libdrynx.ChooseOperation("sum", 3, 4, 5, 0)
+	ranges := make([]*[]int64, operation.NbrOutput)
+	for i := range ranges {
+		ranges[i] = &[]int64{16, 16}
+	}
+	return tn.surveyQuery(client, surveyID, operation, ranges, libdrynx.QueryDPDataGen{GroupByValues: []int64{1}, GenerateRows: 1, GenerateDataMin: 3, GenerateDataMax: 4})
+}
+
+// bitRanges gives the ranges of n bits
+func bitRanges(n int) []*[]int64 {
+	ranges := make([]*[]int64, n)
+	for i := range ranges {
+		ranges[i] = &[]int64{2, 1}
+	}
+	return ranges
+}
+
 //______________________________________________________________________________________________________________________
 /// Test service Drynx for all operations
 func TestServiceDrynx(t *testing.T) {
@@ -302,7 +364,8 @@ func TestServiceDrynx(t *testing.T) {
 			if err != nil {
 				t.Fatal("Something wrong when fetching genesis block")
 			}
-			assert.Equal(t, sb.Data, listBlocks[0].Data)
+			// the genesis block holds no survey, it precedes the block of the first one
+			assert.True(t, sb.Hash.Equal(listBlocks[0].BackLinkIDs[0]))
 
 			sb, err = clientSkip.SendGetLatestBlock(elVNs, listBlocks[0])
 			if err != nil {
@@ -333,13 +396,13 @@ func TestServiceDrynx(t *testing.T) {
 			}
 			assert.Equal(t, sb.Data, listBlocks[1].Data)
 
-			res, err := clientSkip.SendGetProofs(elVNs.List[0], "query-mean")
+			res, err := clientSkip.SendGetProofs(elVNs.List[0], &libdrynx.GetProofs{ID: "query-mean"})
 			if err != nil {
 				t.Fatal("Something wrong when fetching the 'query-mean' form the DB", err)
 			}
 
-			// just check if map is not empty
-			assert.NotEmpty(t, res)
+			// just check if there are proofs
+			assert.NotEmpty(t, res.Proofs)
 		}
 
 		// close DB
@@ -418,6 +481,7 @@ func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 	lrParameters.NbrFeatures = int64(len(XTrain[0]))
 	lrParameters.Means = means
 	lrParameters.StandardDeviations = standardDeviations
+	lrParameters.LocalStandardisation = standardisationMode == 2
 
 	meanAccuracy := 0.0
 	meanPrecision := 0.0
@@ -671,7 +735,8 @@ func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 			if err != nil {
 				t.Fatal("Something wrong when fetching genesis block")
 			}
-			assert.Equal(t, sb.Data, listBlocks[0].Data)
+			// the genesis block holds no survey, it precedes the block of the first one
+			assert.True(t, sb.Hash.Equal(listBlocks[0].BackLinkIDs[0]))
 
 			sb, err = clientSkip.SendGetLatestBlock(elVNs, listBlocks[0])
 			if err != nil {
@@ -702,13 +767,13 @@ func TestServiceDrynxLogisticRegressionForSPECTF(t *testing.T) {
 			}
 			assert.Equal(t, sb.Data, listBlocks[1].Data)
 
-			res, err := clientSkip.SendGetProofs(elVNs.List[0], "query-mean")
+			res, err := clientSkip.SendGetProofs(elVNs.List[0], &libdrynx.GetProofs{ID: "query-mean"})
 			if err != nil {
 				t.Fatal("Something wrong when fetching the 'query-mean' form the DB", err)
 			}
 
-			// just check if map is not empty
-			assert.NotEmpty(t, res)
+			// just check if there are proofs
+			assert.NotEmpty(t, res.Proofs)
 		}
 
 		// close DB
@@ -875,6 +940,7 @@ func TestServiceDrynxLogisticRegression(t *testing.T) {
 		lrParameters.NbrFeatures = int64(len(XTrain[0]))
 		lrParameters.Means = means
 		lrParameters.StandardDeviations = standardDeviations
+		lrParameters.LocalStandardisation = standardisationMode == 2
 
 		operation := libdrynx.Operation{NameOp: "logistic regression", LRParameters: lrParameters}
 
@@ -1159,6 +1225,7 @@ func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 	lrParameters.NbrFeatures = int64(len(XTrain[0]))
 	lrParameters.Means = means
 	lrParameters.StandardDeviations = standardDeviations
+	lrParameters.LocalStandardisation = standardisationMode == 2
 
 	meanAccuracy := 0.0
 	meanPrecision := 0.0
@@ -1412,7 +1479,8 @@ func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 			if err != nil {
 				t.Fatal("Something wrong when fetching genesis block")
 			}
-			assert.Equal(t, sb.Data, listBlocks[0].Data)
+			// the genesis block holds no survey, it precedes the block of the first one
+			assert.True(t, sb.Hash.Equal(listBlocks[0].BackLinkIDs[0]))
 
 			sb, err = clientSkip.SendGetLatestBlock(elVNs, listBlocks[0])
 			if err != nil {
@@ -1443,13 +1511,13 @@ func TestServiceDrynxLogisticRegressionV2(t *testing.T) {
 			}
 			assert.Equal(t, sb.Data, listBlocks[1].Data)
 
-			res, err := clientSkip.SendGetProofs(elVNs.List[0], "query-mean")
+			res, err := clientSkip.SendGetProofs(elVNs.List[0], &libdrynx.GetProofs{ID: "query-mean"})
 			if err != nil {
 				t.Fatal("Something wrong when fetching the 'query-mean' form the DB", err)
 			}
 
-			// just check if map is not empty
-			assert.NotEmpty(t, res)
+			// just check if there are proofs
+			assert.NotEmpty(t, res.Proofs)
 		}
 
 		// close DB
@@ -1545,6 +1613,7 @@ func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 	lrParameters.NbrFeatures = int64(len(XTrain[0]))
 	lrParameters.Means = means
 	lrParameters.StandardDeviations = standardDeviations
+	lrParameters.LocalStandardisation = standardisationMode == 2
 
 	meanAccuracy := 0.0
 	meanPrecision := 0.0
@@ -1793,7 +1862,8 @@ func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 			if err != nil {
 				t.Fatal("Something wrong when fetching genesis block")
 			}
-			assert.Equal(t, sb.Data, listBlocks[0].Data)
+			// the genesis block holds no survey, it precedes the block of the first one
+			assert.True(t, sb.Hash.Equal(listBlocks[0].BackLinkIDs[0]))
 
 			sb, err = clientSkip.SendGetLatestBlock(elVNs, listBlocks[0])
 			if err != nil {
@@ -1824,13 +1894,13 @@ func TestServiceDrynxLogisticRegressionBC(t *testing.T) {
 			}
 			assert.Equal(t, sb.Data, listBlocks[1].Data)
 
-			res, err := clientSkip.SendGetProofs(elVNs.List[0], "query-mean")
+			res, err := clientSkip.SendGetProofs(elVNs.List[0], &libdrynx.GetProofs{ID: "query-mean"})
 			if err != nil {
 				t.Fatal("Something wrong when fetching the 'query-mean' form the DB", err)
 			}
 
-			// just check if map is not empty
-			assert.NotEmpty(t, res)
+			// just check if there are proofs
+			assert.NotEmpty(t, res.Proofs)
 		}
 
 		// close DB
@@ -1950,6 +2020,7 @@ func TestServiceDrynxLogisticRegressionGSE(t *testing.T) {
 	lrParameters.NbrFeatures = int64(len(XTrain[0]))
 	lrParameters.Means = means
 	lrParameters.StandardDeviations = standardDeviations
+	lrParameters.LocalStandardisation = standardisationMode == 2
 
 	meanAccuracy := 0.0
 	meanPrecision := 0.0
@@ -2202,7 +2273,8 @@ func TestServiceDrynxLogisticRegressionGSE(t *testing.T) {
 			if err != nil {
 				t.Fatal("Something wrong when fetching genesis block")
 			}
-			assert.Equal(t, sb.Data, listBlocks[0].Data)
+			// the genesis block holds no survey, it precedes the block of the first one
+			assert.True(t, sb.Hash.Equal(listBlocks[0].BackLinkIDs[0]))
 
 			sb, err = clientSkip.SendGetLatestBlock(elVNs, listBlocks[0])
 			if err != nil {
@@ -2233,16 +2305,1173 @@ func TestServiceDrynxLogisticRegressionGSE(t *testing.T) {
 			}
 			assert.Equal(t, sb.Data, listBlocks[1].Data)
 
-			res, err := clientSkip.SendGetProofs(elVNs.List[0], "query-mean")
+			res, err := clientSkip.SendGetProofs(elVNs.List[0], &libdrynx.GetProofs{ID: "query-mean"})
 			if err != nil {
 				t.Fatal("Something wrong when fetching the 'query-mean' form the DB", err)
 			}
 
-			// just check if map is not empty
-			assert.NotEmpty(t, res)
+			// just check if there are proofs
+			assert.NotEmpty(t, res.Proofs)
 		}
 
 		// close DB
 		clientSkip.SendCloseDB(elVNs, &libdrynx.CloseDB{Close: 1})
 	}
 }
+
+// TestServiceDrynxOneComputingNode tests the surveys of a single CN, whose key switching has no children to wait for
+// before it uses its own contribution, with enough outputs for the contribution to take some time
+func TestServiceDrynxOneComputingNode(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 1, 2, 0)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-one-CN")
+
+	// every DP has 3 records with a value of 2
+	operation := libdrynx.ChooseOperation("frequencyCount", 0, 199, 0, 0)
+	dpData := libdrynx.QueryDPDataGen{GroupByValues: []int64{1}, GenerateRows: 3, GenerateDataMin: 2, GenerateDataMax: 3}
+
+	for i := 0; i < 5; i++ {
+		sq := nodes.surveyQuery(client, fmt.Sprintf("query-one-CN-%d", i), operation, nil, dpData)
+		_, aggr, err := client.SendSurveyQuery(sq)
+		require.NoError(t, err)
+		expected := make([]float64, 200)
+		expected[2] = 6
+		assert.Equal(t, expected, (*aggr)[0])
+	}
+}
+
+// TestServiceDrynxVerifiedResult tests that the querier only gets the result once the proofs are verified in the skipchain
+func TestServiceDrynxVerifiedResult(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 3, 3, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-verified")
+	clientSkip := services.NewDrynxClient(nodes.vns.List[0], "test-skip-verified")
+
+	for _, surveyID := range []string{"query-verified-genesis", "query-verified"} {
+		sq := nodes.proofsQuery(client, surveyID)
+		require.True(t, libdrynx.CheckParameters(sq, false))
+		require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+		grp, aggr, err := client.SendSurveyQueryVerified(sq)
+		require.NoError(t, err)
+		assert.Equal(t, len(*grp), len(*aggr))
+	}
+
+	// the block of the first survey is signed through the forward link of the genesis block, which holds no survey
+	sb, err := clientSkip.SendGetBlock(nodes.vns, "query-verified-genesis")
+	require.NoError(t, err)
+	require.Equal(t, 1, sb.Index)
+	require.NoError(t, services.VerifyProofBlock(nodes.vns, sb))
+	genesis, err := clientSkip.SendGetGenesis(nodes.vns.List[0])
+	require.NoError(t, err)
+	assert.Error(t, services.VerifyProofBlock(nodes.vns, genesis))
+
+	// the block is only accepted for the trusted VNs
+	assert.Error(t, services.VerifyProofBlock(onet.NewRoster(append([]*network.ServerIdentity{nodes.vns.List[1], nodes.vns.List[0]}, nodes.vns.List[2:]...)), sb))
+
+	require.NoError(t, clientSkip.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxAuditChain tests the walk and verification of the skipchain of the VNs
+func TestServiceDrynxAuditChain(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 2, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-audit")
+	clientSkip := services.NewDrynxClient(nodes.vns.List[0], "test-skip-audit")
+
+	surveyIDs := []string{"query-audit-0", "query-audit-1", "query-audit-2"}
+	for _, surveyID := range surveyIDs {
+		sq := nodes.proofsQuery(client, surveyID)
+		require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sq))
+		_, _, err := client.SendSurveyQueryVerified(sq)
+		require.NoError(t, err)
+	}
+
+	blocks, err := clientSkip.SendGetChain(nodes.vns)
+	require.NoError(t, err)
+	require.Len(t, blocks, len(surveyIDs)+1)
+
+	audits := services.AuditChain(blocks)
+	assert.Empty(t, audits[0].Errors)
+	assert.Empty(t, audits[0].SurveyID)
+	audits = audits[1:]
+	for i, audit := range audits {
+		assert.Empty(t, audit.Errors)
+		assert.Equal(t, surveyIDs[i], audit.SurveyID)
+		assert.Len(t, audit.Roster, len(nodes.vns.List))
+		assert.Equal(t, len(nodes.dps.List)*len(nodes.vns.List), audit.Verdicts["range"]["verified"])
+		// honest VNs all agree
+		assert.Empty(t, audit.Disagreements)
+		for _, deviations := range audit.Deviations {
+			assert.Zero(t, deviations)
+		}
+
+		dataBlock, err := services.DecodeDataBlock(blocks[i+1])
+		require.NoError(t, err)
+		assert.Len(t, dataBlock.Agreed, len(dataBlock.Proofs)/len(nodes.vns.List))
+		for _, verdict := range dataBlock.Agreed {
+			assert.Equal(t, drynxproof.ProofTrue, verdict)
+		}
+	}
+	assert.Empty(t, services.FlagDeviatingVNs(audits))
+
+	// a tampered block is detected
+	blocks[2].Data = blocks[1].Data
+	audits = services.AuditChain(blocks)
+	assert.Empty(t, audits[1].Errors)
+	assert.NotEmpty(t, audits[2].Errors)
+
+	require.NoError(t, clientSkip.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxExportProofs tests the export of the proofs of a survey and their offline re-verification
+func TestServiceDrynxExportProofs(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 2, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-export")
+	clientSkip := services.NewDrynxClient(nodes.vns.List[0], "test-skip-export")
+
+	surveyIDs := []string{"query-export-genesis", "query-export"}
+	for _, surveyID := range surveyIDs {
+		sq := nodes.proofsQuery(client, surveyID)
+		require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sq))
+		_, _, err := client.SendSurveyQueryVerified(sq)
+		require.NoError(t, err)
+	}
+
+	// the genesis block is the trusted anchor, obtained from the VNs beforehand
+	genesis, err := clientSkip.SendGetGenesis(nodes.vns.List[0])
+	require.NoError(t, err)
+
+	for _, surveyID := range surveyIDs {
+		export, err := clientSkip.ExportProofs(nodes.vns.List[1], surveyID)
+		require.NoError(t, err)
+		require.NotEmpty(t, export.Proofs)
+
+		bundle, err := services.EncodeProofsExport(export)
+		require.NoError(t, err)
+		export, err = services.DecodeProofsExport(bundle)
+		require.NoError(t, err)
+
+		report := services.VerifyProofsExport(export, genesis.Hash)
+		assert.Equal(t, surveyID, report.SurveyID)
+		assert.Empty(t, report.Errors)
+		assert.Len(t, report.Proofs, len(export.Proofs))
+		assert.Equal(t, 0, report.Failed())
+	}
+
+	// a tampered proof is detected
+	export, err := clientSkip.ExportProofs(nodes.vns.List[0], "query-export")
+	require.NoError(t, err)
+	export.Proofs[0].Signature[0] ^= 0xff
+	report := services.VerifyProofsExport(export, genesis.Hash)
+	assert.Empty(t, report.Errors)
+	assert.Equal(t, 1, report.Failed())
+	assert.Equal(t, "bad-signature", report.Proofs[0].Verified)
+
+	// the block must be in the chain of the trusted genesis block
+	assert.NotEmpty(t, services.VerifyProofsExport(export, export.Previous.Hash).Errors)
+	assert.NotEmpty(t, services.VerifyProofsExport(export, nil).Errors)
+
+	// the roster of the query in the bundle is not trusted: forging it does not change the keys checked
+	export.Query.Query.RosterVNs = nodes.servers
+	assert.Empty(t, services.VerifyProofsExport(export, genesis.Hash).Errors)
+
+	// a genesis block with a forged roster is not the trusted one
+	forged := *export.Genesis
+	forged.Roster = nodes.servers
+	export.Genesis = &forged
+	assert.NotEmpty(t, services.VerifyProofsExport(export, genesis.Hash).Errors)
+	export.Genesis = genesis
+
+	// so is a block that was not signed by the VNs
+	export.Previous.ForwardLink = nil
+	assert.NotEmpty(t, services.VerifyProofsExport(export, genesis.Hash).Errors)
+
+	require.NoError(t, clientSkip.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxSampling tests that the VNs sample the proofs reproducibly and record the sampling in the block
+func TestServiceDrynxSampling(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-sampling")
+	clientSkip := services.NewDrynxClient(nodes.vns.List[0], "test-skip-sampling")
+
+	sampling := libdrynx.ProofsSampling{Range: 0.5, Shuffle: 1, Aggregation: 1, Obfuscation: 1, KeySwitch: 0}
+	var previous skipchain.SkipBlockID
+	for _, surveyID := range []string{"query-sampling-genesis", "query-sampling"} {
+		sq := nodes.proofsQuery(client, surveyID)
+		sq.Sampling = &sampling
+		require.True(t, libdrynx.CheckParameters(sq, false))
+		require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sq))
+		// the seed of the query is the hash of the latest block
+		assert.Equal(t, previous, skipchain.SkipBlockID(sq.SamplingSeed))
+
+		_, _, err := client.SendSurveyQuery(sq)
+		require.NoError(t, err)
+		sb, err := clientSkip.SendEndVerification(nodes.vns.List[0], surveyID)
+		require.NoError(t, err)
+		previous = sb.Hash
+
+		dataBlock, err := services.DecodeDataBlock(sb)
+		require.NoError(t, err)
+		assert.Equal(t, sampling, dataBlock.Sampling)
+		// the sampling seed is only known once the proofs are committed
+		assert.Equal(t, drynxproof.SamplingSeed(sq.SamplingSeed, dataBlock.ProofsRoot), dataBlock.SamplingSeed)
+		assert.NotEqual(t, sq.SamplingSeed, dataBlock.SamplingSeed)
+
+		// anyone can reproduce which proofs were checked
+		for key, verdict := range dataBlock.Proofs {
+			proofType := drynxproof.ProofType(surveyID, key)
+			if drynxproof.Sampled(dataBlock.SamplingSeed, key, dataBlock.Sampling.Rate(proofType)) {
+				assert.Equal(t, drynxproof.ProofTrue, verdict, key)
+			} else {
+				assert.Equal(t, "unchecked", drynxproof.VerdictName(verdict), key)
+			}
+		}
+	}
+
+	// the proofs that were not drawn do not make a verified survey fail
+	sq := nodes.proofsQuery(client, "query-sampling-verified")
+	sq.Sampling = &sampling
+	require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sq))
+	_, aggr, err := client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+	assert.Equal(t, float64(3*len(nodes.dps.List)), (*aggr)[0][0])
+
+	// but a drawn proof that is wrong does: the values of the DPs are not in [0, 2), and with this rate every range
+	// proof is almost surely drawn by one of the VNs
+	corrupted := sampling
+	corrupted.Range = 0.9
+	sq = nodes.proofsQuery(client, "query-sampling-corrupted")
+	sq.Sampling = &corrupted
+	sq.Query.Ranges = []*[]int64{{2, 1}}
+	sq.Query.IVSigs.InputValidationSigs = libdrynxrange.InitRangeProofSignatures(len(nodes.servers.List), sq.Query.Ranges)
+	require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sq))
+	_, _, err = client.SendSurveyQueryVerified(sq)
+	require.Error(t, err)
+	verificationErr, ok := err.(*services.ProofVerificationError)
+	require.True(t, ok)
+	require.Len(t, verificationErr.Failures, 1)
+	assert.Equal(t, "range", verificationErr.Failures[0].Type)
+	assert.NotEmpty(t, verificationErr.Failures[0].Rejected)
+
+	require.NoError(t, clientSkip.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxPruneDB tests the pruning of old proofs from the DB of the VNs
+func TestServiceDrynxPruneDB(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 2, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-prune")
+	clientSkip := services.NewDrynxClient(nodes.vns.List[0], "test-skip-prune")
+
+	var between time.Time
+	stored := make(map[string][]libdrynx.StoredProof)
+	for i, surveyID := range []string{"query-prune-old", "query-prune-new"} {
+		if i == 1 {
+			between = time.Now()
+		}
+		sq := nodes.proofsQuery(client, surveyID)
+		require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sq))
+		_, _, err := client.SendSurveyQueryVerified(sq)
+		require.NoError(t, err)
+
+		proofs, err := clientSkip.SendGetAllProofs(nodes.vns.List[0], &libdrynx.GetProofs{ID: surveyID})
+		require.NoError(t, err)
+		require.NotEmpty(t, proofs)
+		stored[surveyID] = proofs
+	}
+
+	// only the first survey is old enough
+	replies, err := clientSkip.SendPruneDB(nodes.vns, &libdrynx.PruneDB{MaxAge: time.Since(between)})
+	require.NoError(t, err)
+	require.Len(t, replies, len(nodes.vns.List))
+	assert.Equal(t, []string{"query-prune-old"}, replies[0].Surveys)
+	assert.Equal(t, int64(len(stored["query-prune-old"])), replies[0].Proofs)
+
+	// only the hashes of the data of the proofs are left, with their verdicts
+	pruned, err := clientSkip.SendGetAllProofs(nodes.vns.List[0], &libdrynx.GetProofs{ID: "query-prune-old"})
+	require.NoError(t, err)
+	require.Len(t, pruned, len(stored["query-prune-old"]))
+	for i, proof := range stored["query-prune-old"] {
+		hash := sha256.Sum256(proof.Data)
+		assert.Equal(t, proof.Key, pruned[i].Key)
+		assert.Equal(t, proof.Verdict, pruned[i].Verdict)
+		assert.Equal(t, hash[:], pruned[i].PrunedHash)
+		assert.Empty(t, pruned[i].Data)
+	}
+
+	proofs, err := clientSkip.SendGetAllProofs(nodes.vns.List[0], &libdrynx.GetProofs{ID: "query-prune-new"})
+	require.NoError(t, err)
+	assert.Equal(t, stored["query-prune-new"], proofs)
+
+	// pruning everything and compacting gives back the space
+	compacted, err := clientSkip.SendPruneDB(nodes.vns, &libdrynx.PruneDB{Compact: true})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"query-prune-new"}, compacted[0].Surveys)
+	assert.True(t, compacted[0].Size < replies[0].Size)
+
+	// a DB that cannot be replaced by its compacted version is kept open
+	backupPath := "db:" + nodes.vns.List[0].ID.String() + ".backup"
+	require.NoError(t, os.MkdirAll(backupPath+"/blocked", 0700))
+	defer os.RemoveAll(backupPath)
+	_, err = clientSkip.SendPruneDB(onet.NewRoster(nodes.vns.List[:1]), &libdrynx.PruneDB{Compact: true})
+	assert.Error(t, err)
+	proofs, err = clientSkip.SendGetAllProofs(nodes.vns.List[0], &libdrynx.GetProofs{ID: "query-prune-new"})
+	require.NoError(t, err)
+	assert.Len(t, proofs, len(stored["query-prune-new"]))
+
+	// the chain is still available
+	sb, err := clientSkip.SendGetBlock(nodes.vns, "query-prune-old")
+	require.NoError(t, err)
+	require.NoError(t, services.VerifyProofBlock(nodes.vns, sb))
+
+	require.NoError(t, clientSkip.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxGetProofs tests the filtering and the pagination of the proofs stored by a VN
+func TestServiceDrynxGetProofs(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 2)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-get-proofs")
+	clientSkip := services.NewDrynxClient(nodes.vns.List[0], "test-skip-get-proofs")
+
+	surveyID := "query-get-proofs"
+	sq := nodes.proofsQuery(client, surveyID)
+	require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sq))
+	_, _, err := client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+
+	all, err := clientSkip.SendGetAllProofs(nodes.vns.List[1], &libdrynx.GetProofs{ID: surveyID})
+	require.NoError(t, err)
+	// range proofs of the DPs, aggregation and key switch proofs of the CNs
+	require.Len(t, all, len(nodes.dps.List)+2*len(nodes.servers.List))
+	for i, proof := range all {
+		assert.Equal(t, drynxproof.ProofTrue, proof.Verdict)
+		assert.True(t, strings.HasSuffix(proof.Key, "/"+nodes.vns.List[1].Address.String()))
+		if i > 0 {
+			assert.True(t, all[i-1].Key < proof.Key)
+		}
+	}
+
+	// pages follow each other
+	paged := make([]libdrynx.StoredProof, 0)
+	request := &libdrynx.GetProofs{ID: surveyID, Limit: 3}
+	for {
+		page, err := clientSkip.SendGetProofs(nodes.vns.List[1], request)
+		require.NoError(t, err)
+		require.True(t, len(page.Proofs) <= 3)
+		paged = append(paged, page.Proofs...)
+		if page.Next == "" {
+			break
+		}
+		request.Cursor = page.Next
+	}
+	assert.Equal(t, all, paged)
+
+	// filters
+	ranges, err := clientSkip.SendGetAllProofs(nodes.vns.List[1], &libdrynx.GetProofs{ID: surveyID, Types: []string{"range"}, Limit: 1})
+	require.NoError(t, err)
+	assert.Len(t, ranges, len(nodes.dps.List))
+
+	sender, err := clientSkip.SendGetAllProofs(nodes.vns.List[1], &libdrynx.GetProofs{ID: surveyID, Sender: nodes.servers.List[1].String()})
+	require.NoError(t, err)
+	assert.Len(t, sender, 2)
+	for _, proof := range sender {
+		assert.Equal(t, nodes.servers.List[1].String(), proof.SenderID)
+	}
+
+	rejected, err := clientSkip.SendGetAllProofs(nodes.vns.List[1], &libdrynx.GetProofs{ID: surveyID, Verdicts: []int64{0}})
+	require.NoError(t, err)
+	assert.Empty(t, rejected)
+
+	require.NoError(t, clientSkip.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxProofInclusion tests that a single proof can be checked against the root in the block of its survey
+func TestServiceDrynxProofInclusion(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 3, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-inclusion")
+	clientSkip := services.NewDrynxClient(nodes.vns.List[0], "test-skip-inclusion")
+
+	surveyID := "query-inclusion"
+	sq := nodes.proofsQuery(client, surveyID)
+	require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sq))
+	_, _, err := client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+
+	sb, err := clientSkip.SendGetBlock(nodes.vns, surveyID)
+	require.NoError(t, err)
+	dataBlock, err := services.DecodeDataBlock(sb)
+	require.NoError(t, err)
+	require.NotEmpty(t, dataBlock.ProofsRoot)
+
+	// the proof of a DP, fetched from a VN, is checked with a path given by another VN
+	proofs, err := clientSkip.SendGetAllProofs(nodes.vns.List[1], &libdrynx.GetProofs{ID: surveyID, Sender: nodes.dps.List[0].String()})
+	require.NoError(t, err)
+	require.Len(t, proofs, 1)
+	proofID := drynxproof.ProofID(surveyID, proofs[0].Type, proofs[0].SenderID, proofs[0].DifferInfo)
+
+	for _, vn := range nodes.vns.List {
+		inclusion, err := clientSkip.SendGetProofInclusion(vn, surveyID, proofID)
+		require.NoError(t, err)
+		assert.NoError(t, services.VerifyProofInclusion(sb, inclusion, proofs[0].Data))
+	}
+
+	inclusion, err := clientSkip.SendGetProofInclusion(nodes.vns.List[2], surveyID, proofID)
+	require.NoError(t, err)
+	tampered := append([]byte{}, proofs[0].Data...)
+	tampered[len(tampered)-1] ^= 0xff
+	assert.Error(t, services.VerifyProofInclusion(sb, inclusion, tampered))
+	inclusion.ProofID = drynxproof.ProofID(surveyID, proofs[0].Type, nodes.dps.List[1].String(), proofs[0].DifferInfo)
+	assert.Error(t, services.VerifyProofInclusion(sb, inclusion, proofs[0].Data))
+
+	_, err = clientSkip.SendGetProofInclusion(nodes.vns.List[0], surveyID, "unknown")
+	assert.Error(t, err)
+
+	// the proof stays checkable once pruned
+	_, err = clientSkip.SendPruneDB(nodes.vns, &libdrynx.PruneDB{})
+	require.NoError(t, err)
+	inclusion, err = clientSkip.SendGetProofInclusion(nodes.vns.List[0], surveyID, proofID)
+	require.NoError(t, err)
+	assert.NoError(t, services.VerifyProofInclusion(sb, inclusion, proofs[0].Data))
+
+	require.NoError(t, clientSkip.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxConcurrentSurveys tests that surveys running at the same time on the same VNs each get their block,
+// appended in the order in which the VNs received the surveys
+func TestServiceDrynxConcurrentSurveys(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 2, 4)
+
+	clientSkip := services.NewDrynxClient(nodes.vns.List[0], "test-skip-concurrent")
+
+	nbrSurveys := 5
+	surveyIDs := make([]string, nbrSurveys)
+	sqs := make([]libdrynx.SurveyQuery, nbrSurveys)
+	for i := range sqs {
+		surveyIDs[i] = "query-concurrent-" + strconv.Itoa(i)
+		client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-concurrent-"+strconv.Itoa(i))
+		sqs[i] = nodes.proofsQuery(client, surveyIDs[i])
+		require.NoError(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sqs[i]))
+	}
+	// the VNs refuse a survey replacing one that is running
+	assert.Error(t, clientSkip.SendSurveyQueryToVNs(nodes.vns, &sqs[0]))
+
+	// the surveys are run in the reverse order of their reception by the VNs
+	wg := sync.WaitGroup{}
+	errs := make([]error, nbrSurveys)
+	for i := nbrSurveys - 1; i >= 0; i-- {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-concurrent-"+strconv.Itoa(i))
+			_, _, errs[i] = client.SendSurveyQueryVerified(sqs[i])
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+
+	blocks, err := clientSkip.SendGetChain(nodes.vns)
+	require.NoError(t, err)
+	require.Len(t, blocks, nbrSurveys+1)
+	for i, audit := range services.AuditChain(blocks)[1:] {
+		assert.Empty(t, audit.Errors)
+		assert.Equal(t, surveyIDs[i], audit.SurveyID)
+		assert.Equal(t, len(nodes.dps.List)*len(nodes.vns.List), audit.Verdicts["range"]["verified"])
+	}
+
+	require.NoError(t, clientSkip.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxEncryptedGroups tests that the querier gets the groups back when they are hidden from the nodes
+func TestServiceDrynxEncryptedGroups(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-encrypted-groups")
+
+	// every DP has a value of 3 in each group
+	operation := libdrynx.ChooseOperation("sum", 3, 4, 5, 0)
+	dpData := libdrynx.QueryDPDataGen{GroupByValues: []int64{2, 2}, GenerateRows: 1, GenerateDataMin: 3, GenerateDataMax: 4}
+
+	sq := nodes.surveyQuery(client, "query-encrypted-groups", operation, nil, dpData)
+	sq.Query.EncryptedGroups = true
+	require.True(t, libdrynx.CheckParameters(sq, false))
+
+	grp, aggr, err := client.SendSurveyQuery(sq)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, libdrynx.GroupLabels(dpData.GroupByValues), *grp)
+	for _, v := range *aggr {
+		assert.Equal(t, []float64{float64(3 * len(nodes.dps.List))}, v)
+	}
+
+	// with proofs, the DPs also prove that their encrypted groups are groups of the query
+	ranges := []*[]int64{{2, 4}}
+	ranges = append(ranges, libdrynx.BoundRanges(libdrynx.QueryRangeBounds(libdrynx.Query{Ranges: ranges, EncryptedGroups: true, DPDataGen: dpData}))...)
+	sq = nodes.surveyQuery(client, "query-encrypted-groups-proofs", operation, ranges, dpData)
+	sq.Query.EncryptedGroups = true
+	require.True(t, libdrynx.CheckParameters(sq, false))
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+	grp, aggr, err = client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, libdrynx.GroupLabels(dpData.GroupByValues), *grp)
+	for _, v := range *aggr {
+		assert.Equal(t, []float64{float64(3 * len(nodes.dps.List))}, v)
+	}
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxSmallGroupsSuppression tests that the groups with less records than the minimum are withheld, and
+// that the verifying nodes receive all the proofs even when every group is withheld
+func TestServiceDrynxSmallGroupsSuppression(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-suppression")
+
+	// every DP has one record with a value of 3 in each group
+	operation := libdrynx.ChooseOperation("sum", 3, 4, 5, 0)
+	dpData := libdrynx.QueryDPDataGen{GroupByValues: []int64{2, 2}, GenerateRows: 1, GenerateDataMin: 3, GenerateDataMax: 4}
+
+	for _, encryptedGroups := range []bool{false, true} {
+		for _, minGroupSize := range []int64{4, 5} {
+			surveyID := fmt.Sprintf("query-suppression-%d-%t", minGroupSize, encryptedGroups)
+			sq := nodes.surveyQuery(client, surveyID, operation, nil, dpData)
+			sq.Query.EncryptedGroups = encryptedGroups
+			sq.Query.MinGroupSize = minGroupSize
+
+			grp, aggr, err := client.SendSurveyQuery(sq)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, libdrynx.GroupLabels(dpData.GroupByValues), *grp)
+			for _, v := range *aggr {
+				if minGroupSize > int64(len(nodes.dps.List)) {
+					assert.Nil(t, v)
+				} else {
+					assert.Equal(t, []float64{float64(3 * len(nodes.dps.List))}, v)
+				}
+			}
+
+			// with proofs, the DPs also prove the size of their groups, which follows the range of the sum
+			ranges := []*[]int64{{2, 4}, {2, 1}}
+			ranges = append(ranges, libdrynx.BoundRanges(libdrynx.QueryRangeBounds(libdrynx.Query{Ranges: ranges, EncryptedGroups: encryptedGroups, DPDataGen: dpData}))...)
+			sq = nodes.surveyQuery(client, surveyID+"-proofs", operation, ranges, dpData)
+			sq.Query.EncryptedGroups = encryptedGroups
+			sq.Query.MinGroupSize = minGroupSize
+			require.True(t, libdrynx.CheckParameters(sq, false))
+			require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+			grp, aggr, err = client.SendSurveyQueryVerified(sq)
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, libdrynx.GroupLabels(dpData.GroupByValues), *grp)
+			for _, v := range *aggr {
+				if minGroupSize > int64(len(nodes.dps.List)) {
+					assert.Nil(t, v)
+				} else {
+					assert.Equal(t, []float64{float64(3 * len(nodes.dps.List))}, v)
+				}
+			}
+		}
+	}
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxMinMaxBitwise(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-min-max-bitwise")
+
+	// every DP has 4321 as its only value, in a domain too large for one bit per value
+	dpData := libdrynx.QueryDPDataGen{GroupByValues: []int64{1}, GenerateRows: 5, GenerateDataMin: 4321, GenerateDataMax: 4322}
+
+	for _, op := range []string{"min", "max"} {
+		operation := libdrynx.ChooseOperation(op, 0, 100000, 0, 0)
+		require.True(t, libdrynx.MinMaxBitwise(operation))
+
+		sq := nodes.surveyQuery(client, "query-bitwise-"+op, operation, nil, dpData)
+		grp, aggr, err := client.SendSurveyQuery(sq)
+		require.NoError(t, err)
+
+		require.Len(t, *grp, 1)
+		assert.Equal(t, []float64{4321}, (*aggr)[0])
+
+		// with proofs, the VNs also verify the obfuscation of each round
+		operation = libdrynx.ChooseOperation(op, 0, 8191, 0, 0)
+		sq = nodes.surveyQuery(client, "query-bitwise-proofs-"+op, operation, bitRanges(1), dpData)
+		require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+		grp, aggr, err = client.SendSurveyQueryVerified(sq)
+		require.NoError(t, err)
+
+		require.Len(t, *grp, 1)
+		assert.Equal(t, []float64{4321}, (*aggr)[0])
+	}
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+// TestServiceDrynxBloomFilters tests the union and the intersection of Bloom filters, also obfuscated and with proofs
+func TestServiceDrynxBloomFilters(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-bloom")
+
+	// the values of the DPs are in [1, 10]
+	dpData := libdrynx.QueryDPDataGen{GroupByValues: []int64{1}, GenerateRows: 5, GenerateDataMin: 1, GenerateDataMax: 11}
+
+	operation := libdrynx.ChooseOperation("bloom_union", 0, 0, 256, 0)
+	sq := nodes.surveyQuery(client, "query-bloom", operation, nil, dpData)
+	_, aggr, err := client.SendSurveyQuery(sq)
+	require.NoError(t, err)
+
+	filter := (*aggr)[0]
+	require.Len(t, filter, 256)
+	cardinality := libdrynxencoding.BloomCardinality(filter, operation.BloomHashes)
+	assert.True(t, cardinality > 0 && cardinality < 15)
+	assert.Equal(t, []bool{false, false}, libdrynxencoding.BloomMembers(filter, operation.BloomHashes, []string{"0", "11"}))
+
+	// obfuscated, the querier only learns whether each bit is set by a DP
+	for _, op := range []string{"bloom_union", "bloom_inter"} {
+		operation := libdrynx.ChooseOperation(op, 0, 0, 128, 0)
+		sq := nodes.surveyQuery(client, "query-"+op+"-proofs", operation, bitRanges(operation.NbrOutput), dpData)
+		sq.Query.Obfuscation = true
+		sq.ObfuscationProofThreshold = 1.0
+		require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+		_, aggr, err := client.SendSurveyQueryVerified(sq)
+		require.NoError(t, err)
+
+		filter := (*aggr)[0]
+		require.Len(t, filter, 128)
+		assert.True(t, libdrynxencoding.BloomCardinality(filter, operation.BloomHashes) < 15)
+		assert.Equal(t, []bool{false, false}, libdrynxencoding.BloomMembers(filter, operation.BloomHashes, []string{"0", "11"}))
+	}
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxDistinctCount(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-distinct-count")
+
+	// every DP has 7 as its only value
+	dpData := libdrynx.QueryDPDataGen{GroupByValues: []int64{1}, GenerateRows: 10, GenerateDataMin: 7, GenerateDataMax: 8}
+
+	operation := libdrynx.ChooseOperation("distinctCount", 0, 0, 4, 0)
+	sq := nodes.surveyQuery(client, "query-distinct-count", operation, nil, dpData)
+	_, aggr, err := client.SendSurveyQuery(sq)
+	require.NoError(t, err)
+
+	require.Len(t, (*aggr)[0], 2)
+	assert.InDelta(t, 1, (*aggr)[0][0], 0.5)
+
+	operation = libdrynx.ChooseOperation("distinctCount", 0, 0, 2, 0)
+	sq = nodes.surveyQuery(client, "query-distinct-count-proofs", operation, bitRanges(operation.NbrOutput), dpData)
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+	_, aggr, err = client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+
+	require.Len(t, (*aggr)[0], 2)
+	assert.InDelta(t, 1, (*aggr)[0][0], 0.5)
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxTopK(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-top-k")
+
+	// every DP has 10 records with the value 7
+	operation := libdrynx.ChooseOperation("topK", 0, 0, 8, 0)
+	operation.TopK = 1
+	operation.Candidates = []string{"8", "7"}
+	dpData := libdrynx.QueryDPDataGen{GroupByValues: []int64{1}, GenerateRows: 10, GenerateDataMin: 7, GenerateDataMax: 8}
+
+	// the cells are proven to be at most the number of records of a DP
+	operation.CountBound = dpData.GenerateRows
+	ranges := libdrynx.CountRanges(operation.CountBound, operation.NbrOutput)
+	sq := nodes.surveyQuery(client, "query-top-k", operation, append(ranges, ranges...), dpData)
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+	_, aggr, err := client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+
+	assert.Equal(t, []float64{1, 40}, (*aggr)[0])
+
+	// a cell above the bound is rejected, even if it is in the range of the proof of the cell
+	operation.CountBound = dpData.GenerateRows - 1
+	ranges = libdrynx.CountRanges(operation.CountBound, operation.NbrOutput)
+	sq = nodes.surveyQuery(client, "query-top-k-above-bound", operation, append(ranges, ranges...), dpData)
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+	_, _, err = client.SendSurveyQueryVerified(sq)
+	require.Error(t, err)
+	_, ok := err.(*services.ProofVerificationError)
+	assert.True(t, ok)
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxIterativeLogisticRegression(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-iterative-lr")
+
+	// the DPs generate random records
+	operation := libdrynx.LogisticRegressionOperation(libdrynx.LogisticRegressionParameters{NbrRecords: 20, NbrFeatures: 3,
+		FeatureBound: 3, Lambda: 1, Step: 0.5, MaxIterations: 3, PrecisionGradients: 1e2, Iterative: true})
+	nbrOutput := operation.NbrOutput
+
+	sq := nodes.surveyQuery(client, "query-iterative-lr", operation, libdrynx.CountRanges(1e4, nbrOutput), libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	require.True(t, libdrynx.CheckParameters(sq, false))
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+	grp, aggr, err := client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+
+	require.Len(t, *grp, 1)
+	require.Len(t, (*aggr)[0], 4)
+	for _, w := range (*aggr)[0] {
+		assert.False(t, math.IsNaN(w))
+	}
+
+	// the survey of the means and standard deviations and every step are surveys with their own block
+	_, err = client.SendGetBlock(nodes.vns, libdrynx.StandardisationRoundID("query-iterative-lr"))
+	require.NoError(t, err)
+	for round := 0; round < operation.LRParameters.MaxIterations; round++ {
+		_, err := client.SendGetBlock(nodes.vns, libdrynx.GradientRoundID("query-iterative-lr", round))
+		require.NoError(t, err)
+	}
+
+	// every step is smaller than the tolerance, so the training stops after the first one
+	operation.LRParameters.Tolerance = 1e9
+	sq = nodes.surveyQuery(client, "query-iterative-lr-tolerance", operation, libdrynx.CountRanges(1e4, nbrOutput), libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+	_, aggr, err = client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+	require.Len(t, (*aggr)[0], 4)
+
+	_, err = client.SendGetBlock(nodes.vns, libdrynx.GradientRoundID("query-iterative-lr-tolerance", 0))
+	require.NoError(t, err)
+	_, err = client.SendGetBlock(nodes.vns, libdrynx.GradientRoundID("query-iterative-lr-tolerance", 1))
+	assert.Error(t, err)
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxOneVsRestLogisticRegression(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 0)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-one-vs-rest-lr")
+
+	// the DPs generate 20 random records of 3 classes, of which the first feature is 0 and the others in [0, 4)
+	operation := libdrynx.LogisticRegressionOperation(libdrynx.LogisticRegressionParameters{NbrRecords: 20, NbrFeatures: 3,
+		NbrClasses: 3, Means: []float64{0, 1.5, 1.5}, StandardDeviations: []float64{1, 1.1, 1.1}, Lambda: 1, Step: 0.1,
+		MaxIterations: 50, InitialWeights: []float64{0, 0, 0, 0}, K: 2, PrecisionApproxCoefficients: 1e2})
+	require.Equal(t, 3*(4+16), operation.NbrOutput)
+
+	sq := nodes.surveyQuery(client, "query-one-vs-rest-lr", operation, nil, libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	grp, aggr, err := client.SendSurveyQuery(sq)
+	require.NoError(t, err)
+
+	require.Len(t, *grp, 1)
+	// a row of weights per class
+	require.Len(t, (*aggr)[0], 12)
+	for _, w := range (*aggr)[0] {
+		assert.False(t, math.IsNaN(w))
+	}
+	weights := libdrynx.LogisticRegressionWeightMatrix((*aggr)[0], operation.LRParameters)
+	require.Len(t, weights, 3)
+	// the records of the classes are drawn alike, each one is about a third of them
+	for c := range weights {
+		probability := 1 / (1 + math.Exp(-weights[c][0]))
+		assert.True(t, probability > 0.1 && probability < 0.6)
+	}
+}
+
+func TestServiceDrynxLogisticRegressionStandardisation(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-lr-standardisation")
+
+	// the DPs generate 20 random records, of which the first feature is 0 and the others in [0, 4)
+	operation := libdrynx.ChooseOperation("logistic regression", 0, 0, 0, 0)
+	operation.LRParameters = libdrynx.LogisticRegressionParameters{NbrRecords: 20, NbrFeatures: 3, StandardisationRound: true}
+	operation.NbrOutput = 3 * int(operation.LRParameters.NbrFeatures)
+
+	sq := nodes.surveyQuery(client, "query-lr-standardisation", operation, nil, libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	_, aggr, err := client.SendSurveyQuery(sq)
+	require.NoError(t, err)
+
+	require.Len(t, (*aggr)[0], 9)
+	means, standardDeviations := libdrynxencoding.StandardisationFromSums((*aggr)[0])
+	for j := range means {
+		assert.Equal(t, float64(80), (*aggr)[0][3*j+1])
+	}
+	assert.Equal(t, []float64{0, 0}, []float64{means[0], standardDeviations[0]})
+	for j := 1; j < 3; j++ {
+		assert.True(t, means[j] > 0 && means[j] < 3)
+		assert.True(t, standardDeviations[j] > 0 && standardDeviations[j] < 2)
+	}
+
+	// the sums are proven to be those of features in [0, 3]
+	operation.LRParameters.FeatureBound = 3
+	sq = nodes.surveyQuery(client, "query-lr-standardisation-proofs", operation, libdrynx.StandardisationRanges(operation.LRParameters), libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+	_, aggr, err = client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+	for j := 0; j < 3; j++ {
+		assert.Equal(t, float64(80), (*aggr)[0][3*j+1])
+	}
+
+	// but not of features in [0, 1]
+	operation.LRParameters.FeatureBound = 1
+	sq = nodes.surveyQuery(client, "query-lr-standardisation-rejected", operation, libdrynx.StandardisationRanges(operation.LRParameters), libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+	_, _, err = client.SendSurveyQueryVerified(sq)
+	_, ok := err.(*services.ProofVerificationError)
+	assert.True(t, ok)
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxClassifierEval(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-classifier-eval")
+
+	// the DPs generate 20 random records, all scored 0.5 by the model and then predicted as 0
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrRecords: 20, NbrFeatures: 3, Weights: []float64{0, 0, 0, 0}}
+	operation := libdrynx.ClassifierEvalOperation(lrParameters, 10)
+
+	sq := nodes.surveyQuery(client, "query-classifier-eval", operation, libdrynx.CountRanges(lrParameters.NbrRecords, operation.NbrOutput), libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	require.True(t, libdrynx.CheckParameters(sq, false))
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+	_, aggr, err := client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+
+	var evaluation libdrynx.ClassifierEvaluation
+	evaluation.FromFloats((*aggr)[0], 2, 10)
+	assert.Equal(t, int64(80), evaluation.Confusion[0]+evaluation.Confusion[2])
+	assert.Equal(t, []int64{0, 0}, []int64{evaluation.Confusion[1], evaluation.Confusion[3]})
+	assert.InDelta(t, float64(evaluation.Confusion[0])/80, evaluation.Accuracy, 1e-12)
+	assert.InDeltaSlice(t, []float64{0.5, 0.5}, evaluation.AUC, 1e-12)
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxNaiveBayes(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-naive-bayes")
+
+	// the DPs generate 20 random records of 3 classes, whose first feature is 0 and the others in [0, 3]
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrRecords: 20, NbrFeatures: 3, NbrClasses: 3}
+	for _, nbParameters := range []libdrynx.NaiveBayesParameters{{}, {Categories: 4}} {
+		operation := libdrynx.NaiveBayesOperation(lrParameters, nbParameters)
+		// the sums of the squares of the scaled features are the largest outputs
+		sq := nodes.surveyQuery(client, "query-naive-bayes-"+strconv.Itoa(int(nbParameters.Categories)), operation, libdrynx.CountRanges(1e8, operation.NbrOutput), libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+		require.True(t, libdrynx.CheckParameters(sq, false))
+		require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+		_, aggr, err := client.SendSurveyQueryVerified(sq)
+		require.NoError(t, err)
+
+		var model libdrynx.NaiveBayesModel
+		model.FromFloats((*aggr)[0], 3, 3, int(nbParameters.Categories))
+		assert.InDelta(t, 1, model.Priors[0]+model.Priors[1]+model.Priors[2], 1e-12)
+		for c := 0; c < 3; c++ {
+			if nbParameters.Categories == 0 {
+				assert.Equal(t, 0.0, model.Means[c][0])
+				assert.True(t, model.Means[c][1] >= 0 && model.Means[c][1] <= 3)
+				assert.True(t, model.Variances[c][0] > 0)
+			} else {
+				assert.True(t, model.Probabilities[c][0][0] > model.Probabilities[c][0][1])
+				assert.InDelta(t, 1, model.Probabilities[c][1][0]+model.Probabilities[c][1][1]+model.Probabilities[c][1][2]+model.Probabilities[c][1][3], 1e-12)
+			}
+		}
+		assert.Len(t, libdrynxencoding.PredictNaiveBayesInClear([]float64{0, 1, 2}, model), 3)
+	}
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxKMeans(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-kmeans")
+
+	// the DPs generate 20 random records, whose first feature is 0 and the others in [0, 3]
+	lrParameters := libdrynx.LogisticRegressionParameters{NbrRecords: 20, NbrFeatures: 3}
+	kmParameters := libdrynx.KMeansParameters{K: 2, InitialCentroids: []float64{0, 0, 0, 0, 3, 3}, MaxIterations: 5, Tolerance: 1e-3}
+	operation := libdrynx.KMeansOperation(lrParameters, kmParameters)
+
+	sq := nodes.surveyQuery(client, "query-kmeans", operation, nil, libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	_, aggr, err := client.SendSurveyQuery(sq)
+	require.NoError(t, err)
+
+	var result libdrynx.KMeansResult
+	result.FromFloats((*aggr)[0], 2, 3)
+	assert.Equal(t, 80.0, result.Sizes[0]+result.Sizes[1])
+	assert.True(t, result.Iterations >= 1 && result.Iterations <= 5)
+	for _, centroid := range result.Centroids {
+		assert.Equal(t, 0.0, centroid[0])
+		for _, x := range centroid[1:] {
+			assert.True(t, x >= 0 && x <= 3)
+		}
+	}
+
+	// with proofs, the sums of a cluster are at most those of 20 records at 3, scaled by the precision
+	kmParameters.MaxIterations = 2
+	operation = libdrynx.KMeansOperation(lrParameters, kmParameters)
+	sq = nodes.surveyQuery(client, "query-kmeans-proofs", operation, libdrynx.CountRanges(20*3*libdrynx.KMeansDefaultPrecision, operation.NbrOutput), libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+	_, aggr, err = client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+	result.FromFloats((*aggr)[0], 2, 3)
+	assert.Equal(t, 80.0, result.Sizes[0]+result.Sizes[1])
+
+	// every round has its own block, the clustering itself is not a survey
+	for round := 0; round < result.Iterations; round++ {
+		_, err := client.SendGetBlock(nodes.vns, libdrynx.KMeansRoundID("query-kmeans-proofs", round))
+		require.NoError(t, err)
+	}
+	_, err = client.SendGetBlock(nodes.vns, "query-kmeans-proofs")
+	assert.Error(t, err)
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxPCA(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-pca")
+
+	// every DP has 10 records of 3 dimensions in [0, 5), whose cross-products sum to at most 160
+	operation := libdrynx.PCAOperation(3, libdrynx.PCAParameters{Components: 2})
+	dpData := libdrynx.QueryDPDataGen{GroupByValues: []int64{1}, GenerateRows: 10, GenerateDataMin: 0, GenerateDataMax: 5}
+
+	sq := nodes.surveyQuery(client, "query-pca", operation, libdrynx.CountRanges(160, operation.NbrOutput), dpData)
+	require.NoError(t, client.SendSurveyQueryToVNs(nodes.vns, &sq))
+
+	_, aggr, err := client.SendSurveyQueryVerified(sq)
+	require.NoError(t, err)
+
+	require.Len(t, (*aggr)[0], 3+2+2+2*3+2*3)
+	var pcar libdrynx.PCAResult
+	pcar.FromFloats((*aggr)[0], 3, 2)
+	for _, mean := range pcar.Means {
+		assert.True(t, mean >= 0 && mean <= 4)
+	}
+	assert.True(t, pcar.ExplainedVariance[0] >= pcar.ExplainedVariance[1] && pcar.ExplainedVariance[1] >= 0)
+	assert.True(t, pcar.ExplainedVarianceRatio[0]+pcar.ExplainedVarianceRatio[1] <= 1+1e-12)
+	for _, component := range pcar.Components {
+		norm := 0.0
+		for _, v := range component {
+			norm += v * v
+		}
+		assert.InDelta(t, 1, norm, 1e-9)
+	}
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
+
+func TestServiceDrynxCrossValidation(t *testing.T) {
+	if testing.Short() {
+		t.Skip()
+	}
+
+	local := onet.NewLocalTest(libunlynx.SuiTe)
+	defer local.CloseAll()
+	nodes := newTestNodes(local, 2, 4, 3)
+
+	client := services.NewDrynxClient(nodes.servers.List[0], "test-Drynx-cross-validation")
+
+	// 60 records of two features in [0, 4), labelled 1 if their sum is at least 3, shared among the DPs
+	file, err := ioutil.TempFile("", "cross-validation")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	for i := 0; i < 60; i++ {
+		x0, x1 := i%4, (i/4)%4
+		y := 0
+		if x0+x1 >= 3 {
+			y = 1
+		}
+		_, err = fmt.Fprintf(file, "%d,%d,%d\n", y, x0, x1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, file.Close())
+
+	records := int64(0)
+	for _, dp := range nodes.dps.List {
+		x, _, err := libdrynxencoding.GetDataForDataProvider("CV", file.Name(), *dp)
+		require.NoError(t, err)
+		records += int64(len(x))
+	}
+	require.True(t, records > 0)
+
+	// the models are standardised with the means and standard deviations of the training folds
+	operation := libdrynx.LogisticRegressionOperation(libdrynx.LogisticRegressionParameters{DatasetName: "CV", FilePath: file.Name(),
+		NbrRecords: 60, NbrFeatures: 2, FeatureBound: 3, Step: 0.5, MaxIterations: 1, PrecisionGradients: 1e2, Iterative: true})
+
+	sq := nodes.surveyQuery(client, "query-cross-validation", operation, libdrynx.CountRanges(1e4, operation.NbrOutput), libdrynx.QueryDPDataGen{GroupByValues: []int64{1}})
+	results, err := client.CrossValidateVerified(sq, libdrynx.CrossValidationParameters{Folds: 2, Seed: 7, ScoreBins: 10, Lambdas: []float64{0.1, 1}})
+	require.NoError(t, err)
+
+	require.Len(t, results, 2)
+	assert.Equal(t, []float64{0.1, 1}, []float64{results[0].Lambda, results[1].Lambda})
+	for _, result := range results {
+		require.Len(t, result.Folds, 2)
+		// every record of the DPs is tested in exactly one of the folds
+		total := int64(0)
+		for _, fold := range result.Folds {
+			for _, c := range fold.Confusion {
+				total += c
+			}
+		}
+		assert.Equal(t, records, total)
+		assert.InDelta(t, (result.Folds[0].Accuracy+result.Folds[1].Accuracy)/2, result.Mean.Accuracy, 1e-12)
+		assert.True(t, result.Variance.Accuracy >= 0)
+	}
+
+	// the evaluations have their own block
+	_, err = client.SendGetBlock(nodes.vns, libdrynx.CrossValidationRoundID("query-cross-validation", 1, 1, "eval"))
+	require.NoError(t, err)
+	require.NoError(t, client.SendCloseDB(nodes.vns, &libdrynx.CloseDB{Close: 1}))
+}
@@ -1,6 +1,6 @@
 set -eumo pipefail
 
-readonly node_count=3
+readonly node_count=${node_count:-3}
 readonly host_name=localhost
 
 trap cleanup EXIT QUIT
@@ -42,6 +42,7 @@ start_nodes() {
 	do
 		local conf=$(server gen $host_name:{$port,$((port+1))})
 		publics+=" $(echo "$conf" | awk -F \" '/^Public\s*=/ {print $2}')"
+		publics+=",$(echo "$conf" | awk -F \" '/\[Services.Skipchain\]/ {s=1} s && /Public\s*=/ {print $2; exit}')"
 
 		echo "$conf" | DEBUG_COLOR=true server run &
 		nodes+=" $!"
@@ -60,9 +61,10 @@ get_nodes() {
 	[ -z "$nodes" ] && ( echo asking roster of stopped nodes; exit 1 )
 
 	local port=$port_base
+	# the public key and the skipchain key of each node
 	for public in $publics
 	do
-		echo $host_name:$port $public
+		echo $host_name:$port $(echo $public | tr , ' ')
 		: $((port += 2))
 	done
 }
@@ -73,10 +75,11 @@ get_client() {
 	echo $host_name:$((port_base+1))
 }
 
+# network of the first $1 nodes, all of them by default
 client_gen_network() {
 	local pipe=$(
 		echo -n client network new
-		for n in $(get_nodes | tr ' ' ,)
+		for n in $(get_nodes | head -n ${1:-$node_count} | cut -d ' ' -f 1,2 | tr ' ' ,)
 		do
 			echo -n " | client network add-node $(echo $n | tr , ' ')"
 		done
@@ -84,3 +87,21 @@ client_gen_network() {
 	)
 	eval "$pipe"
 }
+
+# verifying nodes from the $1th node on, all of them by default
+client_add_verifying_nodes() {
+	local pipe=cat
+	for n in $(get_nodes | tail -n +${1:-1} | tr ' ' ,)
+	do
+		pipe+=" | client network add-verifying-node $(echo $n | tr , ' ')"
+	done
+	eval "$pipe"
+}
+
+fails() {
+	if "$@"
+	then
+		echo "unexpected success of: $*" >&2
+		return 1
+	fi
+}